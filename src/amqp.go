@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/tracing"
+	"github.com/eventscompass/service-framework/pubsub"
+	"github.com/eventscompass/service-framework/pubsub/rabbitmq"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// amqpBus is a message bus backed by a RabbitMQ message broker. The bus of the
+// service framework does not expose its connection to the broker, thus a
+// separate connection to the same broker is kept for checking the health of
+// the broker, and for publishing messages with headers.
+type amqpBus struct {
+	*rabbitmq.Bus
+
+	// conn is the connection that is checked, and that is
+	// used for publishing.
+	conn *amqp.Connection
+
+	// exchange is the exchange to which messages are published.
+	exchange string
+}
+
+// newAMQPBus connects to the broker given by the configuration. This function
+// returns [service.ErrConnectionClosed] if the broker cannot be reached.
+func newAMQPBus(cfg *BusConfig) (*amqpBus, error) {
+	bus, err := rabbitmq.NewAMQPBus(&rabbitmq.Config{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+	}, pubsub.EventsExchange)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrConnectionClosed, err)
+	}
+	conn, err := amqp.Dial(fmt.Sprintf(
+		"amqp://%s:%s@%s:%d", cfg.Username, cfg.Password, cfg.Host, cfg.Port))
+	if err != nil {
+		_ = bus.Close() //nolint:errcheck // intentional
+		return nil, fmt.Errorf("%w: dial broker: %v", service.ErrConnectionClosed, err)
+	}
+	return &amqpBus{Bus: bus, conn: conn, exchange: pubsub.EventsExchange}, nil
+}
+
+// Ping checks that the connection to the broker is open. This function
+// returns [service.ErrConnectionClosed] if the connection is closed.
+func (b *amqpBus) Ping(ctx context.Context) error {
+	if b.conn.IsClosed() {
+		return fmt.Errorf("%w: amqp connection", service.ErrConnectionClosed)
+	}
+	return ctx.Err() //nolint:wrapcheck // context errors are returned as is
+}
+
+// Publish implements the [service.MessageBus] interface. It publishes the
+// message in the same way as the bus of the service framework, but propagates
+// the trace context in the traceparent header of the message, and the tenant of
+// the message in its tenant_id header. This function
+// returns [service.ErrConnectionClosed] if the message cannot be published.
+func (b *amqpBus) Publish(ctx context.Context, topic string, msg []byte) error {
+	// AMQP channels are not thread-safe, thus a new channel is
+	// opened for every message.
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("%w: open channel: %v", service.ErrConnectionClosed, err)
+	}
+	defer ch.Close() //nolint:errcheck // intentional
+
+	err = ch.ExchangeDeclare(b.exchange, "topic", true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("%w: declare exchange: %v", service.ErrConnectionClosed, err)
+	}
+
+	headers := amqp.Table{}
+	if tp := tracing.SpanContextFromContext(ctx).Traceparent(); tp != "" {
+		headers[traceparentHeader] = tp
+	}
+	if tenant, ok := internal.TenantFromContext(ctx); ok {
+		headers[tenantField] = tenant
+	}
+	err = ch.PublishWithContext(ctx, b.exchange, topic, false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Headers:     headers,
+		Body:        msg,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: publish message: %v", service.ErrConnectionClosed, err)
+	}
+	return nil
+}
+
+// Close implements the [service.MessageBus] interface.
+func (b *amqpBus) Close() error {
+	_ = b.conn.Close()   //nolint:errcheck // intentional
+	return b.Bus.Close() //nolint:wrapcheck // the bus returns its own errors
+}
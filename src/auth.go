@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/eventscompass/events-service/src/eventspb"
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/service"
+)
+
+const (
+	// apiKeyHeader is the header in which the clients send their
+	// API key.
+	apiKeyHeader = "X-API-Key"
+
+	// adminTokenHeader is the header in which the admins send the
+	// admin token. See [Config.AdminToken].
+	adminTokenHeader = "X-Admin-Token"
+)
+
+// grpcReadMethods are the grpc methods that only read, which anonymous clients
+// may call if reads are public.
+var grpcReadMethods = map[string]bool{
+	eventspb.EventsService_GetEvent_FullMethodName:   true,
+	eventspb.EventsService_ListEvents_FullMethodName: true,
+}
+
+// grpcWriteActions maps the grpc methods that write to the action that they
+// perform on the events.
+var grpcWriteActions = map[string]string{
+	eventspb.EventsService_CreateEvent_FullMethodName: auth.ActionCreate,
+	eventspb.EventsService_UpdateEvent_FullMethodName: auth.ActionUpdate,
+	eventspb.EventsService_DeleteEvent_FullMethodName: auth.ActionDelete,
+}
+
+// authPolicy authenticates the clients of the rest and grpc apis, and grants
+// them the roles of the policy. Clients send either a JSON Web Token as a
+// bearer token in the Authorization header, or an API key in the X-API-Key
+// header. Admins may send the admin token in the X-Admin-Token header as well.
+// The grpc clients send them as metadata with the same names.
+type authPolicy struct {
+	authn *auth.Authenticator
+
+	// policy decides which client may change which resources.
+	policy *auth.Policy
+
+	// adminToken authenticates the admins of the service. See
+	// [Config.AdminToken].
+	adminToken string
+
+	// publicReads allows anonymous clients to read. See
+	// [AuthConfig.PublicReads].
+	publicReads bool
+}
+
+// credentials are the credentials sent with a request. They are empty if not
+// sent.
+type credentials struct {
+	authorization string
+	apiKey        string
+	adminToken    string
+}
+
+// newAuthPolicy creates the authentication policy selected by the
+// configuration.
+func newAuthPolicy(cfg *AuthConfig, adminToken string) (*authPolicy, error) {
+	policy, err := auth.LoadPolicy(cfg.PolicyFile)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // intentional
+	}
+	authn, err := auth.NewAuthenticator(&auth.Config{
+		JWKSFile:  cfg.JWKSFile,
+		JWTSecret: cfg.JWTSecret,
+		Issuer:    cfg.JWTIssuer,
+		Audience:  cfg.JWTAudience,
+		APIKeys:   cfg.APIKeys,
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // intentional
+	}
+	switch {
+	case !authn.Enabled() && !cfg.Disabled:
+		return nil, fmt.Errorf("%w: no jwt keys or api keys are configured, "+
+			"set EVENTS_AUTH_DISABLED=true to disable the authentication", service.ErrUnexpected)
+	case authn.Enabled() && cfg.Disabled:
+		return nil, fmt.Errorf("%w: the authentication is disabled, "+
+			"but jwt keys or api keys are configured", service.ErrUnexpected)
+	case cfg.Disabled:
+		slog.Warn("authentication is disabled, only the admin token can authenticate")
+	}
+	return &authPolicy{
+		authn:       authn,
+		policy:      policy,
+		adminToken:  adminToken,
+		publicReads: cfg.PublicReads,
+	}, nil
+}
+
+// authenticate authenticates the client of a request from the credentials
+// sent with the request. The returned context holds the principal of the
+// client, if the client authenticated. This function returns
+// [auth.ErrUnauthenticated] if the credentials are not valid, or if the client
+// is anonymous and the request writes, or reads while reads are not public.
+// Reads are always public if the authentication is disabled.
+func (p *authPolicy) authenticate(
+	ctx context.Context,
+	creds *credentials,
+	read bool,
+) (context.Context, error) {
+	principal, err := p.principal(creds)
+	if err != nil {
+		return nil, err
+	}
+	if principal == nil {
+		if !read || (p.authn.Enabled() && !p.publicReads) {
+			return nil, fmt.Errorf("%w: no credentials", auth.ErrUnauthenticated)
+		}
+		return ctx, nil
+	}
+
+	// The logs of the request identify the client.
+	logger := internal.Logger(ctx).With(slog.String("principal", principal.Subject))
+	ctx = internal.WithLogger(ctx, logger)
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// principal returns the client identified by the credentials, together with
+// its roles, or nil if the client is anonymous. The credentials other than the
+// admin token are ignored if the authentication is disabled. This function
+// returns [auth.ErrUnauthenticated] if the credentials are not valid.
+func (p *authPolicy) principal(creds *credentials) (*auth.Principal, error) {
+	var principal *auth.Principal
+	var err error
+	switch {
+	case !p.authn.Enabled():
+	case creds.authorization != "":
+		scheme, token, _ := strings.Cut(creds.authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			return nil, fmt.Errorf(
+				"%w: unsupported authorization scheme %q", auth.ErrUnauthenticated, scheme)
+		}
+		principal, err = p.authn.AuthenticateToken(strings.TrimSpace(token))
+	case creds.apiKey != "":
+		principal, err = p.authn.AuthenticateAPIKey(creds.apiKey)
+	}
+	if err != nil {
+		return nil, err //nolint:wrapcheck // intentional
+	}
+	if principal != nil {
+		p.policy.ResolveRoles(principal)
+	}
+
+	// The admin token grants the admin role, even to clients that are
+	// otherwise anonymous.
+	if p.isAdminToken(creds.adminToken) {
+		if principal == nil {
+			principal = &auth.Principal{Subject: auth.RoleAdmin, Method: auth.MethodAdminToken}
+		}
+		principal.Roles = append(principal.Roles, auth.RoleAdmin)
+	}
+	return principal, nil
+}
+
+// isAdminToken returns true if the given token is the admin token. The admin
+// token is never valid if no token is configured. See [Config.AdminToken].
+func (p *authPolicy) isAdminToken(token string) bool {
+	return p.adminToken != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(p.adminToken)) == 1
+}
+
+// middleware is a middleware that authenticates the clients of the rest api.
+// Requests with the GET, HEAD and OPTIONS methods are reads.
+func (p *authPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead ||
+			r.Method == http.MethodOptions
+		ctx, err := p.authenticate(r.Context(), &credentials{
+			authorization: r.Header.Get("Authorization"),
+			apiKey:        r.Header.Get(apiKeyHeader),
+			adminToken:    r.Header.Get(adminTokenHeader),
+		}, read)
+		if err != nil {
+			httpError(r.Context(), w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// unaryInterceptor authenticates the clients of the unary grpc methods. The
+// clients that may not perform the action of a method on any event are
+// rejected right away. Whether they may perform it on the requested event is
+// decided by the handler, once the event is known.
+func (p *authPolicy) unaryInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	authenticated, err := p.authenticateGRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	ctx = authenticated
+	if action, ok := grpcWriteActions[info.FullMethod]; ok {
+		principal, _ := auth.PrincipalFromContext(ctx)
+		if !p.policy.Permits(principal, action, auth.ResourceEvent) {
+			return nil, grpcError(ctx, fmt.Errorf("%w: the client may not %s events",
+				service.ErrNotAllowed, action))
+		}
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor authenticates the clients of the streaming grpc methods.
+func (p *authPolicy) streamInterceptor(
+	srv any,
+	stream grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, err := p.authenticateGRPC(stream.Context(), info.FullMethod)
+	if err != nil {
+		return grpcError(stream.Context(), err)
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticateGRPC authenticates the client of the given grpc method from the
+// metadata of the request.
+func (p *authPolicy) authenticateGRPC(
+	ctx context.Context,
+	method string,
+) (context.Context, error) {
+	first := func(key string) string {
+		if v := metadata.ValueFromIncomingContext(ctx, key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	return p.authenticate(ctx, &credentials{
+		authorization: first("authorization"),
+		apiKey:        first("x-api-key"),
+		adminToken:    first("x-admin-token"),
+	}, grpcReadMethods[method])
+}
+
+// authenticatedStream is a server stream whose context holds the principal of
+// the client, and the tenant of the request once it is resolved.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx // the context of the stream
+}
+
+// Context implements the [grpc.ServerStream] interface.
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorize makes sure that the client of the request may perform the action
+// on the resource owned by the given owner. See [auth.Policy.Authorize]. This
+// function returns [service.ErrNotAllowed] if the client may not perform the
+// action.
+func (h *restHandler) authorize(ctx context.Context, action, resource, owner string) error {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	return h.policy.Authorize(principal, action, resource, owner) //nolint:wrapcheck // intentional
+}
+
+// owner returns the identity of the client of the request, who becomes the
+// owner of the resources that it creates. See [auth.Principal.Owner]. It is
+// empty if the client is anonymous.
+func owner(ctx context.Context) string {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	return principal.Owner()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/service"
+)
+
+func TestNewAuthPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  AuthConfig
+		want error
+	}{
+		{"keys", AuthConfig{APIKeys: []string{"ops:ops-key"}}, nil},
+		{"disabled", AuthConfig{Disabled: true}, nil},
+		{"no keys", AuthConfig{}, service.ErrUnexpected},
+		{"disabled with keys", AuthConfig{Disabled: true, JWTSecret: testSecret},
+			service.ErrUnexpected},
+	}
+	for _, tt := range tests {
+		_, err := newAuthPolicy(&tt.cfg, "")
+		if (tt.want == nil && err != nil) || !errors.Is(err, tt.want) {
+			t.Errorf("%s: newAuthPolicy() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	disabled, err := newAuthPolicy(&AuthConfig{Disabled: true}, testAdminToken)
+	if err != nil {
+		t.Fatalf("newAuthPolicy() error = %v", err)
+	}
+	private, err := newAuthPolicy(&AuthConfig{JWTSecret: testSecret}, testAdminToken)
+	if err != nil {
+		t.Fatalf("newAuthPolicy() error = %v", err)
+	}
+	admin := credentials{adminToken: testAdminToken}
+	tests := []struct {
+		name   string
+		policy *authPolicy
+		creds  credentials
+		read   bool
+		want   error
+	}{
+		{"disabled read", disabled, credentials{}, true, nil},
+		{"disabled write", disabled, credentials{}, false, auth.ErrUnauthenticated},
+		{"disabled api key", disabled, credentials{apiKey: "ops-key"}, false,
+			auth.ErrUnauthenticated},
+		{"disabled admin write", disabled, admin, false, nil},
+		{"private read", private, credentials{}, true, auth.ErrUnauthenticated},
+		{"private admin read", private, admin, true, nil},
+	}
+	for _, tt := range tests {
+		_, err := tt.policy.authenticate(context.Background(), &tt.creds, tt.read)
+		if (tt.want == nil && err != nil) || !errors.Is(err, tt.want) {
+			t.Errorf("%s: authenticate() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
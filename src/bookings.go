@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/pubsub"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// attendanceFields are the fields of the event read endpoints that describe
+// the attendance of the events. They are not stored with the events, but are
+// computed from the bookings.
+var attendanceFields = map[string]bool{
+	"booked":    true,
+	"remaining": true,
+	"sold_out":  true,
+}
+
+// eventView is the response body for reading an event. It includes the
+// attendance of the event.
+type eventView struct {
+	internal.Event
+	internal.Attendance
+}
+
+// eventBooked records the booking announced by the given message. Messages
+// that are redelivered are ignored, since the booking has already been
+// recorded. Bookings of unknown events are dropped. The booking is recorded
+// within the tenant named by the message. See [messageTenant].
+func (s *EventsService) eventBooked(ctx context.Context, msg []byte) {
+	var booked pubsub.EventBooked
+	err := json.Unmarshal(msg, &booked)
+	tenant, tenantErr := messageTenant(msg)
+	if err = errors.Join(err, tenantErr); err != nil {
+		internal.Logger(ctx).Error("failed to decode booking", slog.String("error", err.Error()))
+		return
+	}
+	ctx = scope(ctx, tenant)
+	logger := internal.Logger(ctx).With(
+		slog.String("event_id", booked.EventID),
+		slog.String("user_id", booked.UserID),
+	)
+	if booked.EventID == "" || booked.UserID == "" {
+		logger.Error("dropping incomplete booking")
+		return
+	}
+
+	// Make sure that the event exists. Occurrences of recurring events are
+	// booked using their own ids.
+	_, err = findEvent(ctx, s.eventsDB, booked.EventID)
+	if errors.Is(err, service.ErrNotFound) {
+		logger.Warn("dropping booking of unknown event")
+		return
+	}
+	if err != nil {
+		logger.Error("failed to get booked event", slog.String("error", err.Error()))
+		return
+	}
+
+	// Record the booking.
+	booking := internal.Booking{
+		ID:       internal.BookingID(booked.EventID, booked.UserID),
+		EventID:  booked.EventID,
+		UserID:   booked.UserID,
+		BookedAt: time.Now().UTC(),
+	}
+	err = s.eventsDB.Create(ctx, internal.BookingsCollection, booking)
+	switch {
+	case errors.Is(err, service.ErrAlreadyExists):
+		logger.Info("booking already recorded")
+	case err != nil:
+		logger.Error("failed to record booking", slog.String("error", err.Error()))
+	default:
+		logger.Info("booking successfully recorded")
+	}
+}
+
+// withAttendance returns the given events together with their attendance. The
+// location data of the events must be filled in, since the capacity of an
+// event is the capacity of its hall.
+func (h *restHandler) withAttendance(
+	ctx context.Context,
+	events ...internal.Event,
+) ([]eventView, error) {
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	booked, err := h.eventsDB.CountBookings(ctx, ids...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the container returns service errors
+	}
+
+	res := make([]eventView, 0, len(events))
+	for i := range events {
+		res = append(res, eventView{
+			Event:      events[i],
+			Attendance: internal.NewAttendance(&events[i], booked[events[i].ID]),
+		})
+	}
+	return res, nil
+}
@@ -1,5 +1,11 @@
 package main
 
+import (
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
 // Config encapsulates the configuration of the service.
 type Config struct {
 
@@ -10,23 +16,182 @@ type Config struct {
 	// BusConfig encapsulates the configuration for the message
 	// bus used by the service.
 	EventsMQ BusConfig
+
+	// REST encapsulates the configuration of the http server.
+	// The framework parses it as well in order to start the
+	// server, but it is up to the service to dump the requests.
+	REST service.RESTConfig
+
+	// Auth encapsulates the configuration of the authentication
+	// of the clients of the service.
+	Auth AuthConfig
+
+	// Tenants encapsulates the configuration of the resolution
+	// of the tenant of the requests.
+	Tenants TenantConfig
+
+	// Tracing encapsulates the configuration of the tracing of
+	// the requests handled by the service.
+	Tracing TracingConfig
+
+	// LocationRefs configures the service to store only the id
+	// of the location of an event. The location data is filled
+	// in when the event is read, so that changes to a location
+	// reach all of its events.
+	LocationRefs bool `env:"EVENTS_LOCATION_REFS" envDefault:"false"`
+
+	// OutboxInterval is the interval at which the outbox is
+	// polled for messages to be published. OutboxMaxBackoff is
+	// the maximum time to wait before retrying to publish after
+	// a failure.
+	OutboxInterval   time.Duration `env:"EVENTS_OUTBOX_INTERVAL" envDefault:"1s"`
+	OutboxMaxBackoff time.Duration `env:"EVENTS_OUTBOX_MAX_BACKOFF" envDefault:"1m"`
+
+	// RecurrenceHorizon is how long before they start the
+	// occurrences of recurring events are announced. It also
+	// bounds how far ahead the occurrences are checked for
+	// overlaps with other events.
+	RecurrenceHorizon time.Duration `env:"EVENTS_RECURRENCE_HORIZON" envDefault:"2160h"`
+
+	// AdminToken authenticates the admins of the service, who
+	// send it in the X-Admin-Token header. The clients sending
+	// the token are granted the "admin" role of the policy. If
+	// empty, then the role is granted only by the policy.
+	AdminToken string `env:"EVENTS_ADMIN_TOKEN"`
 }
 
 // DBConfig encapsulates the configuration of the database layer
 // used by the service.
 type DBConfig struct {
+	// Backend selects the database used by the service. It is
+	// one of "mongodb", "file" or "memory". The file database
+	// persists the data in a local directory. The in-memory
+	// database does not persist the data and is meant for local
+	// development.
+	Backend string `env:"EVENTS_DB_BACKEND" envDefault:"mongodb"`
+
+	// Path is the directory where the file database stores the
+	// data. CompactInterval is the interval at which its log is
+	// compacted.
+	Path            string        `env:"EVENTS_DB_PATH" envDefault:"./data"`
+	CompactInterval time.Duration `env:"EVENTS_DB_COMPACT_INTERVAL" envDefault:"10m"`
+
 	Host     string `env:"MONGO_DB_HOST" envDefault:"mongodb"`
 	Port     int    `env:"MONGO_DB_PORT" envDefault:"27017"`
 	Username string `env:"MONGO_DB_USERNAME" envDefault:"user"`
 	Password string `env:"MONGO_DB_PASSWORD" envDefault:"password"`
 	Database string `env:"MONGO_DB_DATABASE" envDefault:"events"`
+
+	// UniqueNames configures the database to reject entries with
+	// a name that is already taken within the same collection.
+	UniqueNames bool `env:"MONGO_DB_UNIQUE_NAMES" envDefault:"false"`
 }
 
 // BusConfig encapsulates the configuration for the message bus
 // used by the service.
 type BusConfig struct {
+	// Backend selects the message bus used by the service. It is
+	// either "rabbitmq" or "memory". The in-memory message bus
+	// delivers the messages only within the service itself.
+	Backend string `env:"EVENTS_MQ_BACKEND" envDefault:"rabbitmq"`
+
 	Host     string `env:"RABBIT_MQ_HOST"`
 	Port     int    `env:"RABBIT_MQ_PORT"`
 	Username string `env:"RABBIT_MQ_USERNAME"`
 	Password string `env:"RABBIT_MQ_PASSWORD"`
 }
+
+// AuthConfig encapsulates the configuration of the authentication of the
+// clients of the service. Keys must be configured, unless the authentication
+// is disabled.
+type AuthConfig struct {
+	// Disabled disables the authentication with tokens and API
+	// keys, in which case no keys may be configured. Every client
+	// can then read, but only the admins can write, using the
+	// admin token.
+	Disabled bool `env:"EVENTS_AUTH_DISABLED"`
+
+	// JWKSFile is the path of a file holding a JSON Web Key Set,
+	// whose keys verify the signatures of the bearer tokens.
+	// JWTSecret is the secret that verifies the signatures of
+	// the bearer tokens signed with HMAC. It must be at least
+	// 32 bytes long.
+	JWKSFile  string `env:"EVENTS_AUTH_JWKS_FILE"`
+	JWTSecret string `env:"EVENTS_AUTH_JWT_SECRET"`
+
+	// JWTIssuer and JWTAudience are the expected "iss" and "aud"
+	// claims of the bearer tokens. They are not checked if empty.
+	JWTIssuer   string `env:"EVENTS_AUTH_JWT_ISSUER"`
+	JWTAudience string `env:"EVENTS_AUTH_JWT_AUDIENCE"`
+
+	// APIKeys are the static API keys, given as comma separated
+	// "name:key" pairs. Clients send the key in the X-API-Key
+	// header.
+	APIKeys []string `env:"EVENTS_AUTH_API_KEYS"`
+
+	// PublicReads allows anonymous clients to read. Writes always
+	// require authentication.
+	PublicReads bool `env:"EVENTS_AUTH_PUBLIC_READS" envDefault:"true"`
+
+	// PolicyFile is the path of a JSON file holding the policy
+	// that decides which client may change which resources. If
+	// empty, then organizers can change their own events, venue
+	// managers their own locations, and admins every resource.
+	PolicyFile string `env:"EVENTS_AUTH_POLICY_FILE"`
+}
+
+// TenantConfig encapsulates the configuration of the resolution of the tenant
+// of the requests. A request is bound to the tenant of its client, taken from
+// its token or API key, or to the tenant of its host, and the request cannot
+// override it.
+type TenantConfig struct {
+	// Header is the header, or the grpc metadata, in which the
+	// clients that may act across tenants name the tenant of a
+	// request that is not bound to one.
+	Header string `env:"EVENTS_TENANT_HEADER" envDefault:"X-Tenant-ID"`
+
+	// CrossTenantRoles are the roles of the policy whose clients
+	// may act across tenants. The admin token grants the "admin"
+	// role.
+	CrossTenantRoles []string `env:"EVENTS_TENANT_CROSS_ROLES" envDefault:"admin"`
+
+	// Claim is the claim of the bearer tokens that holds the
+	// tenant of the client.
+	Claim string `env:"EVENTS_TENANT_CLAIM" envDefault:"tenant"`
+
+	// Hosts binds the requests sent to a host to a tenant, given
+	// as comma separated "host:tenant" pairs.
+	Hosts []string `env:"EVENTS_TENANT_HOSTS"`
+
+	// APIKeys assigns the clients that authenticate with an API
+	// key to a tenant, given as comma separated "name:tenant"
+	// pairs, where name is the name of the key.
+	APIKeys []string `env:"EVENTS_TENANT_API_KEYS"`
+
+	// Required rejects the requests whose tenant cannot be
+	// resolved. If false, then they are served within the
+	// "default" tenant, which also holds the data stored before
+	// the service became aware of tenants.
+	Required bool `env:"EVENTS_TENANT_REQUIRED" envDefault:"false"`
+}
+
+// TracingConfig encapsulates the configuration of the tracing of the requests
+// handled by the service. The variables are named as those of the OpenTelemetry
+// SDKs, where possible.
+type TracingConfig struct {
+	// Exporter selects where the spans are exported. It is
+	// either "otlp" or "none". The trace context is propagated
+	// even if the spans are not exported.
+	Exporter string `env:"OTEL_TRACES_EXPORTER" envDefault:"none"`
+
+	// Endpoint is the base url of the OpenTelemetry collector,
+	// to which the spans are exported using OTLP over http.
+	Endpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" envDefault:"http://localhost:4318"`
+
+	// ServiceName identifies the service in the exported spans.
+	ServiceName string `env:"OTEL_SERVICE_NAME" envDefault:"events-service"`
+
+	// ExportInterval is the interval at which the spans are
+	// exported.
+	ExportInterval time.Duration `env:"EVENTS_TRACES_EXPORT_INTERVAL" envDefault:"5s"`
+}
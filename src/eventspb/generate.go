@@ -0,0 +1,6 @@
+// Package eventspb holds the protobuf messages and the gRPC service of the
+// events service. The code is generated from events.proto using protoc with
+// the protoc-gen-go and protoc-gen-go-grpc plugins.
+package eventspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative events.proto
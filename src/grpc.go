@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/eventscompass/events-service/src/eventspb"
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// GRPC implements the [service.CloudService] interface.
+func (s *EventsService) GRPC() *grpc.Server {
+	return s.grpcServer
+}
+
+// initGRPC initializes the grpc server part of the service. This function
+// creates a server and registers with that server the events service. The
+// clients are authenticated, and the requests are scoped to their tenant, in the
+// same way as those of the rest api.
+func (s *EventsService) initGRPC() {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.auth.unaryInterceptor, s.tenants.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.auth.streamInterceptor, s.tenants.streamInterceptor),
+	)
+	eventspb.RegisterEventsServiceServer(srv, &grpcHandler{h: s.newHandler()})
+	s.grpcServer = srv
+}
+
+// grpcHandler handles grpc requests. It is the bridge between the grpc api and
+// the business logic, which it shares with the rest api.
+type grpcHandler struct {
+	eventspb.UnimplementedEventsServiceServer
+
+	h *restHandler
+}
+
+// GetEvent implements the [eventspb.EventsServiceServer] interface.
+func (g *grpcHandler) GetEvent(
+	ctx context.Context,
+	req *eventspb.GetEventRequest,
+) (*eventspb.Event, error) {
+	internal.Logger(ctx).Info("grpc request to read event", slog.String("id", req.GetId()))
+	event, err := findEvent(ctx, g.h.eventsDB, req.GetId())
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	if err := g.h.fillLocations(ctx, &event); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return toProtoEvent(&event), nil
+}
+
+// ListEvents implements the [eventspb.EventsServiceServer] interface.
+func (g *grpcHandler) ListEvents(
+	req *eventspb.ListEventsRequest,
+	stream eventspb.EventsService_ListEventsServer,
+) error {
+	ctx := stream.Context()
+
+	// If only location references are stored, then the events cannot be
+	// filtered by the data of their location.
+	filter, err := fromProtoFilter(req)
+	if err != nil {
+		return grpcError(ctx, err)
+	}
+	if g.h.locationRefs && filter.NeedsLocationData() {
+		return grpcError(ctx, fmt.Errorf(
+			"%w: filtering by country or capacity is not supported", service.ErrBadRequest))
+	}
+
+	// The locations are cached while streaming, so that each of them is
+	// retrieved only once.
+	internal.Logger(ctx).Info("grpc request to read events", slog.Any("filter", filter))
+	locations := make(map[string]internal.Location)
+	err = g.h.eventsDB.ForEachEvent(ctx, filter, func(e *internal.Event) error {
+		if err := g.h.fillLocationsFrom(ctx, locations, e); err != nil {
+			return err
+		}
+		return stream.Send(toProtoEvent(e)) //nolint:wrapcheck // status errors are returned as is
+	})
+	if err != nil {
+		return grpcError(ctx, err)
+	}
+	return nil
+}
+
+// CreateEvent implements the [eventspb.EventsServiceServer] interface.
+func (g *grpcHandler) CreateEvent(
+	ctx context.Context,
+	req *eventspb.CreateEventRequest,
+) (*eventspb.Event, error) {
+	if req.GetEvent() == nil {
+		return nil, grpcError(ctx, fmt.Errorf("%w: missing event", service.ErrBadRequest))
+	}
+	event := fromProtoEvent(req.GetEvent())
+	if event.ID == "" {
+		event.ID = internal.NewID()
+	}
+
+	internal.Logger(ctx).Info("grpc request to create event", slog.Any("event", event))
+	if err := g.h.createEvent(ctx, &event, req.GetAllowOverlap()); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	internal.Logger(ctx).Info("event successfully created")
+	return toProtoEvent(&event), nil
+}
+
+// UpdateEvent implements the [eventspb.EventsServiceServer] interface.
+func (g *grpcHandler) UpdateEvent(
+	ctx context.Context,
+	req *eventspb.UpdateEventRequest,
+) (*eventspb.Event, error) {
+	if req.GetEvent().GetId() == "" {
+		return nil, grpcError(ctx, fmt.Errorf("%w: missing event id", service.ErrBadRequest))
+	}
+	event := fromProtoEvent(req.GetEvent())
+	allowOverlap := req.GetAllowOverlap()
+
+	// Only the name, the dates and the hall of an occurrence can be changed.
+	if _, _, ok := internal.ParseOccurrenceID(event.ID); ok {
+		internal.Logger(ctx).Info("grpc request to replace occurrence", slog.Any("event", event))
+		occurrence, err := g.h.changeOccurrence(ctx, event.ID, req.GetThisAndFollowing(),
+			allowOverlap, func(o *internal.Event) error { return replaceFields(o, &event) })
+		if err != nil {
+			return nil, grpcError(ctx, err)
+		}
+		internal.Logger(ctx).Info("occurrence successfully replaced")
+		return toProtoEvent(&occurrence), nil
+	}
+
+	// The fields that the message does not carry are merged from the stored
+	// event, within the transaction that replaces it.
+	internal.Logger(ctx).Info("grpc request to replace event", slog.Any("event", event))
+	err := g.h.transact(ctx, func(ctx context.Context) error {
+		stored, err := findEvent(ctx, g.h.eventsDB, event.ID)
+		if err != nil {
+			return err
+		}
+		mergeStored(&event, &stored)
+		return g.h.replaceEvent(ctx, event.ID, &event, allowOverlap)
+	})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	internal.Logger(ctx).Info("event successfully replaced")
+	return toProtoEvent(&event), nil
+}
+
+// DeleteEvent implements the [eventspb.EventsServiceServer] interface.
+func (g *grpcHandler) DeleteEvent(
+	ctx context.Context,
+	req *eventspb.DeleteEventRequest,
+) (*emptypb.Empty, error) {
+	id := req.GetId()
+	var err error
+	if _, _, ok := internal.ParseOccurrenceID(id); ok {
+		internal.Logger(ctx).Info("grpc request to delete occurrence", slog.String("id", id))
+		err = g.h.transact(ctx, func(ctx context.Context) error {
+			return g.h.removeOccurrence(ctx, id, req.GetThisAndFollowing())
+		})
+	} else {
+		internal.Logger(ctx).Info("grpc request to delete event", slog.String("id", id))
+		err = g.h.deleteEvent(ctx, id)
+	}
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	internal.Logger(ctx).Info("event successfully deleted")
+	return &emptypb.Empty{}, nil
+}
+
+// grpcError maps the provided error to a grpc status error, in the same way as
+// [service.HTTPError] maps errors to http status codes. Validation errors carry
+// the fields that failed validation as [errdetails.BadRequest] details. Status
+// errors are returned as is. The error is logged with the logger of the context.
+func grpcError(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := grpcCode(err)
+	logger := internal.Logger(ctx)
+	if code == codes.Internal {
+		logger.Error("unexpected error while handling request", slog.String("error", err.Error()))
+	} else {
+		logger.Info("client request failed", slog.String("error", err.Error()))
+	}
+
+	st := status.New(code, err.Error())
+	var vErr *internal.ValidationError
+	if errors.As(err, &vErr) {
+		details := &errdetails.BadRequest{}
+		for _, f := range vErr.Fields {
+			details.FieldViolations = append(details.FieldViolations,
+				&errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+		}
+		if withDetails, err := st.WithDetails(details); err == nil {
+			st = withDetails
+		}
+	}
+	return st.Err() //nolint:wrapcheck // status errors are returned as is
+}
+
+// grpcCode returns the grpc status code corresponding to the provided error.
+func grpcCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, service.ErrTimeOut):
+		return codes.DeadlineExceeded
+	case errors.Is(err, service.ErrBadRequest):
+		return codes.InvalidArgument
+	case errors.Is(err, service.ErrSpaceFull):
+		return codes.ResourceExhausted
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return codes.Unauthenticated
+	case errors.Is(err, service.ErrNotAllowed):
+		return codes.PermissionDenied
+	case errors.Is(err, service.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, service.ErrAlreadyExists):
+		return codes.AlreadyExists
+	default:
+		return codes.Internal
+	}
+}
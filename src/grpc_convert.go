@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/eventscompass/events-service/src/eventspb"
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// toProtoEvent converts an event into its protobuf message.
+func toProtoEvent(e *internal.Event) *eventspb.Event {
+	pb := &eventspb.Event{
+		Id:        e.ID,
+		Name:      e.Name,
+		StartDate: toTimestamp(e.StartDate),
+		EndDate:   toTimestamp(e.EndDate),
+		Location:  toProtoLocation(&e.Location),
+		Hall:      e.Hall,
+		SeriesId:  e.SeriesID,
+	}
+	if e.RecurrenceID != nil {
+		pb.RecurrenceId = toTimestamp(*e.RecurrenceID)
+	}
+	if r := e.Recurrence; r != nil {
+		pb.Recurrence = &eventspb.Recurrence{Rrule: r.RRule}
+		for _, t := range r.ExDates {
+			pb.Recurrence.Exdates = append(pb.Recurrence.Exdates, toTimestamp(t))
+		}
+		for _, x := range r.Exceptions {
+			pb.Recurrence.Exceptions = append(pb.Recurrence.Exceptions, &eventspb.Exception{
+				RecurrenceId:  toTimestamp(x.RecurrenceID),
+				ThisAndFuture: x.ThisAndFuture,
+				Name:          x.Name,
+				StartDate:     toTimestamp(x.StartDate),
+				EndDate:       toTimestamp(x.EndDate),
+				Hall:          x.Hall,
+			})
+		}
+	}
+	return pb
+}
+
+// toProtoLocation converts a location into its protobuf message.
+func toProtoLocation(l *internal.Location) *eventspb.Location {
+	pb := &eventspb.Location{
+		Id:        l.ID,
+		Name:      l.Name,
+		Address:   l.Address,
+		Country:   l.Country,
+		OpenTime:  toTimestamp(l.OpenTime),
+		CloseTime: toTimestamp(l.CloseTime),
+	}
+	for _, h := range l.Halls {
+		pb.Halls = append(pb.Halls, &eventspb.Hall{Name: h.Name, Capacity: int32(h.Capacity)})
+	}
+	return pb
+}
+
+// fromProtoEvent converts the protobuf message of an event into an event. Only
+// the id of the location is converted, since the location data is filled in
+// from the stored location.
+func fromProtoEvent(pb *eventspb.Event) internal.Event {
+	e := internal.Event{
+		ID:        pb.GetId(),
+		Name:      pb.GetName(),
+		StartDate: fromTimestamp(pb.GetStartDate()),
+		EndDate:   fromTimestamp(pb.GetEndDate()),
+		Location:  internal.Location{ID: pb.GetLocation().GetId()},
+		Hall:      pb.GetHall(),
+		SeriesID:  pb.GetSeriesId(),
+	}
+	if pb.GetRecurrenceId() != nil {
+		t := fromTimestamp(pb.GetRecurrenceId())
+		e.RecurrenceID = &t
+	}
+	if r := pb.GetRecurrence(); r != nil {
+		e.Recurrence = &internal.Recurrence{RRule: r.GetRrule()}
+		for _, t := range r.GetExdates() {
+			e.Recurrence.ExDates = append(e.Recurrence.ExDates, fromTimestamp(t))
+		}
+		for _, x := range r.GetExceptions() {
+			e.Recurrence.Exceptions = append(e.Recurrence.Exceptions, internal.Exception{
+				RecurrenceID:  fromTimestamp(x.GetRecurrenceId()),
+				ThisAndFuture: x.GetThisAndFuture(),
+				Name:          x.GetName(),
+				StartDate:     fromTimestamp(x.GetStartDate()),
+				EndDate:       fromTimestamp(x.GetEndDate()),
+				Hall:          x.GetHall(),
+			})
+		}
+	}
+	return e
+}
+
+// mergeStored fills in the fields of an event converted from its protobuf
+// message that the message does not carry, from the stored version of the
+// event. The duration is kept if the stored event has one, and follows the
+// dates of the event. The time zone of the recurrence is kept if the event
+// still recurs. Note that the organizer is kept when the event is replaced, see
+// [restHandler.replaceEvent].
+func mergeStored(e, stored *internal.Event) {
+	if stored.Duration != 0 {
+		e.Duration = e.EndDate.Sub(e.StartDate)
+	}
+	if e.Recurrence != nil && stored.Recurrence != nil {
+		e.Recurrence.TZID = stored.Recurrence.TZID
+	}
+}
+
+// fromProtoFilter converts a request listing events into a filter. This
+// function returns [service.ErrBadRequest] if the minimum capacity is
+// negative.
+func fromProtoFilter(req *eventspb.ListEventsRequest) (*internal.Filter, error) {
+	if req.GetMinCapacity() < 0 {
+		return nil, fmt.Errorf(
+			"%w: min_capacity must be a non-negative integer", service.ErrBadRequest)
+	}
+	return &internal.Filter{
+		StartsAfter: fromTimestamp(req.GetStartsAfter()),
+		EndsBefore:  fromTimestamp(req.GetEndsBefore()),
+		Country:     req.GetCountry(),
+		LocationID:  req.GetLocationId(),
+		Hall:        req.GetHall(),
+		MinCapacity: int(req.GetMinCapacity()),
+	}, nil
+}
+
+// toTimestamp converts a time into a timestamp. The zero time is converted
+// into a nil timestamp, i.e. an unset field.
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// fromTimestamp converts a timestamp into a time. A nil timestamp is converted
+// into the zero time.
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
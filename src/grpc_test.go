@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/eventscompass/events-service/src/eventspb"
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/events-service/src/internal/memory"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// newTestHandler creates a handler backed by a memory container, which holds
+// the location "l1" with the halls "A" and "B". The handler applies the
+// default policy, and the returned context is scoped to the default tenant.
+func newTestHandler(t *testing.T, locationRefs bool) (*restHandler, context.Context) {
+	t.Helper()
+	db := memory.NewContainer()
+	policy, err := auth.LoadPolicy("")
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	relay := newOutboxRelay(db, nil, time.Second, time.Second)
+	h := &restHandler{
+		eventsDB:     db,
+		relay:        relay,
+		locationRefs: locationRefs,
+		policy:       policy,
+		announcer:    &announcer{db: db, relay: relay, horizon: 90 * 24 * time.Hour},
+	}
+	// The requests are made by an admin, who may change every resource.
+	ctx := internal.WithTenant(context.Background(), internal.DefaultTenant)
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{
+		Subject: "root", Method: auth.MethodJWT, Roles: []string{auth.RoleAdmin},
+	})
+	location := internal.Location{
+		ID:      "l1",
+		Name:    "Arena",
+		Address: "1 Main Street",
+		Country: "NL",
+		Halls:   []internal.Hall{{Name: "A", Capacity: 100}, {Name: "B", Capacity: 200}},
+	}
+	if err := db.Create(ctx, internal.LocationsCollection, location); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return h, ctx
+}
+
+// testEvent returns an event taking place in the given hall of the location
+// "l1", starting at the given hour of 2030-01-01 and lasting two hours.
+func testEvent(id, hall string, hour int) internal.Event {
+	start := time.Date(2030, time.January, 1, hour, 0, 0, 0, time.UTC)
+	return internal.Event{
+		ID:        id,
+		Name:      "Event " + id,
+		StartDate: start,
+		EndDate:   start.Add(2 * time.Hour),
+		Location:  internal.Location{ID: "l1"},
+		Hall:      hall,
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{context.Canceled, codes.Canceled},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{service.ErrTimeOut, codes.DeadlineExceeded},
+		{service.ErrBadRequest, codes.InvalidArgument},
+		{&internal.ValidationError{}, codes.InvalidArgument},
+		{service.ErrSpaceFull, codes.ResourceExhausted},
+		{auth.ErrUnauthenticated, codes.Unauthenticated},
+		{service.ErrNotAllowed, codes.PermissionDenied},
+		{service.ErrNotFound, codes.NotFound},
+		{service.ErrAlreadyExists, codes.AlreadyExists},
+		{&internal.ConflictError{IDs: []string{"e1"}}, codes.AlreadyExists},
+		{errors.New("boom"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := grpcCode(tt.err); got != tt.want {
+			t.Errorf("grpcCode(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	ctx := context.Background()
+
+	// Validation errors carry the fields that failed validation.
+	err := grpcError(ctx, &internal.ValidationError{Fields: []internal.FieldError{
+		{Field: "name", Message: "must not be empty"},
+	}})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Errorf("grpcError() code = %v, want %v", st.Code(), codes.InvalidArgument)
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("grpcError() details = %v, want one", details)
+	}
+	br, ok := details[0].(*errdetails.BadRequest)
+	if !ok || len(br.GetFieldViolations()) != 1 || br.GetFieldViolations()[0].GetField() != "name" {
+		t.Errorf("grpcError() details = %v, want a violation of name", details)
+	}
+
+	// Status errors are returned as is.
+	want := status.Error(codes.Unavailable, "down")
+	if got := grpcError(ctx, want); !errors.Is(got, want) {
+		t.Errorf("grpcError() = %v, want %v", got, want)
+	}
+
+	// Other errors carry only their code and message.
+	st = status.Convert(grpcError(ctx, fmt.Errorf("%w: no such event", service.ErrNotFound)))
+	if st.Code() != codes.NotFound || len(st.Details()) != 0 {
+		t.Errorf("grpcError() = %v, want %v without details", st, codes.NotFound)
+	}
+}
+
+func TestProtoEventRoundTrip(t *testing.T) {
+	start := time.Date(2030, time.January, 1, 18, 0, 0, 0, time.UTC)
+	recurrenceID := start.Add(7 * 24 * time.Hour)
+	e := internal.Event{
+		ID:        "e1",
+		Name:      "Jazz Night",
+		StartDate: start,
+		EndDate:   start.Add(2 * time.Hour),
+		Location:  internal.Location{ID: "l1"},
+		Hall:      "A",
+		Recurrence: &internal.Recurrence{
+			RRule:   "FREQ=WEEKLY;COUNT=4",
+			ExDates: []time.Time{start.Add(14 * 24 * time.Hour)},
+			Exceptions: []internal.Exception{{
+				RecurrenceID:  recurrenceID,
+				ThisAndFuture: true,
+				Name:          "Jazz Night (moved)",
+				StartDate:     recurrenceID.Add(time.Hour),
+				EndDate:       recurrenceID.Add(3 * time.Hour),
+				Hall:          "B",
+			}},
+		},
+	}
+	if got := fromProtoEvent(toProtoEvent(&e)); !reflect.DeepEqual(got, e) {
+		t.Errorf("fromProtoEvent(toProtoEvent()) = %+v, want %+v", got, e)
+	}
+
+	// Occurrences carry their series and original start date.
+	o := internal.Event{ID: internal.OccurrenceID("e1", recurrenceID), SeriesID: "e1",
+		RecurrenceID: &recurrenceID, StartDate: recurrenceID, EndDate: recurrenceID.Add(time.Hour)}
+	if got := fromProtoEvent(toProtoEvent(&o)); !reflect.DeepEqual(got, o) {
+		t.Errorf("fromProtoEvent(toProtoEvent()) = %+v, want %+v", got, o)
+	}
+}
+
+func TestToProtoLocation(t *testing.T) {
+	l := internal.Location{
+		ID:      "l1",
+		Name:    "Arena",
+		Address: "1 Main Street",
+		Country: "NL",
+		Halls:   []internal.Hall{{Name: "A", Location: "l1", Capacity: 100}},
+	}
+	pb := toProtoLocation(&l)
+	if pb.GetId() != "l1" || pb.GetName() != "Arena" || pb.GetCountry() != "NL" ||
+		len(pb.GetHalls()) != 1 || pb.GetHalls()[0].GetCapacity() != 100 {
+		t.Errorf("toProtoLocation() = %v", pb)
+	}
+
+	// The zero opening times are left unset.
+	if pb.GetOpenTime() != nil || pb.GetCloseTime() != nil {
+		t.Errorf("toProtoLocation() times = %v, %v, want unset",
+			pb.GetOpenTime(), pb.GetCloseTime())
+	}
+	if got := fromTimestamp(toTimestamp(time.Time{})); !got.IsZero() {
+		t.Errorf("fromTimestamp(toTimestamp(zero)) = %v, want zero", got)
+	}
+}
+
+func TestFromProtoFilter(t *testing.T) {
+	start := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	f, err := fromProtoFilter(&eventspb.ListEventsRequest{
+		StartsAfter: toTimestamp(start),
+		Country:     "NL",
+		LocationId:  "l1",
+		Hall:        "A",
+		MinCapacity: 50,
+	})
+	if err != nil {
+		t.Fatalf("fromProtoFilter() error = %v", err)
+	}
+	want := internal.Filter{
+		StartsAfter: start, Country: "NL", LocationID: "l1", Hall: "A", MinCapacity: 50,
+	}
+	if !reflect.DeepEqual(*f, want) {
+		t.Errorf("fromProtoFilter() = %+v, want %+v", *f, want)
+	}
+
+	_, err = fromProtoFilter(&eventspb.ListEventsRequest{MinCapacity: -1})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Errorf("fromProtoFilter() error = %v, want %v", err, service.ErrBadRequest)
+	}
+}
+
+func TestUpdateEventKeepsDuration(t *testing.T) {
+	h, ctx := newTestHandler(t, false)
+	g := &grpcHandler{h: h}
+	event := testEvent("e1", "A", 18)
+	event.Duration = 2 * time.Hour
+	if err := h.createEvent(ctx, &event, false); err != nil {
+		t.Fatalf("createEvent() error = %v", err)
+	}
+
+	// The message carries no duration, which follows the new dates.
+	pb := toProtoEvent(&event)
+	pb.EndDate = toTimestamp(event.StartDate.Add(3 * time.Hour))
+	if _, err := g.UpdateEvent(ctx, &eventspb.UpdateEventRequest{Event: pb}); err != nil {
+		t.Fatalf("UpdateEvent() error = %v", err)
+	}
+	stored, err := findEvent(ctx, h.eventsDB, "e1")
+	if err != nil {
+		t.Fatalf("findEvent() error = %v", err)
+	}
+	if stored.Duration != 3*time.Hour {
+		t.Errorf("UpdateEvent() stored duration %v, want %v", stored.Duration, 3*time.Hour)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+)
+
+// readinessTimeout is the maximum time spent on checking the dependencies of
+// the service.
+const readinessTimeout = 2 * time.Second
+
+const (
+	// statusUp and statusDown report whether the service or one
+	// of its dependencies is healthy.
+	statusUp   = "up"
+	statusDown = "down"
+)
+
+// health is the response body of the health endpoints.
+type health struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]dependencyHealth `json:"dependencies,omitempty"`
+}
+
+// dependencyHealth reports the health of a dependency of the service, and how
+// long it took to check it.
+type dependencyHealth struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// livez reports that the service is alive, i.e. that it is able to serve
+// requests. The dependencies are not checked, so that the service is not
+// restarted while one of them is down.
+func (s *EventsService) livez(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, &health{Status: statusUp})
+}
+
+// readyz reports whether the service is ready to serve requests, i.e. whether
+// its database and message bus can be reached. The dependencies are checked
+// concurrently. If any of them is down, then the response is 503, so that no
+// traffic is routed to the service.
+func (s *EventsService) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"database":    s.eventsDB.Ping,
+		"message_bus": s.eventsBus.Ping,
+	}
+	res := health{Status: statusUp, Dependencies: make(map[string]dependencyHealth)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		name, check := name, check
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := checkDependency(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			res.Dependencies[name] = d
+			if d.Status != statusUp {
+				internal.Logger(ctx).Warn("dependency is down",
+					slog.String("dependency", name),
+					slog.String("error", d.Error),
+				)
+				res.Status = statusDown
+			}
+		}()
+	}
+	wg.Wait()
+	writeHealth(w, &res)
+}
+
+// checkDependency runs the check of a dependency and measures its latency.
+func checkDependency(ctx context.Context, check func(context.Context) error) dependencyHealth {
+	start := time.Now()
+	err := check(ctx)
+	d := dependencyHealth{
+		Status:    statusUp,
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		d.Status, d.Error = statusDown, err.Error()
+	}
+	return d
+}
+
+// writeHealth encodes the health as the JSON body of the response. The status
+// code is 503 if the service is down.
+func writeHealth(w http.ResponseWriter, h *health) {
+	w.Header().Set("Content-Type", "application/json; charset=utf8")
+	w.Header().Set("Cache-Control", "no-store")
+	if h.Status != statusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(h); err != nil {
+		slog.Info("failed to write response", slog.String("error", err.Error()))
+	}
+}
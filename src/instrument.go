@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/tracing"
+)
+
+// instrumentedContainer records the metrics of the operations of the wrapped
+// container, and traces the operations with spans. The operations that take a
+// callback, i.e. WithTransaction and ForEachEvent, are timed including the
+// callback.
+type instrumentedContainer struct {
+	internal.EventsContainer
+	metrics *serviceMetrics
+	tracer  *tracing.Tracer
+}
+
+// start starts the span of an operation on the given collection, which is
+// empty if the operation does not target a collection. The span is started
+// only within a trace, e.g. that of a request, since spans of operations that
+// run in the background, such as polling the outbox, are noise. The returned
+// function ends the span and records the metrics of the operation. It is meant
+// to be deferred with a pointer to the named error result.
+func (c *instrumentedContainer) start(
+	ctx context.Context,
+	operation string,
+	collection string,
+) (context.Context, func(*error)) {
+	begin := time.Now()
+	var span *tracing.Span
+	if tracing.SpanContextFromContext(ctx).IsValid() {
+		attrs := []tracing.Attribute{tracing.String("db.operation", operation)}
+		if collection != "" {
+			attrs = append(attrs, tracing.String("db.collection", collection))
+		}
+		ctx, span = c.tracer.Start(ctx, "container."+operation, tracing.SpanKindClient, attrs...)
+	}
+	return ctx, func(err *error) {
+		c.metrics.observe(operation, begin, *err)
+		if span != nil {
+			span.SetError(*err)
+			span.End()
+		}
+	}
+}
+
+// Create implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) Create(
+	ctx context.Context,
+	collection string,
+	data any,
+) (err error) {
+	ctx, end := c.start(ctx, "create", collection)
+	defer end(&err)
+	return c.EventsContainer.Create(ctx, collection, data) //nolint:wrapcheck // intentional
+}
+
+// GetByID implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) GetByID(
+	ctx context.Context,
+	collection, id string,
+) (_ any, err error) {
+	ctx, end := c.start(ctx, "get_by_id", collection)
+	defer end(&err)
+	return c.EventsContainer.GetByID(ctx, collection, id) //nolint:wrapcheck // intentional
+}
+
+// GetByName implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) GetByName(
+	ctx context.Context,
+	collection, name string,
+) (_ any, err error) {
+	ctx, end := c.start(ctx, "get_by_name", collection)
+	defer end(&err)
+	return c.EventsContainer.GetByName(ctx, collection, name) //nolint:wrapcheck // intentional
+}
+
+// GetAll implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) GetAll(
+	ctx context.Context,
+	collection string,
+) (_ []any, err error) {
+	ctx, end := c.start(ctx, "get_all", collection)
+	defer end(&err)
+	return c.EventsContainer.GetAll(ctx, collection) //nolint:wrapcheck // intentional
+}
+
+// Replace implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) Replace(
+	ctx context.Context,
+	collection, id string,
+	data any,
+) (err error) {
+	ctx, end := c.start(ctx, "replace", collection)
+	defer end(&err)
+	return c.EventsContainer.Replace(ctx, collection, id, data) //nolint:wrapcheck // intentional
+}
+
+// Update implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) Update(
+	ctx context.Context,
+	collection, id string,
+	patch []byte,
+) (_ any, err error) {
+	ctx, end := c.start(ctx, "update", collection)
+	defer end(&err)
+	return c.EventsContainer.Update(ctx, collection, id, patch) //nolint:wrapcheck // intentional
+}
+
+// Delete implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) Delete(ctx context.Context, collection, id string) (err error) {
+	ctx, end := c.start(ctx, "delete", collection)
+	defer end(&err)
+	return c.EventsContainer.Delete(ctx, collection, id) //nolint:wrapcheck // intentional
+}
+
+// CountEvents implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) CountEvents(
+	ctx context.Context,
+	filter *internal.Filter,
+) (_ int, err error) {
+	ctx, end := c.start(ctx, "count_events", internal.EventsCollection)
+	defer end(&err)
+	return c.EventsContainer.CountEvents(ctx, filter) //nolint:wrapcheck // intentional
+}
+
+// QueryEvents implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) QueryEvents(
+	ctx context.Context,
+	q *internal.Query,
+) (_ *internal.Page, err error) {
+	ctx, end := c.start(ctx, "query_events", internal.EventsCollection)
+	defer end(&err)
+	return c.EventsContainer.QueryEvents(ctx, q) //nolint:wrapcheck // intentional
+}
+
+// ForEachEvent implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) ForEachEvent(
+	ctx context.Context,
+	filter *internal.Filter,
+	fn func(*internal.Event) error,
+) (err error) {
+	ctx, end := c.start(ctx, "for_each_event", internal.EventsCollection)
+	defer end(&err)
+	return c.EventsContainer.ForEachEvent(ctx, filter, fn) //nolint:wrapcheck // intentional
+}
+
+// Ping implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) Ping(ctx context.Context) (err error) {
+	ctx, end := c.start(ctx, "ping", "")
+	defer end(&err)
+	return c.EventsContainer.Ping(ctx) //nolint:wrapcheck // intentional
+}
+
+// SearchEvents implements the [internal.Searcher] interface.
+func (c *instrumentedContainer) SearchEvents(
+	ctx context.Context,
+	query string,
+	limit int,
+) (_ []internal.SearchHit, err error) {
+	ctx, end := c.start(ctx, "search_events", internal.EventsCollection)
+	defer end(&err)
+	return c.EventsContainer.SearchEvents(ctx, query, limit) //nolint:wrapcheck // intentional
+}
+
+// WithTransaction implements the [internal.Outbox] interface.
+func (c *instrumentedContainer) WithTransaction(
+	ctx context.Context,
+	fn func(context.Context) error,
+) (err error) {
+	ctx, end := c.start(ctx, "with_transaction", "")
+	defer end(&err)
+	return c.EventsContainer.WithTransaction(ctx, fn) //nolint:wrapcheck // intentional
+}
+
+// PendingOutbox implements the [internal.Outbox] interface.
+func (c *instrumentedContainer) PendingOutbox(
+	ctx context.Context,
+	limit int,
+) (_ []internal.OutboxRecord, err error) {
+	ctx, end := c.start(ctx, "pending_outbox", internal.OutboxCollection)
+	defer end(&err)
+	return c.EventsContainer.PendingOutbox(ctx, limit) //nolint:wrapcheck // intentional
+}
+
+// CountBookings implements the [internal.Bookings] interface.
+func (c *instrumentedContainer) CountBookings(
+	ctx context.Context,
+	eventIDs ...string,
+) (_ map[string]int, err error) {
+	ctx, end := c.start(ctx, "count_bookings", internal.BookingsCollection)
+	defer end(&err)
+	return c.EventsContainer.CountBookings(ctx, eventIDs...) //nolint:wrapcheck // intentional
+}
+
+// Tenants implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) Tenants(
+	ctx context.Context,
+	collection string,
+) (_ []string, err error) {
+	ctx, end := c.start(ctx, "tenants", collection)
+	defer end(&err)
+	return c.EventsContainer.Tenants(ctx, collection) //nolint:wrapcheck // intentional
+}
+
+// CountAll implements the [internal.EventsContainer] interface.
+func (c *instrumentedContainer) CountAll(
+	ctx context.Context,
+	collection string,
+) (_ int, err error) {
+	ctx, end := c.start(ctx, "count_all", collection)
+	defer end(&err)
+	return c.EventsContainer.CountAll(ctx, collection) //nolint:wrapcheck // intentional
+}
+
+// instrumentedBus counts the messages published to the wrapped message bus,
+// and traces every publish with a span. The span context is passed on to the
+// wrapped bus, which propagates it with the message.
+type instrumentedBus struct {
+	messageBus
+	metrics *serviceMetrics
+	tracer  *tracing.Tracer
+}
+
+// Publish implements the [service.MessageBus] interface.
+func (b *instrumentedBus) Publish(ctx context.Context, topic string, msg []byte) error {
+	ctx, span := b.tracer.Start(ctx, topic+" publish", tracing.SpanKindProducer,
+		tracing.String("messaging.operation", "publish"),
+		tracing.String("messaging.destination.name", topic),
+	)
+	defer span.End()
+	if err := b.messageBus.Publish(ctx, topic, msg); err != nil {
+		b.metrics.publishes.Inc(topic, "failure")
+		span.SetError(err)
+		return err //nolint:wrapcheck // intentional
+	}
+	b.metrics.publishes.Inc(topic, "success")
+	return nil
+}
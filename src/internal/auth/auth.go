@@ -0,0 +1,189 @@
+// Package auth authenticates the clients of the service. Clients authenticate
+// either with a JSON Web Token, see https://www.rfc-editor.org/rfc/rfc7519,
+// which is verified with locally configured keys, or with a static API key.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// ErrUnauthenticated is returned when the client did not send credentials, or
+// sent credentials that are not valid. It is mapped to the 401 http status.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// minSecretLength is the minimum length of the secret used to verify tokens
+// signed with HMAC. Shorter secrets can be brute forced.
+const minSecretLength = 32
+
+// Methods by which a client authenticates.
+const (
+	MethodJWT        = "jwt"
+	MethodAPIKey     = "api_key"
+	MethodAdminToken = "admin_token"
+)
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// Subject identifies the client. It is the "sub" claim of
+	// the token, or the name of the API key.
+	Subject string
+
+	// Method is the method by which the client authenticated,
+	// one of [MethodJWT], [MethodAPIKey] or [MethodAdminToken].
+	Method string
+
+	// Roles are the roles granted to the client. See [Policy].
+	Roles []string
+
+	// Claims are the claims of the token. They are not set if
+	// the client authenticated with an API key.
+	Claims map[string]any
+}
+
+// Owner returns the identity under which the client owns the resources that it
+// creates. The identity is the method followed by the subject, e.g.
+// "jwt:alice", so that a token and an API key with the same subject do not own
+// the same resources. It is empty if the client has no subject.
+func (p *Principal) Owner() string {
+	if p == nil || p.Subject == "" {
+		return ""
+	}
+	return p.Method + ":" + p.Subject
+}
+
+// principalKey is the context key of the principal.
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context that holds the given principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal held by the given context. The
+// second return value is false if the client of the request is anonymous.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Config encapsulates the configuration of the [Authenticator].
+type Config struct {
+	// JWKSFile is the path of a file holding a JSON Web Key Set,
+	// whose public keys verify the signatures of the tokens.
+	JWKSFile string
+
+	// JWTSecret is the secret that verifies the signatures of the
+	// tokens signed with HMAC.
+	JWTSecret string
+
+	// Issuer and Audience are the expected "iss" and "aud"
+	// claims of the tokens. They are not checked if empty.
+	Issuer   string
+	Audience string
+
+	// APIKeys are the static API keys, given as "name:key". The
+	// name identifies the client of the key.
+	APIKeys []string
+}
+
+// Authenticator authenticates the clients of the service.
+type Authenticator struct {
+	jwt *jwtVerifier
+
+	// apiKeys maps the SHA-256 digest of every API key to its
+	// name. Only the digests are kept in memory.
+	apiKeys map[[sha256.Size]byte]string
+}
+
+// NewAuthenticator creates a new [Authenticator] instance with the keys and
+// secrets given in the configuration.
+func NewAuthenticator(cfg *Config) (*Authenticator, error) {
+	keys, err := loadKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a := &Authenticator{apiKeys: make(map[[sha256.Size]byte]string, len(cfg.APIKeys))}
+	if len(keys) > 0 {
+		a.jwt = &jwtVerifier{
+			keys:     keys,
+			issuer:   cfg.Issuer,
+			audience: cfg.Audience,
+			now:      time.Now,
+		}
+	}
+	for _, entry := range cfg.APIKeys {
+		name, key, ok := strings.Cut(entry, ":")
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf(
+				"%w: api keys must be given as name:key", service.ErrUnexpected)
+		}
+		a.apiKeys[sha256.Sum256([]byte(key))] = name
+	}
+	return a, nil
+}
+
+// loadKeys loads the keys that verify the signatures of the tokens, i.e. the
+// keys of the key set file and the secret.
+func loadKeys(cfg *Config) ([]*verificationKey, error) {
+	var keys []*verificationKey
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: read jwks file: %v", service.ErrUnexpected, err)
+		}
+		if keys, err = parseJWKS(data); err != nil {
+			return nil, fmt.Errorf("parse jwks file: %w", err)
+		}
+	}
+	if cfg.JWTSecret != "" {
+		if len(cfg.JWTSecret) < minSecretLength {
+			return nil, fmt.Errorf("%w: the jwt secret must be at least %d bytes long",
+				service.ErrUnexpected, minSecretLength)
+		}
+		keys = append(keys, &verificationKey{key: []byte(cfg.JWTSecret)})
+	}
+	return keys, nil
+}
+
+// Enabled returns true if any keys or secrets are configured. Otherwise, no
+// client can authenticate.
+func (a *Authenticator) Enabled() bool {
+	return a.jwt != nil || len(a.apiKeys) > 0
+}
+
+// AuthenticateToken verifies the given JSON Web Token and returns the client
+// identified by it. This function returns [ErrUnauthenticated] if the token is
+// not valid.
+func (a *Authenticator) AuthenticateToken(token string) (*Principal, error) {
+	if a.jwt == nil {
+		return nil, fmt.Errorf("%w: tokens are not accepted", ErrUnauthenticated)
+	}
+	claims, err := a.jwt.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Subject: claims.Subject, Method: MethodJWT, Claims: claims.all}, nil
+}
+
+// AuthenticateAPIKey returns the client identified by the given API key. This
+// function returns [ErrUnauthenticated] if the key is not known.
+func (a *Authenticator) AuthenticateAPIKey(key string) (*Principal, error) {
+	// The digests are compared, so that the time of the lookup
+	// does not depend on how much of the key is correct.
+	digest := sha256.Sum256([]byte(key))
+	for d, name := range a.apiKeys {
+		if subtle.ConstantTimeCompare(d[:], digest[:]) == 1 {
+			return &Principal{Subject: name, Method: MethodAPIKey}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown api key", ErrUnauthenticated)
+}
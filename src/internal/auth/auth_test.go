@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// testKeys are the private keys that sign the tokens of the tests.
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{rsa: rsaKey, ec: ecKey, ed25519: edKey}
+}
+
+// writeJWKS writes the public keys to a key set file and returns its path.
+func (k *testKeys) writeJWKS(t *testing.T) string {
+	t.Helper()
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	set := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "rsa",
+			"n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": b64(k.ec.X.FillBytes(make([]byte, 32))),
+			"y": b64(k.ec.Y.FillBytes(make([]byte, 32))),
+		},
+		{
+			"kty": "OKP", "kid": "ed25519", "crv": "Ed25519",
+			"x": b64(k.ed25519.Public().(ed25519.PublicKey)),
+		},
+		{"kty": "RSA", "kid": "enc", "use": "enc"},
+	}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign creates a token with the given header and claims.
+func (k *testKeys) sign(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	input := enc(header) + "." + enc(claims)
+	digest := func(h crypto.Hash) []byte {
+		hh := h.New()
+		hh.Write([]byte(input))
+		return hh.Sum(nil)
+	}
+
+	var sig []byte
+	var err error
+	switch header["alg"] {
+	case "HS256":
+		mac := hmac.New(crypto.SHA256.New, []byte(testSecret))
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest(crypto.SHA256))
+	case "PS384":
+		sig, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA384, digest(crypto.SHA384),
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest(crypto.SHA256))
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "EdDSA":
+		sig = ed25519.Sign(k.ed25519, []byte(input))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	keys := newTestKeys(t)
+	a, err := NewAuthenticator(&Config{
+		JWKSFile:  keys.writeJWKS(t),
+		JWTSecret: testSecret,
+		Issuer:    "https://issuer.example.com",
+		Audience:  "events-service",
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	a.jwt.now = func() time.Time { return now }
+
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub":  "alice",
+			"iss":  "https://issuer.example.com",
+			"aud":  []string{"other", "events-service"},
+			"exp":  now.Add(time.Hour).Unix(),
+			"role": "organizer",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		header  map[string]any
+		claims  map[string]any
+		wantErr bool
+	}{
+		{"hs256", map[string]any{"alg": "HS256"}, claims(nil), false},
+		{"rs256", map[string]any{"alg": "RS256", "kid": "rsa"}, claims(nil), false},
+		{"ps384 without kid", map[string]any{"alg": "PS384"}, claims(nil), false},
+		{"es256", map[string]any{"alg": "ES256", "kid": "ec"}, claims(nil), false},
+		{"eddsa", map[string]any{"alg": "EdDSA", "kid": "ed25519"}, claims(nil), false},
+		{"string audience", map[string]any{"alg": "HS256"},
+			claims(map[string]any{"aud": "events-service"}), false},
+		{"expired within leeway", map[string]any{"alg": "HS256"},
+			claims(map[string]any{"exp": now.Add(-leeway / 2).Unix()}), false},
+
+		{"wrong kid", map[string]any{"alg": "RS256", "kid": "ec"}, claims(nil), true},
+		{"none", map[string]any{"alg": "none"}, claims(nil), true},
+		{"expired", map[string]any{"alg": "HS256"},
+			claims(map[string]any{"exp": now.Add(-time.Hour).Unix()}), true},
+		{"not yet valid", map[string]any{"alg": "HS256"},
+			claims(map[string]any{"nbf": now.Add(time.Hour).Unix()}), true},
+		{"no expiry", map[string]any{"alg": "HS256"}, claims(map[string]any{"exp": nil}), true},
+		{"no subject", map[string]any{"alg": "HS256"}, claims(map[string]any{"sub": nil}), true},
+		{"wrong issuer", map[string]any{"alg": "HS256"},
+			claims(map[string]any{"iss": "https://evil.example.com"}), true},
+		{"wrong audience", map[string]any{"alg": "HS256"},
+			claims(map[string]any{"aud": "other"}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := keys.sign(t, tt.header, tt.claims)
+			p, err := a.AuthenticateToken(token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnauthenticated) {
+					t.Fatalf("AuthenticateToken() error = %v, want %v", err, ErrUnauthenticated)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AuthenticateToken() error = %v", err)
+			}
+			if p.Subject != "alice" || p.Method != MethodJWT || p.Claims["role"] != "organizer" {
+				t.Errorf("AuthenticateToken() = %+v", p)
+			}
+		})
+	}
+}
+
+func TestAuthenticateTokenTampered(t *testing.T) {
+	keys := newTestKeys(t)
+	a, err := NewAuthenticator(&Config{JWKSFile: keys.writeJWKS(t)})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	rs256, hs256 := map[string]any{"alg": "RS256"}, map[string]any{"alg": "HS256"}
+	token := keys.sign(t, rs256, map[string]any{"sub": "alice", "exp": exp})
+	forged := keys.sign(t, rs256, map[string]any{"sub": "admin", "exp": exp})
+
+	// Swap the payloads of the tokens.
+	parts, forgedParts := strings.Split(token, "."), strings.Split(forged, ".")
+	tampered := parts[0] + "." + forgedParts[1] + "." + parts[2]
+	for _, tok := range []string{tampered, "not-a-token", token + "x"} {
+		if _, err := a.AuthenticateToken(tok); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("AuthenticateToken(%q) error = %v, want %v", tok, err, ErrUnauthenticated)
+		}
+	}
+
+	// Tokens signed with HMAC are rejected, if no secret is
+	// configured.
+	hs := keys.sign(t, hs256, map[string]any{"sub": "alice", "exp": exp})
+	if _, err := a.AuthenticateToken(hs); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("AuthenticateToken() error = %v, want %v", err, ErrUnauthenticated)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	a, err := NewAuthenticator(&Config{
+		APIKeys: []string{"importer:k3y", "billing:s3cret:with:colons"},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	if !a.Enabled() {
+		t.Error("Enabled() = false, want true")
+	}
+	for key, want := range map[string]string{"k3y": "importer", "s3cret:with:colons": "billing"} {
+		p, err := a.AuthenticateAPIKey(key)
+		if err != nil {
+			t.Fatalf("AuthenticateAPIKey(%q) error = %v", key, err)
+		}
+		if p.Subject != want || p.Method != MethodAPIKey {
+			t.Errorf("AuthenticateAPIKey(%q) = %+v, want subject %q", key, p, want)
+		}
+	}
+	if _, err := a.AuthenticateAPIKey("k3"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("AuthenticateAPIKey() error = %v, want %v", err, ErrUnauthenticated)
+	}
+	if _, err := a.AuthenticateToken("a.b.c"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("AuthenticateToken() error = %v, want %v", err, ErrUnauthenticated)
+	}
+}
+
+func TestPrincipalOwner(t *testing.T) {
+	tests := []struct {
+		principal *Principal
+		want      string
+	}{
+		{nil, ""},
+		{&Principal{Method: MethodJWT}, ""},
+		{&Principal{Subject: "alice", Method: MethodJWT}, "jwt:alice"},
+		{&Principal{Subject: "alice", Method: MethodAPIKey}, "api_key:alice"},
+		{&Principal{Subject: RoleAdmin, Method: MethodAdminToken}, "admin_token:admin"},
+	}
+	for _, tt := range tests {
+		if got := tt.principal.Owner(); got != tt.want {
+			t.Errorf("Owner(%+v) = %q, want %q", tt.principal, got, tt.want)
+		}
+	}
+}
+
+func TestNewAuthenticatorInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}]}`),
+		0o600); err != nil {
+		t.Fatal(err)
+	}
+	configs := map[string]*Config{
+		"short secret":    {JWTSecret: "short"},
+		"missing file":    {JWKSFile: filepath.Join(t.TempDir(), "missing.json")},
+		"invalid key":     {JWKSFile: path},
+		"malformed key":   {APIKeys: []string{"no-name"}},
+		"empty key value": {APIKeys: []string{"name:"}},
+	}
+	for name, cfg := range configs {
+		if _, err := NewAuthenticator(cfg); err == nil {
+			t.Errorf("%s: NewAuthenticator() error = nil", name)
+		}
+	}
+
+	a, err := NewAuthenticator(&Config{})
+	if err != nil || a.Enabled() {
+		t.Errorf("NewAuthenticator() = %v, %v, want disabled", a.Enabled(), err)
+	}
+}
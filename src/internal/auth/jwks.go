@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// jsonWebKey is a key of a JSON Web Key Set, see
+// https://www.rfc-editor.org/rfc/rfc7517. Only the parameters of public and
+// symmetric keys are decoded.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// Parameters of RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// Parameters of EC and OKP keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// Parameters of symmetric keys.
+	K string `json:"k"`
+}
+
+// ecCurves are the supported curves of EC keys. The ecdh curves are used to
+// check that the points are on the curve.
+var ecCurves = map[string]struct {
+	curve elliptic.Curve
+	ecdh  ecdh.Curve
+}{
+	"P-256": {elliptic.P256(), ecdh.P256()},
+	"P-384": {elliptic.P384(), ecdh.P384()},
+	"P-521": {elliptic.P521(), ecdh.P521()},
+}
+
+// parseJWKS parses the keys of a JSON Web Key Set. Keys that are meant for
+// encryption are skipped. This function returns [service.ErrUnexpected] if a
+// key cannot be parsed, since the set is part of the configuration.
+func parseJWKS(data []byte) ([]*verificationKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: decode key set: %v", service.ErrUnexpected, err)
+	}
+	keys := make([]*verificationKey, 0, len(set.Keys))
+	for i := range set.Keys {
+		jwk := &set.Keys[i]
+		if jwk.Use == "enc" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %d (%q): %v", service.ErrUnexpected, i, jwk.Kid, err)
+		}
+		keys = append(keys, &verificationKey{id: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: the key set has no signing keys", service.ErrUnexpected)
+	}
+	return keys, nil
+}
+
+// parseJWK parses the public or symmetric key of the JSON Web Key.
+func parseJWK(jwk *jsonWebKey) (any, error) {
+	switch jwk.Kty {
+	case "RSA":
+		return parseRSAKey(jwk)
+	case "EC":
+		return parseECKey(jwk)
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported okp key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(k) < minSecretLength {
+			return nil, fmt.Errorf("invalid or too short symmetric key")
+		}
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// parseRSAKey parses the public key of an RSA JSON Web Key.
+func parseRSAKey(jwk *jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("decode n: %w", err)
+	}
+	e, err := decodeBigInt(jwk.E)
+	if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid e")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+// parseECKey parses the public key of an EC JSON Web Key.
+func parseECKey(jwk *jsonWebKey) (*ecdsa.PublicKey, error) {
+	c, ok := ecCurves[jwk.Crv]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+	size := (c.curve.Params().BitSize + 7) / 8 //nolint:gomnd // bits to bytes
+	if errX != nil || errY != nil || len(x) != size || len(y) != size {
+		return nil, fmt.Errorf("invalid point")
+	}
+
+	// Check that the point is on the curve, using its uncompressed
+	// encoding.
+	point := append([]byte{4}, append(x, y...)...) //nolint:gomnd // uncompressed point
+	if _, err := c.ecdh.NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("invalid point: %w", err)
+	}
+	return &ecdsa.PublicKey{
+		Curve: c.curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer.
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by the caller
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
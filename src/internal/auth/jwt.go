@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers SHA-256
+	_ "crypto/sha512" // registers SHA-384 and SHA-512
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// leeway is the tolerated clock skew between the service and the issuer of
+// the tokens.
+const leeway = time.Minute
+
+// algorithm verifies the signatures of one of the JWS algorithms, see
+// https://www.rfc-editor.org/rfc/rfc7518#section-3.1.
+type algorithm struct {
+	hash   crypto.Hash
+	verify func(key any, hash crypto.Hash, input, sig []byte) bool
+}
+
+// algorithms are the supported JWS algorithms. The "none" algorithm is not
+// supported on purpose.
+var algorithms = map[string]algorithm{
+	"HS256": {crypto.SHA256, verifyHMAC},
+	"HS384": {crypto.SHA384, verifyHMAC},
+	"HS512": {crypto.SHA512, verifyHMAC},
+	"RS256": {crypto.SHA256, verifyPKCS1v15},
+	"RS384": {crypto.SHA384, verifyPKCS1v15},
+	"RS512": {crypto.SHA512, verifyPKCS1v15},
+	"PS256": {crypto.SHA256, verifyPSS},
+	"PS384": {crypto.SHA384, verifyPSS},
+	"PS512": {crypto.SHA512, verifyPSS},
+	"ES256": {crypto.SHA256, verifyECDSA},
+	"ES384": {crypto.SHA384, verifyECDSA},
+	"ES512": {crypto.SHA512, verifyECDSA},
+	"EdDSA": {0, verifyEd25519},
+}
+
+// verificationKey is a key that verifies the signatures of tokens.
+type verificationKey struct {
+	// id is the "kid" of the key. Tokens that name a key are
+	// verified only with that key.
+	id string
+
+	// alg is the algorithm the key is restricted to. Any
+	// algorithm matching the type of the key is accepted if
+	// empty.
+	alg string
+
+	// key is either a []byte secret, an *rsa.PublicKey, an
+	// *ecdsa.PublicKey or an ed25519.PublicKey. The type of the
+	// key is checked by the algorithm, so that a public key can
+	// never be used as an HMAC secret.
+	key any
+}
+
+// jwtVerifier verifies JSON Web Tokens.
+type jwtVerifier struct {
+	keys     []*verificationKey
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+// jwtClaims are the claims of a verified token.
+type jwtClaims struct {
+	Subject   string    `json:"sub"`
+	Issuer    string    `json:"iss"`
+	Audience  audience  `json:"aud"`
+	ExpiresAt *jsonTime `json:"exp"`
+	NotBefore *jsonTime `json:"nbf"`
+
+	// all holds all the claims, including those above.
+	all map[string]any
+}
+
+// verify verifies the signature and the claims of the given token, and
+// returns its claims. This function returns [ErrUnauthenticated] if the token
+// is not valid.
+func (v *jwtVerifier) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 { //nolint:gomnd // header, payload and signature
+		return nil, fmt.Errorf("%w: malformed token", ErrUnauthenticated)
+	}
+
+	// Decode the header and verify the signature.
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: decode header: %v", ErrUnauthenticated, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decode signature: %v", ErrUnauthenticated, err)
+	}
+	if err := v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	// Decode and check the claims.
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: decode claims: %v", ErrUnauthenticated, err)
+	}
+	if err := decodeSegment(parts[1], &claims.all); err != nil {
+		return nil, fmt.Errorf("%w: decode claims: %v", ErrUnauthenticated, err)
+	}
+	if err := v.checkClaims(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// verifySignature verifies the signature of the token with the keys that
+// match the given algorithm and key id.
+func (v *jwtVerifier) verifySignature(alg, kid string, input string, sig []byte) error {
+	a, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrUnauthenticated, alg)
+	}
+	for _, k := range v.keys {
+		if (kid != "" && k.id != "" && k.id != kid) || (k.alg != "" && k.alg != alg) {
+			continue
+		}
+		if a.verify(k.key, a.hash, []byte(input), sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: invalid signature", ErrUnauthenticated)
+}
+
+// checkClaims checks the registered claims of the token. The token must
+// expire and identify its subject.
+func (v *jwtVerifier) checkClaims(c *jwtClaims) error {
+	now := v.now()
+	switch {
+	case c.ExpiresAt == nil:
+		return fmt.Errorf("%w: token does not expire", ErrUnauthenticated)
+	case now.After(c.ExpiresAt.Add(leeway)):
+		return fmt.Errorf("%w: token expired", ErrUnauthenticated)
+	case c.NotBefore != nil && now.Add(leeway).Before(c.NotBefore.Time):
+		return fmt.Errorf("%w: token is not valid yet", ErrUnauthenticated)
+	case c.Subject == "":
+		return fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	case v.issuer != "" && c.Issuer != v.issuer:
+		return fmt.Errorf("%w: unexpected issuer %q", ErrUnauthenticated, c.Issuer)
+	case v.audience != "" && !c.Audience.contains(v.audience):
+		return fmt.Errorf("%w: token is meant for another audience", ErrUnauthenticated)
+	default:
+		return nil
+	}
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a token into v.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err //nolint:wrapcheck // wrapped by the caller
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v) //nolint:wrapcheck // wrapped by the caller
+}
+
+// audience is the "aud" claim, which is either a string or an array of
+// strings.
+type audience []string
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a)) //nolint:wrapcheck // wrapped by the caller
+}
+
+// contains returns true if the audience contains the given value.
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTime is a time claim, which is given as seconds since the epoch.
+type jsonTime struct {
+	time.Time
+}
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface.
+func (t *jsonTime) UnmarshalJSON(data []byte) error {
+	var secs json.Number
+	if err := json.Unmarshal(data, &secs); err != nil {
+		return err //nolint:wrapcheck // wrapped by the caller
+	}
+	f, err := secs.Float64()
+	if err != nil {
+		return err //nolint:wrapcheck // wrapped by the caller
+	}
+	t.Time = time.Unix(0, int64(f*float64(time.Second)))
+	return nil
+}
+
+// verifyHMAC verifies an HMAC signature.
+func verifyHMAC(key any, hash crypto.Hash, input, sig []byte) bool {
+	secret, ok := key.([]byte)
+	if !ok {
+		return false
+	}
+	mac := hmac.New(hash.New, secret)
+	mac.Write(input)
+	return hmac.Equal(mac.Sum(nil), sig)
+}
+
+// verifyPKCS1v15 verifies an RSASSA-PKCS1-v1_5 signature.
+func verifyPKCS1v15(key any, hash crypto.Hash, input, sig []byte) bool {
+	pub, ok := key.(*rsa.PublicKey)
+	return ok && rsa.VerifyPKCS1v15(pub, hash, digest(hash, input), sig) == nil
+}
+
+// verifyPSS verifies an RSASSA-PSS signature.
+func verifyPSS(key any, hash crypto.Hash, input, sig []byte) bool {
+	pub, ok := key.(*rsa.PublicKey)
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+	return ok && rsa.VerifyPSS(pub, hash, digest(hash, input), sig, opts) == nil
+}
+
+// ecdsaCurveBits are the sizes of the curves of the ECDSA algorithms, keyed by
+// their hash.
+var ecdsaCurveBits = map[crypto.Hash]int{
+	crypto.SHA256: 256,
+	crypto.SHA384: 384,
+	crypto.SHA512: 521,
+}
+
+// verifyECDSA verifies an ECDSA signature, which is the concatenation of the
+// fixed size R and S values.
+func verifyECDSA(key any, hash crypto.Hash, input, sig []byte) bool {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok || pub.Curve.Params().BitSize != ecdsaCurveBits[hash] {
+		return false
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8 //nolint:gomnd // bits to bytes
+	if len(sig) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	return ecdsa.Verify(pub, digest(hash, input), r, s)
+}
+
+// verifyEd25519 verifies an Ed25519 signature.
+func verifyEd25519(key any, _ crypto.Hash, input, sig []byte) bool {
+	pub, ok := key.(ed25519.PublicKey)
+	return ok && ed25519.Verify(pub, input, sig)
+}
+
+// digest returns the digest of the input.
+func digest(hash crypto.Hash, input []byte) []byte {
+	h := hash.New()
+	h.Write(input)
+	return h.Sum(nil)
+}
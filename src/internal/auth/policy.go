@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"bytes"
+	_ "embed" // embeds the default policy
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// Actions that the clients perform on the resources of the service.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+
+	// ActionAllowOverlap is the action of storing an event that
+	// overlaps with other events in the same hall.
+	ActionAllowOverlap = "allow_overlap"
+)
+
+// Resources of the service. The halls of a location are part of the location
+// resource.
+const (
+	ResourceEvent    = "event"
+	ResourceLocation = "location"
+)
+
+const (
+	// RoleAdmin is the role granted to the clients that send the admin
+	// token.
+	RoleAdmin = "admin"
+
+	// anyRole is the role of every client, including anonymous ones.
+	anyRole = "*"
+
+	// wildcard matches any action or resource in a rule.
+	wildcard = "*"
+
+	// defaultRolesClaim is the claim of the tokens that lists the
+	// roles of the client, if the policy does not name another one.
+	defaultRolesClaim = "roles"
+)
+
+// defaultPolicy is the policy used if no policy file is configured. It lets
+// organizers change their events and venue managers their locations, and
+// admins do anything. Other clients may not change any resource.
+//
+//go:embed default_policy.json
+var defaultPolicy []byte
+
+// Rule grants a role to perform some actions on some resources. If Owner is
+// set, then the rule grants the actions only on the resources owned by the
+// client.
+type Rule struct {
+	Actions   []string `json:"actions"`
+	Resources []string `json:"resources"`
+	Owner     bool     `json:"owner"`
+}
+
+// Policy decides which client may perform which action on which resource. The
+// clients are granted roles, and each role is granted the actions of its rules.
+// A client may perform an action if any of its roles is granted the action.
+type Policy struct {
+	// RolesClaim is the claim of the tokens that lists the roles
+	// of the client, either as a string or an array of strings.
+	RolesClaim string `json:"roles_claim"`
+
+	// APIKeys maps the name of every API key to the roles of the
+	// clients that use it.
+	APIKeys map[string][]string `json:"api_keys"`
+
+	// Roles maps every role to its rules. The rules of the "*"
+	// role apply to every client, including anonymous ones.
+	Roles map[string][]Rule `json:"roles"`
+}
+
+// LoadPolicy loads the policy from the JSON file at the given path. The default
+// policy is loaded if the path is empty.
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return ParsePolicy(defaultPolicy)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read policy file: %v", service.ErrUnexpected, err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses and validates a JSON encoded policy. Unknown fields,
+// actions and resources are rejected, so that typos do not go unnoticed. This
+// function returns [service.ErrUnexpected] if the policy is not valid, since
+// it is part of the configuration.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%w: decode policy: %v", service.ErrUnexpected, err)
+	}
+	if p.RolesClaim == "" {
+		p.RolesClaim = defaultRolesClaim
+	}
+	actions := []string{wildcard, ActionCreate, ActionUpdate, ActionDelete, ActionAllowOverlap}
+	resources := []string{wildcard, ResourceEvent, ResourceLocation}
+	for role, rules := range p.Roles {
+		for i, r := range rules {
+			if err := checkNames(r.Actions, actions); err != nil {
+				return nil, fmt.Errorf("%w: role %q: rule %d: actions: %v",
+					service.ErrUnexpected, role, i, err)
+			}
+			if err := checkNames(r.Resources, resources); err != nil {
+				return nil, fmt.Errorf("%w: role %q: rule %d: resources: %v",
+					service.ErrUnexpected, role, i, err)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// checkNames makes sure that the names are not empty and all of them are
+// known.
+func checkNames(names, known []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("must not be empty")
+	}
+	for _, n := range names {
+		if !slices.Contains(known, n) {
+			return fmt.Errorf("unknown name %q", n)
+		}
+	}
+	return nil
+}
+
+// ResolveRoles adds to the roles of the principal the roles listed in the
+// roles claim of its token, or those of its API key.
+func (p *Policy) ResolveRoles(principal *Principal) {
+	switch principal.Method {
+	case MethodJWT:
+		switch v := principal.Claims[p.RolesClaim].(type) {
+		case string:
+			principal.Roles = append(principal.Roles, v)
+		case []any:
+			for _, role := range v {
+				if s, ok := role.(string); ok {
+					principal.Roles = append(principal.Roles, s)
+				}
+			}
+		}
+	case MethodAPIKey:
+		principal.Roles = append(principal.Roles, p.APIKeys[principal.Subject]...)
+	}
+}
+
+// Authorize makes sure that the client may perform the action on the resource
+// owned by the given owner, as returned by [Principal.Owner]. The client is
+// anonymous if the principal is nil. The owner of a resource that is being
+// created is the client creating it.
+// This function returns [service.ErrNotAllowed] if the client may not perform
+// the action.
+func (p *Policy) Authorize(principal *Principal, action, resource, owner string) error {
+	isOwner := owner != "" && principal.Owner() == owner
+	if p.permits(principal, action, resource, func(r *Rule) bool { return !r.Owner || isOwner }) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s may not perform %q on the %s", service.ErrNotAllowed,
+		describe(principal), action, resource)
+}
+
+// Permits returns true if the client may perform the action on at least some
+// resources of the given kind, e.g. on those that it owns. It is meant for
+// rejecting requests early, before the resource is known.
+func (p *Policy) Permits(principal *Principal, action, resource string) bool {
+	return p.permits(principal, action, resource, func(*Rule) bool { return true })
+}
+
+// permits returns true if a rule of the roles of the principal grants the
+// action on the resource, and satisfies the given condition.
+func (p *Policy) permits(
+	principal *Principal,
+	action, resource string,
+	cond func(*Rule) bool,
+) bool {
+	roles := []string{anyRole}
+	if principal != nil {
+		roles = append(roles, principal.Roles...)
+	}
+	for _, role := range roles {
+		for i := range p.Roles[role] {
+			r := &p.Roles[role][i]
+			if matches(r.Actions, action) && matches(r.Resources, resource) && cond(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matches returns true if the names contain the given name or the wildcard.
+func matches(names []string, name string) bool {
+	return slices.Contains(names, name) || slices.Contains(names, wildcard)
+}
+
+// describe describes the client in error messages.
+func describe(principal *Principal) string {
+	if principal == nil {
+		return "anonymous clients"
+	}
+	return fmt.Sprintf("%q", principal.Subject)
+}
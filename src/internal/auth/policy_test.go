@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+const testPolicy = `{
+  "roles_claim": "groups",
+  "api_keys": {"importer": ["organizer"]},
+  "roles": {
+    "*": [{"actions": ["create"], "resources": ["event"]}],
+    "organizer": [{"actions": ["update", "delete"], "resources": ["event"], "owner": true}],
+    "venue_manager": [{"actions": ["*"], "resources": ["location"], "owner": true}],
+    "admin": [{"actions": ["*"], "resources": ["*"]}]
+  }
+}`
+
+func TestParsePolicyInvalid(t *testing.T) {
+	policies := map[string]string{
+		"malformed":        `{"roles":`,
+		"unknown field":    `{"rules": {}}`,
+		"unknown action":   `{"roles": {"a": [{"actions": ["publish"], "resources": ["event"]}]}}`,
+		"unknown resource": `{"roles": {"a": [{"actions": ["create"], "resources": ["hall"]}]}}`,
+		"no actions":       `{"roles": {"a": [{"resources": ["event"]}]}}`,
+	}
+	for name, data := range policies {
+		if _, err := ParsePolicy([]byte(data)); !errors.Is(err, service.ErrUnexpected) {
+			t.Errorf("%s: ParsePolicy() error = %v, want %v", name, err, service.ErrUnexpected)
+		}
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(testPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	if p.RolesClaim != "groups" {
+		t.Errorf("RolesClaim = %q, want %q", p.RolesClaim, "groups")
+	}
+	if _, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadPolicy() error = nil")
+	}
+}
+
+func TestResolveRoles(t *testing.T) {
+	p, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		principal *Principal
+		want      []string
+	}{
+		{"string claim", &Principal{Method: MethodJWT,
+			Claims: map[string]any{"groups": "admin"}}, []string{"admin"}},
+		{"array claim", &Principal{Method: MethodJWT,
+			Claims: map[string]any{"groups": []any{"organizer", 1, "venue_manager"}}},
+			[]string{"organizer", "venue_manager"}},
+		{"other claim", &Principal{Method: MethodJWT,
+			Claims: map[string]any{"roles": "admin"}}, nil},
+		{"api key", &Principal{Subject: "importer", Method: MethodAPIKey}, []string{"organizer"}},
+		{"unknown api key", &Principal{Subject: "billing", Method: MethodAPIKey}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.ResolveRoles(tt.principal)
+			if !slices.Equal(tt.principal.Roles, tt.want) {
+				t.Errorf("ResolveRoles() roles = %v, want %v", tt.principal.Roles, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	p, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := &Principal{Subject: "alice", Method: MethodJWT, Roles: []string{"organizer"}}
+	aliceKey := &Principal{Subject: "alice", Method: MethodAPIKey, Roles: []string{"organizer"}}
+	bob := &Principal{Subject: "bob", Method: MethodJWT, Roles: []string{"venue_manager"}}
+	admin := &Principal{Subject: "root", Method: MethodJWT, Roles: []string{RoleAdmin}}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		action    string
+		resource  string
+		owner     string
+		want      bool
+	}{
+		{"anyone creates events", nil, ActionCreate, ResourceEvent, "", true},
+		{"anonymous updates", nil, ActionUpdate, ResourceEvent, "", false},
+		{"organizer updates own", alice, ActionUpdate, ResourceEvent, "jwt:alice", true},
+		{"organizer updates other", alice, ActionUpdate, ResourceEvent, "jwt:bob", false},
+		{"organizer updates unowned", alice, ActionUpdate, ResourceEvent, "", false},
+		{"organizer updates bare subject", alice, ActionUpdate, ResourceEvent, "alice", false},
+		{"api key updates token's", aliceKey, ActionUpdate, ResourceEvent, "jwt:alice", false},
+		{"api key updates own", aliceKey, ActionUpdate, ResourceEvent, "api_key:alice", true},
+		{"organizer allows overlap", alice, ActionAllowOverlap, ResourceEvent, "jwt:alice", false},
+		{"organizer creates location", alice, ActionCreate, ResourceLocation, "jwt:alice", false},
+		{"manager deletes own", bob, ActionDelete, ResourceLocation, "jwt:bob", true},
+		{"manager deletes other", bob, ActionDelete, ResourceLocation, "jwt:alice", false},
+		{"admin allows overlap", admin, ActionAllowOverlap, ResourceEvent, "jwt:alice", true},
+		{"admin deletes location", admin, ActionDelete, ResourceLocation, "jwt:bob", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Authorize(tt.principal, tt.action, tt.resource, tt.owner)
+			if tt.want && err != nil {
+				t.Errorf("Authorize() error = %v", err)
+			}
+			if !tt.want && !errors.Is(err, service.ErrNotAllowed) {
+				t.Errorf("Authorize() error = %v, want %v", err, service.ErrNotAllowed)
+			}
+		})
+	}
+
+	// The coarse check ignores the ownership of the resources.
+	if !p.Permits(alice, ActionUpdate, ResourceEvent) {
+		t.Error("Permits() = false, want true")
+	}
+	if p.Permits(nil, ActionDelete, ResourceEvent) {
+		t.Error("Permits() = true, want false")
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	p, err := LoadPolicy("")
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	alice := &Principal{Subject: "alice", Method: MethodJWT, Roles: []string{"organizer"}}
+	bob := &Principal{Subject: "bob", Method: MethodJWT, Roles: []string{"venue_manager"}}
+	carol := &Principal{Subject: "carol", Method: MethodJWT}
+	admin := &Principal{Subject: RoleAdmin, Roles: []string{RoleAdmin}}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		action    string
+		resource  string
+		owner     string
+		want      bool
+	}{
+		{"anonymous creates", nil, ActionCreate, ResourceEvent, "", false},
+		{"anonymous deletes", nil, ActionDelete, ResourceLocation, "jwt:bob", false},
+		{"no roles creates", carol, ActionCreate, ResourceEvent, "jwt:carol", false},
+		{"organizer creates", alice, ActionCreate, ResourceEvent, "jwt:alice", true},
+		{"organizer updates own", alice, ActionUpdate, ResourceEvent, "jwt:alice", true},
+		{"organizer updates other", alice, ActionUpdate, ResourceEvent, "jwt:bob", false},
+		{"organizer deletes other", alice, ActionDelete, ResourceEvent, "jwt:bob", false},
+		{"organizer allows overlap", alice, ActionAllowOverlap, ResourceEvent, "jwt:alice", false},
+		{"organizer creates location", alice, ActionCreate, ResourceLocation, "jwt:alice", false},
+		{"manager creates location", bob, ActionCreate, ResourceLocation, "jwt:bob", true},
+		{"manager updates own", bob, ActionUpdate, ResourceLocation, "jwt:bob", true},
+		{"manager updates other", bob, ActionUpdate, ResourceLocation, "jwt:alice", false},
+		{"manager creates event", bob, ActionCreate, ResourceEvent, "jwt:bob", false},
+		{"admin allows overlap", admin, ActionAllowOverlap, ResourceEvent, "", true},
+		{"admin deletes location", admin, ActionDelete, ResourceLocation, "jwt:bob", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Authorize(tt.principal, tt.action, tt.resource, tt.owner)
+			if tt.want && err != nil {
+				t.Errorf("Authorize() error = %v", err)
+			}
+			if !tt.want && !errors.Is(err, service.ErrNotAllowed) {
+				t.Errorf("Authorize() error = %v, want %v", err, service.ErrNotAllowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// BookingsCollection is the name of the collection where the bookings of the
+// events will be stored.
+var BookingsCollection = "bookings"
+
+// Booking represents a seat booked by a user for an event. The bookings are
+// made by the bookings service, which announces them on the message bus. A
+// user books at most one seat for an event.
+type Booking struct {
+	// ID identifies the booking, see [BookingID].
+	ID string `json:"id"`
+
+	EventID  string    `json:"event_id"`
+	UserID   string    `json:"user_id"`
+	BookedAt time.Time `json:"booked_at"`
+}
+
+// BookingID returns the id of the booking of the given user for the given
+// event. Since the id is derived from the event and the user, recording the
+// same booking twice, e.g. because the message announcing it was redelivered,
+// fails with [service.ErrAlreadyExists] instead of counting the booking twice.
+func BookingID(eventID, userID string) string {
+	return eventID + "/" + userID
+}
+
+// Bookings is implemented by containers that store the bookings of the events.
+type Bookings interface {
+
+	// CountBookings returns the number of bookings of each of
+	// the given events. Events without bookings are not included
+	// in the result.
+	CountBookings(_ context.Context, eventIDs ...string) (map[string]int, error)
+}
+
+// Attendance describes how many seats of an event are booked.
+type Attendance struct {
+	// Booked is the number of booked seats.
+	Booked int `json:"booked"`
+
+	// Remaining is the number of seats that can still be booked.
+	// It is not set if the capacity of the event is not known,
+	// i.e. if the event does not take place in a hall of its
+	// location.
+	Remaining *int `json:"remaining,omitempty"`
+
+	// SoldOut is set if no seats can be booked anymore.
+	SoldOut bool `json:"sold_out"`
+}
+
+// NewAttendance returns the attendance of the event, given the number of its
+// booked seats. The number of seats is the capacity of the hall where the event
+// takes place, thus the location data of the event must be filled in.
+func NewAttendance(e *Event, booked int) Attendance {
+	a := Attendance{Booked: booked}
+	if hall := e.Location.Hall(e.Hall); hall != nil {
+		remaining := max(hall.Capacity-booked, 0)
+		a.Remaining = &remaining
+		a.SoldOut = remaining == 0
+	}
+	return a
+}
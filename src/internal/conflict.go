@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// ConflictError is returned when an event overlaps with other events taking
+// place in the same hall of the same location. It lists the ids of the
+// conflicting events. The error wraps [service.ErrAlreadyExists].
+type ConflictError struct {
+	IDs []string
+}
+
+// Error implements the [error] interface.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: hall is occupied by events %s",
+		service.ErrAlreadyExists, strings.Join(e.IDs, ", "))
+}
+
+// Unwrap returns [service.ErrAlreadyExists], so that conflicts can be handled
+// as any other attempt to create an existing resource.
+func (e *ConflictError) Unwrap() error {
+	return service.ErrAlreadyExists
+}
@@ -0,0 +1,933 @@
+// Package containertest provides a conformance test suite for implementations
+// of the [internal.EventsContainer] interface. Every implementation should run
+// the suite against itself, so that the implementations are interchangeable:
+//
+//	func TestConformance(t *testing.T) {
+//		containertest.Run(t, func(t *testing.T) internal.EventsContainer {
+//			return NewContainer()
+//		})
+//	}
+package containertest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// testTenant is the tenant of the entries stored by the tests, unless a test
+// states otherwise.
+const testTenant = "acme"
+
+// NewContainer creates a new, empty container for a single test. The function
+// is responsible for releasing the container at the end of the test, e.g. by
+// registering a cleanup function with [testing.T.Cleanup].
+type NewContainer func(t *testing.T) EventsContainer
+
+// Run runs the conformance test suite. Every test is run against a new
+// container created by newContainer.
+func Run(t *testing.T, newContainer NewContainer) {
+	t.Helper()
+	tests := []struct {
+		name string
+		test func(*testing.T, EventsContainer)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateDuplicate", testCreateDuplicate},
+		{"GetMissing", testGetMissing},
+		{"GetAll", testGetAll},
+		{"UnknownCollection", testUnknownCollection},
+		{"Replace", testReplace},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"CountEvents", testCountEvents},
+		{"QueryEvents", testQueryEvents},
+		{"ForEachEvent", testForEachEvent},
+		{"SearchEvents", testSearchEvents},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"ConcurrentWrites", testConcurrentWrites},
+		{"CanceledContext", testCanceledContext},
+		{"TransactionCommit", testTransactionCommit},
+		{"TransactionRollback", testTransactionRollback},
+		{"PendingOutbox", testPendingOutbox},
+		{"CountBookings", testCountBookings},
+		{"Ping", testPing},
+		{"Tenants", testTenants},
+		{"TenantsOutbox", testTenantsOutbox},
+		{"NoTenant", testNoTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newContainer(t))
+		})
+	}
+}
+
+func testCreateAndGet(t *testing.T, c EventsContainer) {
+	ctx := background()
+	l := location("l1", "Arena")
+	e := event("e1", "Concert", l, 0)
+	mustCreate(t, c, LocationsCollection, l)
+	mustCreate(t, c, EventsCollection, e)
+
+	got, err := c.GetByID(ctx, EventsCollection, e.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	assertEqual(t, e, got)
+
+	got, err = c.GetByName(ctx, EventsCollection, e.Name)
+	if err != nil {
+		t.Fatalf("GetByName() error = %v", err)
+	}
+	assertEqual(t, e, got)
+
+	got, err = c.GetByID(ctx, LocationsCollection, l.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	assertEqual(t, l, got)
+}
+
+func testCreateDuplicate(t *testing.T, c EventsContainer) {
+	l := location("l1", "Arena")
+	mustCreate(t, c, LocationsCollection, l)
+
+	dup := location(l.ID, "Stadium")
+	err := c.Create(background(), LocationsCollection, dup)
+	assertError(t, "Create()", err, service.ErrAlreadyExists)
+}
+
+func testGetMissing(t *testing.T, c EventsContainer) {
+	ctx := background()
+	mustCreate(t, c, LocationsCollection, location("l1", "Arena"))
+
+	_, err := c.GetByID(ctx, LocationsCollection, "missing")
+	assertError(t, "GetByID()", err, service.ErrNotFound)
+	_, err = c.GetByName(ctx, LocationsCollection, "missing")
+	assertError(t, "GetByName()", err, service.ErrNotFound)
+	_, err = c.GetByID(ctx, EventsCollection, "l1")
+	assertError(t, "GetByID()", err, service.ErrNotFound)
+}
+
+func testGetAll(t *testing.T, c EventsContainer) {
+	ctx := background()
+	all, err := c.GetAll(ctx, LocationsCollection)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(all) != 0 {
+		t.Fatalf("GetAll() = %d entries, want 0", len(all))
+	}
+
+	want := map[string]Location{}
+	for i := 0; i < 3; i++ {
+		l := location(fmt.Sprintf("l%d", i), fmt.Sprintf("Arena %d", i))
+		mustCreate(t, c, LocationsCollection, l)
+		want[l.ID] = l
+	}
+
+	all, err = c.GetAll(ctx, LocationsCollection)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(all) != len(want) {
+		t.Fatalf("GetAll() = %d entries, want %d", len(all), len(want))
+	}
+	for _, elem := range all {
+		l, ok := elem.(Location)
+		if !ok {
+			t.Fatalf("GetAll() entry of type %T, want Location", elem)
+		}
+		assertEqual(t, want[l.ID], l)
+	}
+}
+
+func testUnknownCollection(t *testing.T, c EventsContainer) {
+	ctx := background()
+	const unknown = "unknown"
+	l := location("l1", "Arena")
+
+	err := c.Create(ctx, unknown, l)
+	assertError(t, "Create()", err, service.ErrNotAllowed)
+	_, err = c.GetByID(ctx, unknown, l.ID)
+	assertError(t, "GetByID()", err, service.ErrNotAllowed)
+	_, err = c.GetByName(ctx, unknown, l.Name)
+	assertError(t, "GetByName()", err, service.ErrNotAllowed)
+	_, err = c.GetAll(ctx, unknown)
+	assertError(t, "GetAll()", err, service.ErrNotAllowed)
+	err = c.Replace(ctx, unknown, l.ID, l)
+	assertError(t, "Replace()", err, service.ErrNotAllowed)
+	_, err = c.Update(ctx, unknown, l.ID, []byte(`{"name":"Stadium"}`))
+	assertError(t, "Update()", err, service.ErrNotAllowed)
+	err = c.Delete(ctx, unknown, l.ID)
+	assertError(t, "Delete()", err, service.ErrNotAllowed)
+	_, err = c.Tenants(ctx, unknown)
+	assertError(t, "Tenants()", err, service.ErrNotAllowed)
+	_, err = c.CountAll(ctx, unknown)
+	assertError(t, "CountAll()", err, service.ErrNotAllowed)
+}
+
+func testReplace(t *testing.T, c EventsContainer) {
+	ctx := background()
+	l := location("l1", "Arena")
+	mustCreate(t, c, LocationsCollection, l)
+
+	l.Name = "Stadium"
+	l.Halls = append(l.Halls, Hall{Name: "C", Capacity: 10})
+	if err := c.Replace(ctx, LocationsCollection, l.ID, l); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	got, err := c.GetByID(ctx, LocationsCollection, l.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	assertEqual(t, l, got)
+
+	err = c.Replace(ctx, LocationsCollection, "missing", location("missing", "Missing"))
+	assertError(t, "Replace()", err, service.ErrNotFound)
+	err = c.Replace(ctx, LocationsCollection, l.ID, location("l2", "Arena"))
+	assertError(t, "Replace()", err, service.ErrBadRequest)
+}
+
+func testUpdate(t *testing.T, c EventsContainer) {
+	ctx := background()
+	l := location("l1", "Arena")
+	mustCreate(t, c, LocationsCollection, l)
+
+	got, err := c.Update(ctx, LocationsCollection, l.ID, []byte(`{"name":"Stadium","address":null}`))
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	l.Name, l.Address = "Stadium", ""
+	assertEqual(t, l, got)
+
+	got, err = c.GetByID(ctx, LocationsCollection, l.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	assertEqual(t, l, got)
+
+	_, err = c.Update(ctx, LocationsCollection, "missing", []byte(`{"name":"Stadium"}`))
+	assertError(t, "Update()", err, service.ErrNotFound)
+	_, err = c.Update(ctx, LocationsCollection, l.ID, []byte(`{"id":"l2"}`))
+	assertError(t, "Update()", err, service.ErrBadRequest)
+	_, err = c.Update(ctx, LocationsCollection, l.ID, []byte(`{"name":`))
+	assertError(t, "Update()", err, service.ErrBadRequest)
+	_, err = c.Update(ctx, LocationsCollection, l.ID, []byte(`{"halls":"many"}`))
+	assertError(t, "Update()", err, service.ErrBadRequest)
+}
+
+func testDelete(t *testing.T, c EventsContainer) {
+	ctx := background()
+	l := location("l1", "Arena")
+	mustCreate(t, c, LocationsCollection, l)
+
+	if err := c.Delete(ctx, LocationsCollection, l.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	_, err := c.GetByID(ctx, LocationsCollection, l.ID)
+	assertError(t, "GetByID()", err, service.ErrNotFound)
+	err = c.Delete(ctx, LocationsCollection, l.ID)
+	assertError(t, "Delete()", err, service.ErrNotFound)
+
+	// The id can be reused after the entry was deleted.
+	mustCreate(t, c, LocationsCollection, l)
+}
+
+func testCountEvents(t *testing.T, c EventsContainer) {
+	l := location("l1", "Arena")
+	for i := 0; i < 4; i++ {
+		e := event(fmt.Sprintf("e%d", i), fmt.Sprintf("Concert %d", i), l, i)
+		if i%2 == 1 {
+			e.Hall = "B"
+		}
+		if i == 3 {
+			e.Recurrence = &Recurrence{RRule: "FREQ=DAILY;COUNT=2"}
+		}
+		mustCreate(t, c, EventsCollection, e)
+	}
+
+	tests := []struct {
+		filter Filter
+		want   int
+	}{
+		{Filter{}, 4},
+		{Filter{Hall: "B"}, 2},
+		{Filter{LocationID: "l1", Hall: "A"}, 2},
+		{Filter{LocationID: "l2"}, 0},
+		{Filter{StartsAfter: start(2)}, 2},
+		{Filter{StartsBefore: start(2)}, 2},
+		{Filter{StartsBefore: start(2).Add(time.Hour), EndsAfter: start(1).Add(time.Hour)}, 2},
+		{Filter{EndsBefore: start(2)}, 2},
+		{Filter{Country: "NL"}, 4},
+		{Filter{MinCapacity: 150}, 2},
+		{Filter{Recurring: true}, 1},
+		{Filter{Recurring: true, Hall: "A"}, 0},
+		{Filter{NonRecurring: true}, 3},
+		{Filter{NonRecurring: true, Hall: "B"}, 1},
+	}
+	for _, tt := range tests {
+		got, err := c.CountEvents(background(), &tt.filter)
+		if err != nil {
+			t.Fatalf("CountEvents(%+v) error = %v", tt.filter, err)
+		}
+		if got != tt.want {
+			t.Errorf("CountEvents(%+v) = %d, want %d", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func testQueryEvents(t *testing.T, c EventsContainer) {
+	ctx := background()
+	l := location("l1", "Arena")
+	names := []string{"Delta", "Alpha", "Charlie", "Bravo", "Echo"}
+	for i, name := range names {
+		mustCreate(t, c, EventsCollection, event(fmt.Sprintf("e%d", i), name, l, i))
+	}
+
+	tests := []struct {
+		sort Sort
+		want []string
+	}{
+		{SortByID, []string{"e0", "e1", "e2", "e3", "e4"}},
+		{SortByStartDate, []string{"e0", "e1", "e2", "e3", "e4"}},
+		{"-" + SortByStartDate, []string{"e4", "e3", "e2", "e1", "e0"}},
+		{SortByName, []string{"e1", "e3", "e2", "e0", "e4"}},
+	}
+	for _, tt := range tests {
+		// Page through the events two at a time.
+		var got []string
+		q := Query{Sort: tt.sort, Limit: 2}
+		for pages := 0; ; pages++ {
+			if pages > len(names) {
+				t.Fatalf("QueryEvents(%q) does not terminate", tt.sort)
+			}
+			page, err := c.QueryEvents(ctx, &q)
+			if err != nil {
+				t.Fatalf("QueryEvents(%q) error = %v", tt.sort, err)
+			}
+			for _, e := range page.Events {
+				got = append(got, e.ID)
+			}
+			if page.NextPageToken == "" {
+				break
+			}
+			q.PageToken = page.NextPageToken
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("QueryEvents(%q) = %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+
+	// Filter the events.
+	page, err := c.QueryEvents(ctx, &Query{Filter: Filter{StartsAfter: start(3)}, Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryEvents() error = %v", err)
+	}
+	if len(page.Events) != 2 || page.NextPageToken != "" {
+		t.Errorf("QueryEvents() = %d events, want 2 in a single page", len(page.Events))
+	}
+
+	// Invalid queries are rejected.
+	_, err = c.QueryEvents(ctx, &Query{Limit: 0})
+	assertError(t, "QueryEvents()", err, service.ErrBadRequest)
+	_, err = c.QueryEvents(ctx, &Query{Limit: 1, PageToken: "garbage"})
+	assertError(t, "QueryEvents()", err, service.ErrBadRequest)
+	token := NewPageToken(SortByName, &Event{ID: "e0", Name: "Delta"})
+	_, err = c.QueryEvents(ctx, &Query{Sort: SortByStartDate, Limit: 1, PageToken: token})
+	assertError(t, "QueryEvents()", err, service.ErrBadRequest)
+}
+
+func testForEachEvent(t *testing.T, c EventsContainer) {
+	ctx := background()
+	l := location("l1", "Arena")
+	for _, i := range []int{2, 0, 3, 1} {
+		mustCreate(t, c, EventsCollection, event(fmt.Sprintf("e%d", i), "Concert", l, i))
+	}
+
+	// The events are visited in the order of their ids.
+	var ids []string
+	err := c.ForEachEvent(ctx, &Filter{StartsAfter: start(1)}, func(e *Event) error {
+		ids = append(ids, e.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachEvent() error = %v", err)
+	}
+	if fmt.Sprint(ids) != "[e1 e2 e3]" {
+		t.Errorf("ForEachEvent() visited %v, want [e1 e2 e3]", ids)
+	}
+
+	// The iteration stops at the first error.
+	errStop := errors.New("stop")
+	var n int
+	err = c.ForEachEvent(ctx, &Filter{}, func(*Event) error {
+		n++
+		return errStop
+	})
+	assertError(t, "ForEachEvent()", err, errStop)
+	if n != 1 {
+		t.Errorf("ForEachEvent() visited %d events after an error, want 1", n)
+	}
+}
+
+func testSearchEvents(t *testing.T, c EventsContainer) {
+	l := location("l1", "Arena")
+	mustCreate(t, c, EventsCollection, event("e1", "Jazz Night", l, 0))
+	mustCreate(t, c, EventsCollection, event("e2", "Rock Concert", l, 1))
+
+	hits, err := c.SearchEvents(background(), "jaz", 10)
+	if err != nil {
+		t.Fatalf("SearchEvents() error = %v", err)
+	}
+	if len(hits) != 1 || hits[0].Event.ID != "e1" {
+		t.Errorf("SearchEvents() = %+v, want only e1", hits)
+	}
+
+	hits, err = c.SearchEvents(background(), "arena", 1)
+	if err != nil {
+		t.Fatalf("SearchEvents() error = %v", err)
+	}
+	if len(hits) != 1 {
+		t.Errorf("SearchEvents() = %d hits, want 1", len(hits))
+	}
+
+	// Events that only reference their location are matched by the data of
+	// the stored location, which fills in the hits.
+	ref := location("l2", "Concertgebouw")
+	mustCreate(t, c, LocationsCollection, ref)
+	mustCreate(t, c, EventsCollection, event("e3", "Matinee", Location{ID: ref.ID}, 2))
+	hits, err = c.SearchEvents(background(), "concertgebow", 10)
+	if err != nil {
+		t.Fatalf("SearchEvents() error = %v", err)
+	}
+	if len(hits) != 1 || hits[0].Event.ID != "e3" || hits[0].Event.Location.Name != ref.Name {
+		t.Errorf("SearchEvents() = %+v, want only e3 at %s", hits, ref.Name)
+	}
+
+	// The hits are filled in with their locations, even if the locations do
+	// not match the query.
+	hits, err = c.SearchEvents(background(), "matinee", 10)
+	if err != nil {
+		t.Fatalf("SearchEvents() error = %v", err)
+	}
+	if len(hits) != 1 || hits[0].Event.ID != "e3" || hits[0].Event.Location.Name != ref.Name {
+		t.Errorf("SearchEvents() = %+v, want only e3 at %s", hits, ref.Name)
+	}
+}
+
+// testConcurrentCreate checks that exactly one of many concurrent attempts to
+// create an entry with the same id succeeds.
+func testConcurrentCreate(t *testing.T, c EventsContainer) {
+	const n = 10
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := location("l1", fmt.Sprintf("Arena %d", i))
+			errs <- c.Create(background(), LocationsCollection, l)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, service.ErrAlreadyExists):
+			t.Errorf("Create() error = %v, want %v", err, service.ErrAlreadyExists)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Create() succeeded %d times, want 1", created)
+	}
+}
+
+// testConcurrentUpdates checks that concurrent updates of the same entry are
+// not lost, i.e. that every update applies its patch to the latest version.
+func testConcurrentUpdates(t *testing.T, c EventsContainer) {
+	l := location("l1", "Arena")
+	mustCreate(t, c, LocationsCollection, l)
+	patches := []string{`{"name":"Stadium"}`, `{"address":"2 Side Street"}`, `{"country":"BE"}`}
+	var wg sync.WaitGroup
+	for _, patch := range patches {
+		wg.Add(1)
+		go func(patch string) {
+			defer wg.Done()
+			_, err := c.Update(background(), LocationsCollection, l.ID, []byte(patch))
+			if err != nil {
+				t.Errorf("Update(%s) error = %v", patch, err)
+			}
+		}(patch)
+	}
+	wg.Wait()
+
+	got, err := c.GetByID(background(), LocationsCollection, l.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	l.Name, l.Address, l.Country = "Stadium", "2 Side Street", "BE"
+	assertEqual(t, l, got)
+}
+
+// testConcurrentWrites checks that concurrent writes to different entries do
+// not interfere with each other.
+func testConcurrentWrites(t *testing.T, c EventsContainer) {
+	const n = 10
+	l := location("l1", "Arena")
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := background()
+			e := event(fmt.Sprintf("e%d", i), fmt.Sprintf("Concert %d", i), l, i)
+			if err := c.Create(ctx, EventsCollection, e); err != nil {
+				t.Errorf("Create() error = %v", err)
+				return
+			}
+			patch := []byte(fmt.Sprintf(`{"name":"Updated %d"}`, i))
+			if _, err := c.Update(ctx, EventsCollection, e.ID, patch); err != nil {
+				t.Errorf("Update() error = %v", err)
+			}
+			if i%2 == 0 {
+				if err := c.Delete(ctx, EventsCollection, e.ID); err != nil {
+					t.Errorf("Delete() error = %v", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	all, err := c.GetAll(background(), EventsCollection)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(all) != n/2 {
+		t.Fatalf("GetAll() = %d entries, want %d", len(all), n/2)
+	}
+	for _, elem := range all {
+		e := elem.(Event) //nolint:forcetypeassert // checked by testGetAll
+		if want := "Updated " + e.ID[1:]; e.Name != want {
+			t.Errorf("event %q has name %q, want %q", e.ID, e.Name, want)
+		}
+	}
+}
+
+// testCanceledContext checks that no operation is performed with a canceled
+// context, and that the context error is returned.
+func testCanceledContext(t *testing.T, c EventsContainer) {
+	l := location("l1", "Arena")
+	mustCreate(t, c, LocationsCollection, l)
+
+	ctx, cancel := context.WithCancel(background())
+	cancel()
+
+	check := func(method string, err error) {
+		t.Helper()
+		assertError(t, method, err, context.Canceled)
+	}
+	check("Create()", c.Create(ctx, LocationsCollection, location("l2", "Stadium")))
+	_, err := c.GetByID(ctx, LocationsCollection, l.ID)
+	check("GetByID()", err)
+	_, err = c.GetByName(ctx, LocationsCollection, l.Name)
+	check("GetByName()", err)
+	_, err = c.GetAll(ctx, LocationsCollection)
+	check("GetAll()", err)
+	check("Replace()", c.Replace(ctx, LocationsCollection, l.ID, location(l.ID, "Stadium")))
+	_, err = c.Update(ctx, LocationsCollection, l.ID, []byte(`{"name":"Stadium"}`))
+	check("Update()", err)
+	check("Delete()", c.Delete(ctx, LocationsCollection, l.ID))
+	_, err = c.CountEvents(ctx, &Filter{})
+	check("CountEvents()", err)
+	_, err = c.QueryEvents(ctx, &Query{Limit: 1})
+	check("QueryEvents()", err)
+	check("ForEachEvent()", c.ForEachEvent(ctx, &Filter{}, func(*Event) error { return nil }))
+	_, err = c.SearchEvents(ctx, "arena", 1)
+	check("SearchEvents()", err)
+	check("Ping()", c.Ping(ctx))
+
+	// The container is left unchanged.
+	all, err := c.GetAll(background(), LocationsCollection)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("GetAll() = %d entries, want 1", len(all))
+	}
+	assertEqual(t, l, all[0])
+}
+
+func testTransactionCommit(t *testing.T, c EventsContainer) {
+	ctx := background()
+	l := location("l1", "Arena")
+	r := outboxRecord("topic", "l1")
+	err := c.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := c.Create(ctx, LocationsCollection, l); err != nil {
+			return err
+		}
+		// The changes are visible within the transaction.
+		if _, err := c.GetByID(ctx, LocationsCollection, l.ID); err != nil {
+			return err
+		}
+		return c.Create(ctx, OutboxCollection, r)
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+
+	got, err := c.GetByID(ctx, LocationsCollection, l.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	assertEqual(t, l, got)
+	pending, err := c.PendingOutbox(ctx, 10)
+	if err != nil {
+		t.Fatalf("PendingOutbox() error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != r.ID || string(pending[0].Payload) != string(r.Payload) {
+		t.Fatalf("PendingOutbox() = %+v, want [%+v]", pending, r)
+	}
+}
+
+func testTransactionRollback(t *testing.T, c EventsContainer) {
+	ctx := background()
+	l1, l2, l3 := location("l1", "Arena"), location("l2", "Stadium"), location("l3", "Hall")
+	mustCreate(t, c, LocationsCollection, l1)
+	mustCreate(t, c, LocationsCollection, l2)
+
+	errFailed := errors.New("failed")
+	err := c.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := c.Create(ctx, LocationsCollection, l3); err != nil {
+			return err
+		}
+		if _, err := c.Update(ctx, LocationsCollection, l1.ID, []byte(`{"name":"Updated"}`)); err != nil {
+			return err
+		}
+		if err := c.Delete(ctx, LocationsCollection, l2.ID); err != nil {
+			return err
+		}
+		// A nested transaction joins the outer one.
+		return c.WithTransaction(ctx, func(ctx context.Context) error {
+			if err := c.Create(ctx, OutboxCollection, outboxRecord("topic", "l3")); err != nil {
+				return err
+			}
+			return errFailed
+		})
+	})
+	assertError(t, "WithTransaction()", err, errFailed)
+
+	// None of the changes were committed.
+	all, err := c.GetAll(ctx, LocationsCollection)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("GetAll() = %d entries, want 2", len(all))
+	}
+	for _, want := range []Location{l1, l2} {
+		got, err := c.GetByID(ctx, LocationsCollection, want.ID)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		assertEqual(t, want, got)
+	}
+	pending, err := c.PendingOutbox(ctx, 10)
+	if err != nil {
+		t.Fatalf("PendingOutbox() error = %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("PendingOutbox() = %d records, want 0", len(pending))
+	}
+}
+
+func testPendingOutbox(t *testing.T, c EventsContainer) {
+	ctx := background()
+	var records []OutboxRecord
+	for i := 0; i < 3; i++ {
+		r := outboxRecord("topic", fmt.Sprintf("e%d", i))
+		mustCreate(t, c, OutboxCollection, r)
+		records = append(records, r)
+	}
+
+	pending, err := c.PendingOutbox(ctx, 2)
+	if err != nil {
+		t.Fatalf("PendingOutbox() error = %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != records[0].ID || pending[1].ID != records[1].ID {
+		t.Fatalf("PendingOutbox() = %+v, want the first two records", pending)
+	}
+
+	if err := c.Delete(ctx, OutboxCollection, records[0].ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	pending, err = c.PendingOutbox(ctx, 10)
+	if err != nil {
+		t.Fatalf("PendingOutbox() error = %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != records[1].ID || pending[1].ID != records[2].ID {
+		t.Fatalf("PendingOutbox() = %+v, want the last two records", pending)
+	}
+
+	_, err = c.PendingOutbox(ctx, 0)
+	assertError(t, "PendingOutbox()", err, service.ErrBadRequest)
+}
+
+func testCountBookings(t *testing.T, c EventsContainer) {
+	ctx := background()
+	for _, b := range [][2]string{{"e1", "u1"}, {"e1", "u2"}, {"e2", "u1"}, {"e3", "u1"}} {
+		mustCreate(t, c, BookingsCollection, Booking{
+			ID:      BookingID(b[0], b[1]),
+			EventID: b[0],
+			UserID:  b[1],
+		})
+	}
+
+	// Bookings are identified by the event and the user.
+	dup := Booking{ID: BookingID("e1", "u1"), EventID: "e1", UserID: "u1"}
+	err := c.Create(ctx, BookingsCollection, dup)
+	assertError(t, "Create()", err, service.ErrAlreadyExists)
+
+	got, err := c.CountBookings(ctx, "e1", "e2", "e4")
+	if err != nil {
+		t.Fatalf("CountBookings() error = %v", err)
+	}
+	if len(got) != 2 || got["e1"] != 2 || got["e2"] != 1 {
+		t.Errorf("CountBookings() = %v, want map[e1:2 e2:1]", got)
+	}
+
+	got, err = c.CountBookings(ctx)
+	if err != nil {
+		t.Fatalf("CountBookings() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("CountBookings() = %v, want empty", got)
+	}
+}
+
+func testPing(t *testing.T, c EventsContainer) {
+	if err := c.Ping(background()); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+}
+
+// testTenants checks that the entries of a tenant are neither read nor changed
+// within another tenant, which may use the same ids and names.
+func testTenants(t *testing.T, c EventsContainer) {
+	ctx, other := background(), WithTenant(context.Background(), "globex")
+	l := location("l1", "Arena")
+	e := event("e1", "Jazz Night", l, 1)
+	mustCreate(t, c, LocationsCollection, l)
+	mustCreate(t, c, EventsCollection, e)
+	mustCreate(t, c, BookingsCollection, Booking{ID: BookingID(e.ID, "u1"), EventID: e.ID})
+
+	// Other tenants may use the same ids and names.
+	otherL := location(l.ID, l.Name)
+	otherL.Address = "Elsewhere 1"
+	if err := c.Create(other, LocationsCollection, otherL); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	got, err := c.GetByName(other, LocationsCollection, l.Name)
+	if err != nil {
+		t.Fatalf("GetByName() error = %v", err)
+	}
+	assertEqual(t, otherL, got)
+
+	// The entries of the tenant are invisible to the other tenant.
+	assertInvisible(t, c, "globex", &e)
+
+	// The entries of the tenant cannot be changed within the other tenant.
+	err = c.Replace(other, EventsCollection, e.ID, e)
+	assertError(t, "Replace()", err, service.ErrNotFound)
+	_, err = c.Update(other, EventsCollection, e.ID, []byte(`{"name":"Blues Night"}`))
+	assertError(t, "Update()", err, service.ErrNotFound)
+	err = c.Delete(other, EventsCollection, e.ID)
+	assertError(t, "Delete()", err, service.ErrNotFound)
+	got, err = c.GetByID(ctx, LocationsCollection, l.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	assertEqual(t, l, got)
+
+	tenants, err := c.Tenants(ctx, LocationsCollection)
+	if err != nil || len(tenants) != 2 || tenants[0] != testTenant || tenants[1] != "globex" {
+		t.Errorf("Tenants() = %v, %v, want [%s globex]", tenants, err, testTenant)
+	}
+	tenants, err = c.Tenants(ctx, EventsCollection)
+	if err != nil || len(tenants) != 1 || tenants[0] != testTenant {
+		t.Errorf("Tenants() = %v, %v, want [%s]", tenants, err, testTenant)
+	}
+
+	// The entries are counted across the tenants.
+	if n, err := c.CountAll(ctx, LocationsCollection); err != nil || n != 2 {
+		t.Errorf("CountAll() = %d, %v, want 2", n, err)
+	}
+}
+
+// assertInvisible makes sure that the event, and its bookings, cannot be read
+// within the given tenant.
+func assertInvisible(t *testing.T, c EventsContainer, tenant string, e *Event) {
+	t.Helper()
+	ctx := WithTenant(context.Background(), tenant)
+	_, err := c.GetByID(ctx, EventsCollection, e.ID)
+	assertError(t, "GetByID()", err, service.ErrNotFound)
+	_, err = c.GetByName(ctx, EventsCollection, e.Name)
+	assertError(t, "GetByName()", err, service.ErrNotFound)
+	all, err := c.GetAll(ctx, EventsCollection)
+	if err != nil || len(all) != 0 {
+		t.Errorf("GetAll() = %v, %v, want no events", all, err)
+	}
+	n, err := c.CountEvents(ctx, &Filter{})
+	if err != nil || n != 0 {
+		t.Errorf("CountEvents() = %d, %v, want 0", n, err)
+	}
+	page, err := c.QueryEvents(ctx, &Query{Limit: 10})
+	if err != nil || len(page.Events) != 0 {
+		t.Errorf("QueryEvents() = %+v, %v, want no events", page, err)
+	}
+	visited := 0
+	err = c.ForEachEvent(ctx, &Filter{}, func(*Event) error { visited++; return nil })
+	if err != nil || visited != 0 {
+		t.Errorf("ForEachEvent() visited %d events, %v, want none", visited, err)
+	}
+	hits, err := c.SearchEvents(ctx, "jazz", 10)
+	if err != nil || len(hits) != 0 {
+		t.Errorf("SearchEvents() = %+v, %v, want no hits", hits, err)
+	}
+	booked, err := c.CountBookings(ctx, e.ID)
+	if err != nil || len(booked) != 0 {
+		t.Errorf("CountBookings() = %v, %v, want empty", booked, err)
+	}
+}
+
+// testTenantsOutbox makes sure that the outbox is read across the tenants, and
+// that the records are removed within their tenant.
+func testTenantsOutbox(t *testing.T, c EventsContainer) {
+	other := WithTenant(context.Background(), "globex")
+	r1, r2 := outboxRecord("topic", "e1"), outboxRecord("topic", "e2")
+	mustCreate(t, c, OutboxCollection, r1)
+	if err := c.Create(other, OutboxCollection, r2); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	pending, err := c.PendingOutbox(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("PendingOutbox() error = %v", err)
+	}
+	if len(pending) != 2 || pending[0].Tenant != testTenant || pending[1].Tenant != "globex" {
+		t.Fatalf("PendingOutbox() = %+v, want the records of both tenants", pending)
+	}
+	err = c.Delete(background(), OutboxCollection, r2.ID)
+	assertError(t, "Delete()", err, service.ErrNotFound)
+	if err := c.Delete(other, OutboxCollection, r2.ID); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+}
+
+// testNoTenant makes sure that the operations not scoped to a tenant fail.
+func testNoTenant(t *testing.T, c EventsContainer) {
+	_, err := c.GetAll(context.Background(), EventsCollection)
+	assertError(t, "GetAll()", err, service.ErrNotAllowed)
+	err = c.Create(context.Background(), LocationsCollection, location("l2", "Stadium"))
+	assertError(t, "Create()", err, service.ErrNotAllowed)
+	_, err = c.CountEvents(context.Background(), &Filter{})
+	assertError(t, "CountEvents()", err, service.ErrNotAllowed)
+}
+
+// background returns an empty context scoped to the tenant of the tests.
+func background() context.Context {
+	return WithTenant(context.Background(), testTenant)
+}
+
+// location returns a location with two halls, "A" and "B".
+func location(id, name string) Location {
+	return Location{
+		ID:      id,
+		Name:    name,
+		Address: "1 Main Street",
+		Country: "NL",
+		Halls: []Hall{
+			{Name: "A", Location: id, Capacity: 100},
+			{Name: "B", Location: id, Capacity: 200},
+		},
+	}
+}
+
+// event returns an event taking place in hall "A" of the given location. The
+// events of consecutive days start at consecutive days.
+func event(id, name string, l Location, day int) Event {
+	return Event{
+		ID:        id,
+		Name:      name,
+		Duration:  2 * time.Hour,
+		StartDate: start(day),
+		EndDate:   start(day).Add(2 * time.Hour),
+		Location:  l,
+		Hall:      "A",
+	}
+}
+
+// start returns the start time of an event on the given day.
+func start(day int) time.Time {
+	return time.Date(2030, time.January, 1+day, 18, 0, 0, 0, time.UTC)
+}
+
+// outboxRecord returns a new outbox record with the given topic and payload.
+func outboxRecord(topic, payload string) OutboxRecord {
+	return OutboxRecord{
+		ID:        NewID(),
+		Topic:     topic,
+		Payload:   []byte(payload),
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+
+		TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+}
+
+func mustCreate(t *testing.T, c EventsContainer, collection string, data any) {
+	t.Helper()
+	if err := c.Create(background(), collection, data); err != nil {
+		t.Fatalf("Create(%s) error = %v", collection, err)
+	}
+}
+
+// assertEqual compares the entries by their json representation, so that
+// implementations are free to choose e.g. the location of the times.
+func assertEqual(t *testing.T, want, got any) {
+	t.Helper()
+	if _, ok := got.(Location); !ok {
+		if _, ok := got.(Event); !ok {
+			t.Fatalf("got entry of type %T, want Event or Location", got)
+		}
+	}
+	w, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("encode entry: %v", err)
+	}
+	g, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("encode entry: %v", err)
+	}
+	if string(w) != string(g) {
+		t.Fatalf("got entry\n\t%s\nwant\n\t%s", g, w)
+	}
+}
+
+func assertError(t *testing.T, method string, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("%s error = %v, want %v", method, err, target)
+	}
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// CSVHeader lists the columns of the CSV representation of events. The dates
+// are formatted using RFC 3339. Recurring events are represented only by their
+// recurrence rule and its time zone, i.e. their excluded dates and exceptions
+// are left out.
+var CSVHeader = []string{
+	"id", "name", "start_date", "end_date", "location_id", "hall", "rrule", "tzid",
+}
+
+// MarshalCSV returns the CSV record of the event, with the columns listed in
+// [CSVHeader].
+func (e *Event) MarshalCSV() []string {
+	var rrule, tzid string
+	if e.Recurrence != nil {
+		rrule, tzid = e.Recurrence.RRule, e.Recurrence.TZID
+	}
+	return []string{
+		e.ID,
+		e.Name,
+		e.StartDate.Format(time.RFC3339),
+		e.EndDate.Format(time.RFC3339),
+		e.Location.ID,
+		e.Hall,
+		rrule,
+		tzid,
+	}
+}
+
+// CSVDecoder decodes events from CSV records, whose columns are given by a
+// header. The header must contain the columns "name", "start_date",
+// "end_date" and "location_id", and may contain any other column from
+// [CSVHeader], in any order.
+type CSVDecoder struct {
+	columns []string
+}
+
+// NewCSVDecoder returns a decoder for the records with the given header. This
+// function returns [service.ErrBadRequest] if the header is not valid.
+func NewCSVDecoder(header []string) (*CSVDecoder, error) {
+	for i, c := range header {
+		if !slices.Contains(CSVHeader, c) {
+			return nil, fmt.Errorf("%w: unknown column %q", service.ErrBadRequest, c)
+		}
+		if slices.Contains(header[:i], c) {
+			return nil, fmt.Errorf("%w: duplicate column %q", service.ErrBadRequest, c)
+		}
+	}
+	for _, c := range []string{"name", "start_date", "end_date", "location_id"} {
+		if !slices.Contains(header, c) {
+			return nil, fmt.Errorf("%w: missing column %q", service.ErrBadRequest, c)
+		}
+	}
+	return &CSVDecoder{columns: header}, nil
+}
+
+// Decode decodes the event from the given record. This function returns a
+// [ValidationError] if a column cannot be decoded.
+func (d *CSVDecoder) Decode(record []string) (Event, error) {
+	values := make(map[string]string, len(d.columns))
+	for i, c := range d.columns {
+		values[c] = record[i]
+	}
+	var v validator
+	e := Event{
+		ID:        values["id"],
+		Name:      values["name"],
+		StartDate: parseCSVTime(&v, "start_date", values["start_date"]),
+		EndDate:   parseCSVTime(&v, "end_date", values["end_date"]),
+		Location:  Location{ID: values["location_id"]},
+		Hall:      values["hall"],
+	}
+	if rrule := values["rrule"]; rrule != "" {
+		e.Recurrence = &Recurrence{RRule: rrule, TZID: values["tzid"]}
+	}
+	return e, v.err()
+}
+
+// parseCSVTime parses the RFC 3339 time from the given column. A failure is
+// recorded by the validator.
+func parseCSVTime(v *validator, column, value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	v.check(err == nil, column, "must be an RFC 3339 time")
+	return t
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+func TestCSV(t *testing.T) {
+	start := time.Date(2030, time.January, 1, 18, 0, 0, 0, time.UTC)
+	e := Event{
+		ID:         "e1",
+		Name:       "Meetup",
+		StartDate:  start,
+		EndDate:    start.Add(2 * time.Hour),
+		Location:   Location{ID: "l1"},
+		Hall:       "A",
+		Recurrence: &Recurrence{RRule: "FREQ=WEEKLY;COUNT=3", TZID: "Europe/Amsterdam"},
+	}
+	d, err := NewCSVDecoder(CSVHeader)
+	if err != nil {
+		t.Fatalf("NewCSVDecoder() error = %v", err)
+	}
+	got, err := d.Decode(e.MarshalCSV())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.ID != e.ID || got.Name != e.Name || !got.StartDate.Equal(e.StartDate) ||
+		!got.EndDate.Equal(e.EndDate) || got.Location.ID != e.Location.ID ||
+		got.Hall != e.Hall || got.Recurrence.RRule != e.Recurrence.RRule ||
+		got.Recurrence.TZID != e.Recurrence.TZID {
+		t.Errorf("Decode() = %+v, want %+v", got, e)
+	}
+
+	// The columns can be given in any order, and the optional ones left out.
+	d, err = NewCSVDecoder([]string{"location_id", "end_date", "start_date", "name"})
+	if err != nil {
+		t.Fatalf("NewCSVDecoder() error = %v", err)
+	}
+	got, err = d.Decode([]string{"l1", "2030-01-01T20:00:00Z", "tomorrow", "Meetup"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != "start_date" {
+		t.Errorf("Decode() error = %v, want a validation error of start_date", err)
+	}
+	if got.Name != "Meetup" || got.Recurrence != nil {
+		t.Errorf("Decode() = %+v", got)
+	}
+
+	for _, header := range [][]string{
+		{"name", "start_date", "end_date"},
+		{"name", "start_date", "end_date", "location_id", "name"},
+		{"name", "start_date", "end_date", "location_id", "color"},
+	} {
+		if _, err := NewCSVDecoder(header); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("NewCSVDecoder(%q) error = %v, want %v", header, err, service.ErrBadRequest)
+		}
+	}
+}
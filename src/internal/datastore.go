@@ -7,11 +7,22 @@ import (
 )
 
 // EventsContainer abstracts the database layer for storing events.
+//
+// The entries of the container belong to tenants. Every operation is scoped to
+// the tenant of its context, see [WithTenant], and never reads nor changes the
+// entries of other tenants. The ids and names of the entries are unique only
+// within a tenant. Operations whose context holds no tenant fail with
+// [service.ErrNotAllowed]. The only exceptions are [Outbox.PendingOutbox] and
+// Tenants, which serve the background tasks of the service.
 type EventsContainer interface {
 	io.Closer
+	Searcher
+	Outbox
+	Bookings
 
 	// Create creates a new entry in the given collection in the
-	// container.
+	// container. This function returns [service.ErrAlreadyExists]
+	// if an entry with the same id is already in the container.
 	Create(_ context.Context, collection string, data any) error
 
 	// GetByID retrieves the entry with the given id from the
@@ -21,7 +32,7 @@ type EventsContainer interface {
 	// if the requested collection is not in the container.
 	GetByID(_ context.Context, collection string, id string) (any, error)
 
-	// GetByID retrieves the entry with the given name from the
+	// GetByName retrieves the entry with the given name from the
 	// given collection in the container. This function returns
 	// [service.ErrNotFound] if the requested item is not in the
 	// container. This function returns [service.ErrNotAllowed]
@@ -33,6 +44,70 @@ type EventsContainer interface {
 	// [service.ErrNotAllowed] if the requested collection is not
 	// in the container.
 	GetAll(_ context.Context, collection string) ([]any, error)
+
+	// Replace replaces the entry with the given id from the
+	// given collection with the provided data. The id of the
+	// entry cannot be changed. This function returns
+	// [service.ErrNotFound] if the requested item is not in the
+	// container. This function returns [service.ErrNotAllowed]
+	// if the requested collection is not in the container. This
+	// function returns [service.ErrAlreadyExists] if the data
+	// conflicts with another entry, e.g. by a duplicate name.
+	Replace(_ context.Context, collection string, id string, data any) error
+
+	// Update applies the given JSON Merge Patch (RFC 7396) to
+	// the entry with the given id from the given collection and
+	// returns the updated entry. The id of the entry cannot be
+	// changed. This function returns [service.ErrBadRequest] if
+	// the patch cannot be applied. This function returns
+	// [service.ErrNotFound] if the requested item is not in the
+	// container. This function returns [service.ErrNotAllowed]
+	// if the requested collection is not in the container.
+	Update(_ context.Context, collection string, id string, patch []byte) (any, error)
+
+	// Delete removes the entry with the given id from the given
+	// collection in the container. This function returns
+	// [service.ErrNotFound] if the requested item is not in the
+	// container. This function returns [service.ErrNotAllowed]
+	// if the requested collection is not in the container.
+	Delete(_ context.Context, collection string, id string) error
+
+	// CountEvents returns the number of events from the events
+	// collection that match the given filter.
+	CountEvents(_ context.Context, filter *Filter) (int, error)
+
+	// QueryEvents retrieves a page of events from the events
+	// collection. The events are filtered and sorted as described
+	// by the query. This function returns [service.ErrBadRequest]
+	// if the query is not valid, e.g. if the page token does not
+	// match the sort order of the query.
+	QueryEvents(_ context.Context, q *Query) (*Page, error)
+
+	// Tenants returns the tenants that have entries in the given
+	// collection, sorted. This function returns
+	// [service.ErrNotAllowed] if the requested collection is not
+	// in the container.
+	Tenants(_ context.Context, collection string) ([]string, error)
+
+	// CountAll returns the number of entries in the given
+	// collection, across all tenants. The number may be estimated,
+	// if counting the entries exactly is expensive, since it is
+	// meant for reporting metrics. This function returns
+	// [service.ErrNotAllowed] if the requested collection is not
+	// in the container.
+	CountAll(_ context.Context, collection string) (int, error)
+
+	// ForEachEvent calls fn for every event from the events
+	// collection that matches the given filter, ordered by id.
+	// The events are streamed from the container instead of
+	// being retrieved all at once. The iteration stops at the
+	// first error returned by fn, which is then returned.
+	ForEachEvent(_ context.Context, filter *Filter, fn func(*Event) error) error
+
+	// Ping checks that the container can serve requests, e.g.
+	// that the database server can be reached. This function
+	// returns [service.ErrConnectionClosed] if it cannot.
+	Ping(_ context.Context) error
 }
 
 // Event represents an event entry in the container.
@@ -43,9 +118,31 @@ type Event struct {
 	StartDate time.Time     `json:"start_date"`
 	EndDate   time.Time     `json:"end_date"`
 	Location  Location      `json:"location"`
+
+	// Hall is the name of the hall of the location where the
+	// event takes place.
+	Hall string `json:"hall"`
+
+	// Organizer identifies the client that created the event,
+	// who owns it, by its authentication method and its subject,
+	// e.g. "jwt:alice". It is set by the service and cannot be
+	// changed by the clients.
+	Organizer string `json:"organizer,omitempty"`
+
+	// Recurrence is set if the event recurs. See [Recurrence].
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+
+	// SeriesID and RecurrenceID are set only on the occurrences
+	// of recurring events, which are not stored. They hold the
+	// id of the recurring event and the original start date of
+	// the occurrence.
+	SeriesID     string     `json:"series_id,omitempty"`
+	RecurrenceID *time.Time `json:"recurrence_id,omitempty"`
 }
 
-// Location represents a location entry in the container.
+// Location represents a location entry in the container. Only the time of day
+// of the open and close times is relevant, i.e. they describe the daily opening
+// hours of the location.
 type Location struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
@@ -54,6 +151,13 @@ type Location struct {
 	OpenTime  time.Time `json:"open_time"`
 	CloseTime time.Time `json:"close_time"`
 	Halls     []Hall    `json:"halls"`
+
+	// Owner identifies the client that created the location,
+	// usually a venue manager, who owns it together with its
+	// halls, by its authentication method and its subject, e.g.
+	// "jwt:bob". It is set by the service and cannot be changed
+	// by the clients.
+	Owner string `json:"owner,omitempty"`
 }
 
 // Hall is the room where the event will be taking place.
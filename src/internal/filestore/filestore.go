@@ -0,0 +1,574 @@
+// Package filestore provides a container that persists the entries in a local
+// append-only log file. It is meant for edge deployments and demos, where
+// running a database server is not an option.
+//
+// Every change to the container is appended to the log and synced to disk
+// before it is applied. When the container is opened, the log is replayed to
+// restore the entries. Periodically, the log is compacted by writing a fresh
+// log holding only the current entries and atomically renaming it over the old
+// one.
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/memory"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// Config holds configuration variables for the file-backed container.
+type Config struct {
+	// Dir is the directory where the log file is stored. The
+	// directory is created if it does not exist.
+	Dir string
+
+	// CompactInterval is the interval at which the log file is
+	// compacted. Zero disables the periodic compaction.
+	CompactInterval time.Duration
+}
+
+// Container is a container that keeps its entries in memory and persists every
+// change in an append-only log file. It is safe for concurrent use.
+type Container struct {
+	// Container serves all reads. Note that only the methods
+	// that change the entries are overridden.
+	*memory.Container
+
+	// mu serializes the changes to the container, so that the
+	// order of the log records matches the order in which the
+	// changes are applied.
+	mu sync.Mutex
+
+	// dir is the directory of the log file.
+	dir string
+
+	// log is the log file, opened for appending, and size is
+	// the size of its records that were written in full.
+	log  *os.File
+	size int64
+
+	// garbage is the number of log records that were written
+	// since the last compaction.
+	garbage int
+
+	// stop stops the periodic compaction, and done is closed
+	// once the compaction goroutine has exited.
+	stop chan struct{}
+	done chan struct{}
+
+	// closeOnce closes the container once, and closeErr is the
+	// error of closing it, which is returned by every call to
+	// [Container.Close].
+	closeOnce sync.Once
+	closeErr  error
+}
+
+var (
+	_ EventsContainer = (*Container)(nil)
+	_ io.Closer       = (*Container)(nil)
+)
+
+// logFile is the name of the log file inside the directory of the container.
+const logFile = "store.log"
+
+// record is a log record describing a single change to the container.
+type record struct {
+	// Op is one of opPut, opDelete or opBatch.
+	Op string `json:"op"`
+
+	Collection string          `json:"collection,omitempty"`
+	ID         string          `json:"id,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+
+	// Tenant is the tenant of the entry. Batch records have none,
+	// since their records hold their own tenants.
+	Tenant string `json:"tenant,omitempty"`
+
+	// Records are the records of a transaction, which are
+	// written as a single batch record, so that they are either
+	// all replayed or none of them is.
+	Records []*record `json:"records,omitempty"`
+}
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	opBatch  = "batch"
+)
+
+// tx is a transaction of a container. The records of the transaction are
+// buffered and written to the log when the transaction is committed.
+type tx struct {
+	c       *Container
+	records []*record
+}
+
+// txKey is the context key under which the transaction is stored.
+type txKey struct{}
+
+// NewContainer opens the container stored in the configured directory, or
+// creates a new one if the directory holds no container.
+func NewContainer(ctx context.Context, cfg *Config) (*Container, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil { //nolint:gomnd // rwxr-x---
+		return nil, Unexpected(ctx, fmt.Errorf("create dir: %w", err))
+	}
+
+	c := &Container{
+		Container: memory.NewContainer(),
+		dir:       cfg.Dir,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if err := c.replay(ctx); err != nil {
+		return nil, fmt.Errorf("replay log: %w", err)
+	}
+
+	// Start from a compacted log. This also opens the log for appending.
+	if err := c.compact(ctx); err != nil {
+		return nil, fmt.Errorf("compact log: %w", err)
+	}
+
+	go c.compactEvery(cfg.CompactInterval)
+	return c, nil
+}
+
+// Create implements the [EventsContainer] interface.
+func (c *Container) Create(ctx context.Context, collection string, data any) error {
+	raw, id, err := encode(data)
+	if err != nil {
+		return err
+	}
+
+	defer c.lock(ctx)()
+	_, err = c.Container.GetByID(ctx, collection, id)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %s %q", service.ErrAlreadyExists, collection, id)
+	case !errors.Is(err, service.ErrNotFound):
+		return err //nolint:wrapcheck // the memory container returns service errors
+	}
+
+	return c.commit(ctx, &record{Op: opPut, Collection: collection, ID: id, Data: raw}, func() error {
+		return c.Container.Create(ctx, collection, raw) //nolint:wrapcheck // see above
+	})
+}
+
+// Replace implements the [EventsContainer] interface.
+func (c *Container) Replace(ctx context.Context, collection string, id string, data any) error {
+	raw, dataID, err := encode(data)
+	if err != nil {
+		return err
+	}
+	if dataID != id {
+		return fmt.Errorf("%w: id cannot be changed", service.ErrBadRequest)
+	}
+
+	defer c.lock(ctx)()
+	if _, err := c.Container.GetByID(ctx, collection, id); err != nil {
+		return err //nolint:wrapcheck // the memory container returns service errors
+	}
+
+	return c.commit(ctx, &record{Op: opPut, Collection: collection, ID: id, Data: raw}, func() error {
+		return c.Container.Replace(ctx, collection, id, raw) //nolint:wrapcheck // see above
+	})
+}
+
+// Update implements the [EventsContainer] interface.
+func (c *Container) Update(
+	ctx context.Context,
+	collection string,
+	id string,
+	patch []byte,
+) (any, error) {
+	defer c.lock(ctx)()
+	elem, err := c.Container.GetByID(ctx, collection, id)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the memory container returns service errors
+	}
+	updated, err := ApplyMergePatch(elem, patch)
+	if err != nil {
+		return nil, fmt.Errorf("apply patch: %w", err)
+	}
+	raw, _, err := encode(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.commit(ctx, &record{Op: opPut, Collection: collection, ID: id, Data: raw}, func() error {
+		return c.Container.Replace(ctx, collection, id, updated) //nolint:wrapcheck // see above
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Delete implements the [EventsContainer] interface.
+func (c *Container) Delete(ctx context.Context, collection string, id string) error {
+	defer c.lock(ctx)()
+	if _, err := c.Container.GetByID(ctx, collection, id); err != nil {
+		return err //nolint:wrapcheck // the memory container returns service errors
+	}
+
+	return c.commit(ctx, &record{Op: opDelete, Collection: collection, ID: id}, func() error {
+		return c.Container.Delete(ctx, collection, id) //nolint:wrapcheck // see above
+	})
+}
+
+// WithTransaction implements the [Outbox] interface. The changes of the
+// transaction are applied to the in-memory container within a transaction of
+// its own, and are written to the log as a single record on commit.
+func (c *Container) WithTransaction(ctx context.Context, fn func(context.Context) error) error {
+	if c.txFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &tx{c: c}
+	ctx = context.WithValue(ctx, txKey{}, t)
+	return c.Container.WithTransaction(ctx, func(ctx context.Context) error { //nolint:wrapcheck // see Create
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		if len(t.records) == 0 {
+			return nil
+		}
+		// If the batch cannot be written, then the in-memory changes
+		// are rolled back as well.
+		return c.append(ctx, &record{Op: opBatch, Records: t.records})
+	})
+}
+
+// Ping implements the [EventsContainer] interface. It makes sure that the log
+// file is still accessible.
+func (c *Container) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck // context errors are returned as is
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.log.Stat(); err != nil {
+		return fmt.Errorf("%w: stat log: %v", service.ErrConnectionClosed, err)
+	}
+	return nil
+}
+
+// Close implements the [io.Closer] interface. The log is compacted one last
+// time before closing it. Closing the container again does nothing, and
+// returns the error of the first call.
+func (c *Container) Close() error {
+	c.closeOnce.Do(func() { c.closeErr = c.close() })
+	return c.closeErr
+}
+
+// close stops the periodic compaction, compacts the log and closes it.
+func (c *Container) close() error {
+	close(c.stop)
+	<-c.done
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctx := context.Background()
+	if err := c.compact(ctx); err != nil {
+		slog.Error("failed to compact log", slog.String("error", err.Error()))
+	}
+	if err := c.log.Close(); err != nil {
+		return Unexpected(ctx, fmt.Errorf("close log: %w", err))
+	}
+	return nil
+}
+
+// txFrom returns the transaction of the container to which the context
+// belongs, or nil if the context does not belong to a transaction.
+func (c *Container) txFrom(ctx context.Context) *tx {
+	if t, ok := ctx.Value(txKey{}).(*tx); ok && t.c == c {
+		return t
+	}
+	return nil
+}
+
+// lock locks the container for writing and returns the function that unlocks
+// it. Within a transaction the container is already locked, so nothing is
+// done.
+func (c *Container) lock(ctx context.Context) (unlock func()) {
+	if c.txFrom(ctx) != nil {
+		return func() {}
+	}
+	c.mu.Lock()
+	return c.mu.Unlock
+}
+
+// commit writes the record to the log and then applies the change to the
+// in-memory container. The record is written for the tenant of the context.
+// Within a transaction, the change is applied first and the record is buffered
+// until the transaction is committed. The caller must hold the lock.
+func (c *Container) commit(ctx context.Context, r *record, apply func() error) error {
+	r.Tenant, _ = TenantFromContext(ctx)
+	if t := c.txFrom(ctx); t != nil {
+		if err := apply(); err != nil {
+			return err
+		}
+		t.records = append(t.records, r)
+		return nil
+	}
+	if err := c.append(ctx, r); err != nil {
+		return err
+	}
+	return apply()
+}
+
+// append writes the record to the log and syncs it to disk. If the record
+// cannot be written in full, then the log is truncated back to the end of the
+// last record, see [Container.rollback].
+func (c *Container) append(ctx context.Context, r *record) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck // context errors are returned as is
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return Unexpected(ctx, fmt.Errorf("encode record: %w", err))
+	}
+	line = append(line, '\n')
+	if _, err := c.log.Write(line); err != nil {
+		return c.rollback(ctx, fmt.Errorf("write record: %w", err))
+	}
+	if err := c.log.Sync(); err != nil {
+		return c.rollback(ctx, fmt.Errorf("sync log: %w", err))
+	}
+	c.size += int64(len(line))
+	c.garbage++
+	return nil
+}
+
+// rollback truncates the log back to the end of the last record that was
+// written in full, after appending a record failed with the given error. The
+// change of the record is not applied, so a part of the record must not be
+// left in the log, where the next records would be appended to it. If the log
+// cannot be truncated, then it is rewritten by the next compaction.
+func (c *Container) rollback(ctx context.Context, err error) error {
+	if terr := c.log.Truncate(c.size); terr != nil {
+		c.garbage++
+		err = fmt.Errorf("%w, truncate log: %v", err, terr)
+	}
+	return Unexpected(ctx, err)
+}
+
+// replay applies the records of the log to the in-memory container. A torn
+// record at the end of the log, which is left behind if the process crashed
+// while appending, is discarded. Corrupt records anywhere else are reported as
+// errors.
+func (c *Container) replay(ctx context.Context) error {
+	f, err := os.Open(filepath.Join(c.dir, logFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return Unexpected(ctx, fmt.Errorf("open log: %w", err))
+	}
+	defer f.Close() //nolint:errcheck // read-only
+
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				slog.Warn("discarding torn record at the end of the log", slog.Int("line", n))
+			}
+			return nil
+		}
+		if err != nil {
+			return Unexpected(ctx, fmt.Errorf("read log: %w", err))
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return Unexpected(ctx, fmt.Errorf("decode record on line %d: %w", n, err))
+		}
+		if err := c.apply(ctx, &rec); err != nil {
+			return fmt.Errorf("apply record on line %d: %w", n, err)
+		}
+	}
+}
+
+// apply applies the record to the in-memory container, within the tenant of
+// the record.
+func (c *Container) apply(ctx context.Context, r *record) error {
+	ctx = WithTenant(ctx, r.Tenant)
+	switch r.Op {
+	case opPut:
+		err := c.Container.Replace(ctx, r.Collection, r.ID, r.Data)
+		if errors.Is(err, service.ErrNotFound) {
+			err = c.Container.Create(ctx, r.Collection, r.Data)
+		}
+		return err //nolint:wrapcheck // the memory container returns service errors
+	case opDelete:
+		err := c.Container.Delete(ctx, r.Collection, r.ID)
+		if errors.Is(err, service.ErrNotFound) {
+			return nil
+		}
+		return err //nolint:wrapcheck // see above
+	case opBatch:
+		for _, rec := range r.Records {
+			if err := c.apply(ctx, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return Unexpected(ctx, fmt.Errorf("unknown op %q", r.Op))
+	}
+}
+
+// compact writes the current entries to a new log file and atomically replaces
+// the old log with it. The new log is synced to disk before the rename, and the
+// directory is synced after it, so that a crash leaves either the old or the
+// new log in place. The caller must hold c.mu, unless the container is still
+// being opened.
+func (c *Container) compact(ctx context.Context) error {
+	path := filepath.Join(c.dir, logFile)
+	tmp, err := os.CreateTemp(c.dir, logFile+".*.tmp")
+	if err != nil {
+		return Unexpected(ctx, fmt.Errorf("create temp log: %w", err))
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // no-op after the rename
+
+	w := bufio.NewWriter(tmp)
+	collections := []string{
+		LocationsCollection, EventsCollection, OutboxCollection, BookingsCollection,
+	}
+	for _, collection := range collections {
+		if err := c.dump(ctx, w, collection); err != nil {
+			_ = tmp.Close() //nolint:errcheck // already failing
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close() //nolint:errcheck // already failing
+		return Unexpected(ctx, fmt.Errorf("write temp log: %w", err))
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close() //nolint:errcheck // already failing
+		return Unexpected(ctx, fmt.Errorf("sync temp log: %w", err))
+	}
+	if err := tmp.Close(); err != nil {
+		return Unexpected(ctx, fmt.Errorf("close temp log: %w", err))
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Unexpected(ctx, fmt.Errorf("rename log: %w", err))
+	}
+	if err := syncDir(c.dir); err != nil {
+		return Unexpected(ctx, fmt.Errorf("sync dir: %w", err))
+	}
+
+	// Reopen the log for appending, since the old file was replaced.
+	log, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o640) //nolint:gomnd // rw-r-----
+	if err != nil {
+		return Unexpected(ctx, fmt.Errorf("open log: %w", err))
+	}
+	info, err := log.Stat()
+	if err != nil {
+		_ = log.Close() //nolint:errcheck // already failing
+		return Unexpected(ctx, fmt.Errorf("stat log: %w", err))
+	}
+	if c.log != nil {
+		_ = c.log.Close() //nolint:errcheck // replaced
+	}
+	c.log, c.size = log, info.Size()
+	c.garbage = 0
+	return nil
+}
+
+// dump writes a record for every entry of the collection, of every tenant. The
+// errors of the writer are left to be checked on flush.
+func (c *Container) dump(ctx context.Context, w io.Writer, collection string) error {
+	tenants, err := c.Container.Tenants(ctx, collection)
+	if err != nil {
+		return err //nolint:wrapcheck // the memory container returns service errors
+	}
+	for _, tenant := range tenants {
+		entries, err := c.Container.GetAll(WithTenant(ctx, tenant), collection)
+		if err != nil {
+			return err //nolint:wrapcheck // see above
+		}
+		for _, elem := range entries {
+			raw, id, err := encode(elem)
+			if err != nil {
+				return err
+			}
+			line, err := json.Marshal(&record{
+				Op: opPut, Collection: collection, ID: id, Data: raw, Tenant: tenant,
+			})
+			if err != nil {
+				return Unexpected(ctx, fmt.Errorf("encode record: %w", err))
+			}
+			_, _ = w.Write(append(line, '\n')) //nolint:errcheck // checked on flush
+		}
+	}
+	return nil
+}
+
+// compactEvery compacts the log at the given interval, until the container is
+// closed. The log is compacted only if it has changed since the last time.
+func (c *Container) compactEvery(interval time.Duration) {
+	defer close(c.done)
+	if interval <= 0 {
+		<-c.stop
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			if c.garbage > 0 {
+				if err := c.compact(context.Background()); err != nil {
+					slog.Error("failed to compact log", slog.String("error", err.Error()))
+				}
+			}
+			c.mu.Unlock()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// encode returns the json representation of the given entry and its id.
+func encode(data any) (json.RawMessage, string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: encode data: %v", service.ErrBadRequest, err)
+	}
+	var key struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, "", fmt.Errorf("%w: decode id: %v", service.ErrBadRequest, err)
+	}
+	return raw, key.ID, nil
+}
+
+// syncDir syncs the directory to disk, so that a rename inside it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err //nolint:wrapcheck // wrapped by the caller
+	}
+	defer d.Close() //nolint:errcheck // read-only
+	return d.Sync() //nolint:wrapcheck // wrapped by the caller
+}
@@ -0,0 +1,119 @@
+package filestore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/containertest"
+	"github.com/eventscompass/events-service/src/internal/filestore"
+)
+
+func TestConformance(t *testing.T) {
+	containertest.Run(t, func(t *testing.T) internal.EventsContainer {
+		return open(t, &filestore.Config{Dir: t.TempDir()})
+	})
+}
+
+// TestReopen checks that the entries survive reopening the container, including
+// the changes of transactions, and that a torn record at the end of the log is
+// discarded.
+func TestReopen(t *testing.T) {
+	ctx := internal.WithTenant(context.Background(), "acme")
+	cfg := filestore.Config{Dir: t.TempDir()}
+	c := open(t, &cfg)
+	for _, id := range []string{"l1", "l2", "l3"} {
+		l := internal.Location{ID: id, Name: "Arena " + id}
+		if err := c.Create(ctx, internal.LocationsCollection, l); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	err := c.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := c.Update(ctx, internal.LocationsCollection, "l1", []byte(`{"name":"Stadium"}`)); err != nil {
+			return err
+		}
+		return c.Delete(ctx, internal.LocationsCollection, "l2")
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+
+	// Simulate a crash while appending a record. Note that the container is
+	// not closed, so that the log is not compacted.
+	f, err := os.OpenFile(filepath.Join(cfg.Dir, "store.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	if _, err := f.WriteString(`{"op":"put","collection":"loc`); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close log: %v", err)
+	}
+
+	all, err := open(t, &cfg).GetAll(ctx, internal.LocationsCollection)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	var names []string
+	for _, elem := range all {
+		names = append(names, elem.(internal.Location).Name) //nolint:forcetypeassert // by construction
+	}
+	if len(names) != 2 || names[0] != "Stadium" || names[1] != "Arena l3" {
+		t.Errorf("GetAll() = %v, want [Stadium Arena l3]", names)
+	}
+}
+
+// TestReopenTenants checks that the entries keep their tenant when the container
+// is reopened.
+func TestReopenTenants(t *testing.T) {
+	cfg := filestore.Config{Dir: t.TempDir()}
+	c := open(t, &cfg)
+	locations := map[string]string{internal.DefaultTenant: "Arena", "acme": ""}
+	for tenant, name := range locations {
+		ctx := internal.WithTenant(context.Background(), tenant)
+		l := internal.Location{ID: "l1", Name: name}
+		if err := c.Create(ctx, internal.LocationsCollection, l); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	// Note that the container is not closed, since the records are synced
+	// to the log as they are written.
+	c = open(t, &cfg)
+	for tenant, want := range locations {
+		elem, err := c.GetByID(internal.WithTenant(context.Background(), tenant),
+			internal.LocationsCollection, "l1")
+		if err != nil {
+			t.Fatalf("GetByID(%s) error = %v", tenant, err)
+		}
+		got := elem.(internal.Location).Name //nolint:forcetypeassert // by construction
+		if got != want {
+			t.Errorf("GetByID(%s) name = %q, want %q", tenant, got, want)
+		}
+	}
+}
+
+// TestCloseTwice checks that closing the container again does nothing.
+func TestCloseTwice(t *testing.T) {
+	c := open(t, &filestore.Config{Dir: t.TempDir(), CompactInterval: time.Hour})
+	for i := 0; i < 2; i++ {
+		if err := c.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	}
+}
+
+// open opens the container and closes it at the end of the test.
+func open(t *testing.T, cfg *filestore.Config) *filestore.Container {
+	t.Helper()
+	c, err := filestore.NewContainer(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewContainer() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
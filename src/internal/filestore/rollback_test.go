@@ -0,0 +1,49 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// TestRollback checks that a record that was written only in part is removed
+// from the log, so that the following records can still be replayed.
+func TestRollback(t *testing.T) {
+	ctx := internal.WithTenant(context.Background(), internal.DefaultTenant)
+	cfg := Config{Dir: t.TempDir()}
+	c, err := NewContainer(ctx, &cfg)
+	if err != nil {
+		t.Fatalf("NewContainer() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	if err := c.Create(ctx, internal.LocationsCollection, internal.Location{ID: "l1"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Simulate a write that failed halfway through the record.
+	c.mu.Lock()
+	_, _ = c.log.WriteString(`{"op":"put","collection":"loc`)
+	err = c.rollback(ctx, errors.New("short write"))
+	c.mu.Unlock()
+	if !errors.Is(err, service.ErrUnexpected) {
+		t.Errorf("rollback() error = %v, want %v", err, service.ErrUnexpected)
+	}
+	if err := c.Create(ctx, internal.LocationsCollection, internal.Location{ID: "l2"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Note that the container is not closed, so that the log is not compacted.
+	reopened, err := NewContainer(ctx, &cfg)
+	if err != nil {
+		t.Fatalf("NewContainer() error = %v", err)
+	}
+	t.Cleanup(func() { _ = reopened.Close() })
+	for _, id := range []string{"l1", "l2"} {
+		if _, err := reopened.GetByID(ctx, internal.LocationsCollection, id); err != nil {
+			t.Errorf("GetByID(%s) error = %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"time"
+)
+
+// Filter restricts the set of events that a query to the container applies
+// to. Fields that are left with their zero value are ignored, i.e. an empty
+// filter matches all events.
+type Filter struct {
+	// StartsAfter matches the events that start at or after the
+	// given time.
+	StartsAfter time.Time
+
+	// StartsBefore matches the events that start before the given
+	// time.
+	StartsBefore time.Time
+
+	// EndsAfter matches the events that end after the given time.
+	EndsAfter time.Time
+
+	// EndsBefore matches the events that end at or before the
+	// given time.
+	EndsBefore time.Time
+
+	// Country matches the events taking place in the given
+	// country.
+	Country string
+
+	// LocationID matches the events taking place at the location
+	// with the given id.
+	LocationID string
+
+	// Hall matches the events taking place in the hall with the
+	// given name.
+	Hall string
+
+	// MinCapacity matches the events taking place in a hall with
+	// at least the given capacity.
+	MinCapacity int
+
+	// Recurring matches only the recurring events.
+	Recurring bool
+
+	// NonRecurring matches only the events that do not recur.
+	NonRecurring bool
+}
+
+// Match returns true if the given event satisfies the filter.
+func (f *Filter) Match(e *Event) bool {
+	switch {
+	case !f.StartsAfter.IsZero() && e.StartDate.Before(f.StartsAfter):
+		return false
+	case !f.StartsBefore.IsZero() && !e.StartDate.Before(f.StartsBefore):
+		return false
+	case !f.EndsAfter.IsZero() && !e.EndDate.After(f.EndsAfter):
+		return false
+	case !f.EndsBefore.IsZero() && e.EndDate.After(f.EndsBefore):
+		return false
+	case f.Country != "" && e.Location.Country != f.Country:
+		return false
+	case f.LocationID != "" && e.Location.ID != f.LocationID:
+		return false
+	case f.Hall != "" && e.Hall != f.Hall:
+		return false
+	case f.Recurring && e.Recurrence == nil:
+		return false
+	case f.NonRecurring && e.Recurrence != nil:
+		return false
+	}
+	if f.MinCapacity > 0 {
+		hall := e.Location.Hall(e.Hall)
+		if hall == nil || hall.Capacity < f.MinCapacity {
+			return false
+		}
+	}
+	return true
+}
+
+// NeedsLocationData returns true if the filter matches events using the data
+// of their location, other than the location id.
+func (f *Filter) NeedsLocationData() bool {
+	return f.Country != "" || f.MinCapacity > 0
+}
@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"hash"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icalTimeLayout is the layout of the UTC times in iCalendar objects.
+const icalTimeLayout = "20060102T150405Z"
+
+// icalLocalTimeLayout is the layout of the local times in iCalendar objects,
+// whose time zone is given by the TZID parameter.
+const icalLocalTimeLayout = "20060102T150405"
+
+// maxLineLength is the maximum length of a content line of an iCalendar
+// object in octets, excluding the line break.
+const maxLineLength = 75
+
+// icalEscaper escapes the special characters of TEXT values.
+var icalEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\r", `\n`,
+	"\n", `\n`,
+)
+
+// CalendarWriter writes events as an iCalendar object as defined by RFC 5545.
+// A recurring event is written together with its recurrence rule, its
+// excluded dates and its exceptions, so that calendar clients expand its
+// occurrences themselves. The writer keeps a digest of everything it writes
+// except the DTSTAMP properties, which is used to tell whether the calendar
+// changed between two requests. Like [bufio.Writer], the first write error is
+// kept and returned by [CalendarWriter.Close].
+type CalendarWriter struct {
+	w      io.Writer
+	stamp  string
+	digest hash.Hash
+	err    error
+
+	// zones maps the time zones referenced by the written times
+	// to the earliest of these times.
+	zones map[string]time.Time
+}
+
+// NewCalendarWriter starts writing a calendar with the given name to w. The
+// given time is used as the DTSTAMP of all the events.
+func NewCalendarWriter(w io.Writer, name string, stamp time.Time) *CalendarWriter {
+	c := &CalendarWriter{
+		w:      w,
+		stamp:  stamp.UTC().Format(icalTimeLayout),
+		digest: sha256.New(),
+		zones:  make(map[string]time.Time),
+	}
+	c.line("BEGIN", "VCALENDAR")
+	c.line("VERSION", "2.0")
+	c.line("PRODID", "-//eventscompass//events-service//EN")
+	c.line("CALSCALE", "GREGORIAN")
+	c.line("X-WR-CALNAME", escapeText(name))
+	return c
+}
+
+// WriteEvent writes the given event. The occurrence of a recurring event is
+// written as a standalone event, whose UID is the id of the occurrence.
+func (c *CalendarWriter) WriteEvent(e *Event) {
+	c.line("BEGIN", "VEVENT")
+	c.line("UID", escapeText(e.ID))
+	c.line("DTSTAMP", c.stamp)
+	c.timeLine("DTSTART", e.Recurrence, e.StartDate)
+	c.timeLine("DTEND", e.Recurrence, e.EndDate)
+	c.line("SUMMARY", escapeText(e.Name))
+	if e.Location.Address != "" {
+		c.line("LOCATION", escapeText(e.Location.Address))
+	}
+	if e.Recurrence != nil {
+		rrule := e.Recurrence.RRule
+		if rule, err := ParseRule(rrule); err == nil {
+			rrule = rule.String()
+		}
+		c.line("RRULE", rrule)
+		if len(e.Recurrence.ExDates) > 0 {
+			c.timeLine("EXDATE", e.Recurrence, e.Recurrence.ExDates...)
+		}
+	}
+	c.line("END", "VEVENT")
+
+	if e.Recurrence == nil {
+		return
+	}
+	for i := range e.Recurrence.Exceptions {
+		c.writeException(e, &e.Recurrence.Exceptions[i])
+	}
+}
+
+// writeException writes the exception of the recurring event as a separate
+// event with the same UID, identified by its RECURRENCE-ID.
+func (c *CalendarWriter) writeException(e *Event, x *Exception) {
+	recurrenceID := "RECURRENCE-ID"
+	if x.ThisAndFuture {
+		recurrenceID += ";RANGE=THISANDFUTURE"
+	}
+	c.line("BEGIN", "VEVENT")
+	c.line("UID", escapeText(e.ID))
+	c.line("DTSTAMP", c.stamp)
+	c.timeLine(recurrenceID, e.Recurrence, x.RecurrenceID)
+	c.timeLine("DTSTART", e.Recurrence, x.StartDate)
+	c.timeLine("DTEND", e.Recurrence, x.EndDate)
+	c.line("SUMMARY", escapeText(x.Name))
+	if e.Location.Address != "" {
+		c.line("LOCATION", escapeText(e.Location.Address))
+	}
+	c.line("END", "VEVENT")
+}
+
+// Close ends the calendar, after writing the VTIMEZONE components of the time
+// zones referenced by the events. It returns the first error that occurred
+// while writing the calendar.
+func (c *CalendarWriter) Close() error {
+	tzids := make([]string, 0, len(c.zones))
+	for tzid := range c.zones {
+		tzids = append(tzids, tzid)
+	}
+	slices.Sort(tzids)
+	for _, tzid := range tzids {
+		if loc, err := time.LoadLocation(tzid); err == nil {
+			c.writeTimezone(tzid, loc, c.zones[tzid])
+		}
+	}
+	c.line("END", "VCALENDAR")
+	return c.err
+}
+
+// Digest returns the digest of the calendar written so far, excluding the
+// DTSTAMP properties.
+func (c *CalendarWriter) Digest() []byte {
+	return c.digest.Sum(nil)
+}
+
+// timeLine writes a DATE-TIME property with the given times of an event with
+// the given recurrence, which is nil if the event does not recur. The times of
+// a recurrence with a time zone are written as local times of the zone, so
+// that calendar clients expand the rule in the same zone as the service. The
+// zone is referenced by its IANA name, and described by a VTIMEZONE component
+// written by [CalendarWriter.Close]. Other times are written in UTC.
+func (c *CalendarWriter) timeLine(name string, r *Recurrence, times ...time.Time) {
+	format := formatTime
+	if r != nil && r.TZID != "" {
+		if loc, err := r.Location(); err == nil {
+			for _, t := range times {
+				if earliest, ok := c.zones[r.TZID]; !ok || t.Before(earliest) {
+					c.zones[r.TZID] = t
+				}
+			}
+			name += ";TZID=" + r.TZID
+			format = func(t time.Time) string {
+				return t.In(loc).Format(icalLocalTimeLayout)
+			}
+		}
+	}
+	values := make([]string, len(times))
+	for i, t := range times {
+		values[i] = format(t)
+	}
+	c.line(name, strings.Join(values, ","))
+}
+
+// line writes a content line with the given name and value, folding it into
+// lines of at most [maxLineLength] octets.
+func (c *CalendarWriter) line(name, value string) {
+	if c.err != nil {
+		return
+	}
+	l := foldLine(name + ":" + value)
+	if name != "DTSTAMP" {
+		c.digest.Write([]byte(l)) //nolint:errcheck // hashes never fail
+	}
+	_, c.err = io.WriteString(c.w, l)
+}
+
+// foldLine splits the content line into lines of at most [maxLineLength]
+// octets, without splitting UTF-8 characters. The continuation lines start
+// with a space. The result ends with a line break.
+func foldLine(l string) string {
+	var b strings.Builder
+	for limit := maxLineLength; len(l) > limit; limit = maxLineLength - 1 {
+		i := limit
+		for i > 0 && !utf8.RuneStart(l[i]) {
+			i--
+		}
+		b.WriteString(l[:i])
+		b.WriteString("\r\n ")
+		l = l[i:]
+	}
+	b.WriteString(l)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// escapeText escapes a TEXT value.
+func escapeText(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+// formatTime formats the time as a UTC DATE-TIME value.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(icalTimeLayout)
+}
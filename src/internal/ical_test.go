@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarWriter(t *testing.T) {
+	start := time.Date(2030, time.January, 1, 18, 0, 0, 0, time.UTC)
+	e := Event{
+		ID:        "e1",
+		Name:      "Meetup; Talks, Drinks",
+		StartDate: start,
+		EndDate:   start.Add(2 * time.Hour),
+		Location:  Location{Address: "Main Street 1, Berlin"},
+		Recurrence: &Recurrence{
+			RRule:   "FREQ=WEEKLY;BYDAY=TU;COUNT=3",
+			ExDates: []time.Time{start.Add(7 * 24 * time.Hour)},
+			Exceptions: []Exception{{
+				RecurrenceID:  start.Add(14 * 24 * time.Hour),
+				ThisAndFuture: true,
+				Name:          "Late Meetup",
+				StartDate:     start.Add(14*24*time.Hour + time.Hour),
+				EndDate:       start.Add(14*24*time.Hour + 3*time.Hour),
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	c := NewCalendarWriter(&buf, "Events", start)
+	c.WriteEvent(&e)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//eventscompass//events-service//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:Events",
+		"BEGIN:VEVENT",
+		"UID:e1",
+		"DTSTAMP:20300101T180000Z",
+		"DTSTART:20300101T180000Z",
+		"DTEND:20300101T200000Z",
+		`SUMMARY:Meetup\; Talks\, Drinks`,
+		`LOCATION:Main Street 1\, Berlin`,
+		"RRULE:FREQ=WEEKLY;COUNT=3;BYDAY=TU",
+		"EXDATE:20300108T180000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:e1",
+		"DTSTAMP:20300101T180000Z",
+		"RECURRENCE-ID;RANGE=THISANDFUTURE:20300115T180000Z",
+		"DTSTART:20300115T190000Z",
+		"DTEND:20300115T210000Z",
+		"SUMMARY:Late Meetup",
+		`LOCATION:Main Street 1\, Berlin`,
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if got := buf.String(); got != want {
+		t.Errorf("calendar = %q, want %q", got, want)
+	}
+
+	// The digest does not depend on the time stamp.
+	c2 := NewCalendarWriter(&bytes.Buffer{}, "Events", time.Now())
+	c2.WriteEvent(&e)
+	_ = c2.Close()
+	if !bytes.Equal(c.Digest(), c2.Digest()) {
+		t.Errorf("Digest() depends on the time stamp")
+	}
+
+	// The times of a recurrence with a time zone are local times of the zone.
+	e.Recurrence.TZID = "Europe/Berlin"
+	buf.Reset()
+	c = NewCalendarWriter(&buf, "Events", start)
+	c.WriteEvent(&e)
+	_ = c.Close()
+	for _, l := range []string{
+		"DTSTART;TZID=Europe/Berlin:20300101T190000",
+		"EXDATE;TZID=Europe/Berlin:20300108T190000",
+		"RECURRENCE-ID;RANGE=THISANDFUTURE;TZID=Europe/Berlin:20300115T190000",
+	} {
+		if !strings.Contains(buf.String(), l+"\r\n") {
+			t.Errorf("calendar = %q, want line %q", buf.String(), l)
+		}
+	}
+
+	// The zone is described by a VTIMEZONE component.
+	want = strings.Join([]string{
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:20290325T020000",
+		"TZOFFSETFROM:+0100",
+		"TZOFFSETTO:+0200",
+		"TZNAME:CEST",
+		"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3",
+		"END:DAYLIGHT",
+		"BEGIN:STANDARD",
+		"DTSTART:20291028T030000",
+		"TZOFFSETFROM:+0200",
+		"TZOFFSETTO:+0100",
+		"TZNAME:CET",
+		"RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("calendar = %q, want suffix %q", buf.String(), want)
+	}
+}
+
+func TestZoneObservances(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	got := zoneObservances(tokyo, 2030)
+	want := []observance{{
+		kind:  "STANDARD",
+		start: "19700101T000000",
+		from:  "+0900",
+		to:    "+0900",
+		name:  "JST",
+	}}
+	if !slices.Equal(got, want) {
+		t.Errorf("zoneObservances(Asia/Tokyo) = %v, want %v", got, want)
+	}
+
+	// The transitions of a zone whose rules change are listed one by one.
+	cairo, err := time.LoadLocation("Africa/Cairo")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	got = zoneObservances(cairo, 2010)
+	if len(got) < 2 {
+		t.Errorf("zoneObservances(Africa/Cairo) = %v, want the transitions", got)
+	}
+	for _, o := range got {
+		if o.rrule != "" {
+			t.Errorf("zoneObservances(Africa/Cairo) = %v, want no rules", o)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"a\r\nb\nc\rd", `a\nb\nc\nd`},
+	}
+	for _, tt := range tests {
+		if got := escapeText(tt.in); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFoldLine(t *testing.T) {
+	l := "SUMMARY:" + strings.Repeat("ä", 60)
+	got := foldLine(l)
+	lines := strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n")
+	if len(lines) != 2 {
+		t.Fatalf("foldLine() returned %d lines, want 2", len(lines))
+	}
+	for _, line := range lines {
+		if len(line) > maxLineLength {
+			t.Errorf("line %q is longer than %d octets", line, maxLineLength)
+		}
+	}
+	if unfolded := lines[0] + strings.TrimPrefix(lines[1], " "); unfolded != l {
+		t.Errorf("unfolded line = %q, want %q", unfolded, l)
+	}
+}
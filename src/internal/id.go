@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// lastID is the last id generated by [NewID]. It is used
+	// to keep the ids generated by this process monotonic.
+	lastID   [16]byte
+	lastIDMu sync.Mutex
+)
+
+// NewID generates a new unique id for an entry in the container. The id is a
+// version 7 UUID (RFC 9562), which starts with a millisecond timestamp. Thus,
+// ids generated later sort after ids generated earlier. Ids generated by the
+// same process are strictly monotonic, even within the same millisecond.
+func NewID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		// The crypto/rand reader is documented to never return an error on
+		// the platforms we support.
+		panic(fmt.Sprintf("read random bytes: %v", err))
+	}
+
+	// The first 48 bits hold the unix timestamp in milliseconds.
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+	copy(u[:6], ts[2:])
+
+	u[6] = (u[6] & 0x0f) | 0x70 // version 7
+	u[8] = (u[8] & 0x3f) | 0x80 // variant 10
+
+	// If the id does not sort after the last one, e.g. because it was
+	// generated within the same millisecond, then increment the last id
+	// instead. Only the trailing 56 random bits are incremented, so that
+	// the version and the variant are preserved (RFC 9562, section 6.2).
+	lastIDMu.Lock()
+	if bytes.Compare(u[:], lastID[:]) <= 0 {
+		u = lastID
+		for i := len(u) - 1; i >= 9; i-- {
+			u[i]++
+			if u[i] != 0 {
+				break
+			}
+		}
+	}
+	lastID = u
+	lastIDMu.Unlock()
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// loggerKey is the context key of the request-scoped logger.
+type loggerKey struct{}
+
+// WithLogger returns a copy of the context that holds the given logger. The
+// logger usually carries the attributes of a request, e.g. its id, so that the
+// logs written while handling the request can be tied to it.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger held by the given context. If the context does not
+// hold a logger, then the default logger is returned.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Unexpected wraps the given error with [service.ErrUnexpected] and logs it, in
+// the same way as [service.Unexpected], but using the logger of the context, so
+// that the log can be tied to the request.
+func Unexpected(ctx context.Context, err error) error {
+	if errors.Is(err, service.ErrUnexpected) {
+		return err
+	}
+	if errors.Is(err, ctx.Err()) {
+		Logger(ctx).Info("context was cancelled or timed out")
+		return err
+	}
+	Logger(ctx).Error("unexpected error occurred", slog.String("error", err.Error()))
+	return fmt.Errorf("%w: %s", service.ErrUnexpected, err)
+}
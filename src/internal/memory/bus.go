@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// Bus is a message bus that delivers the published messages to the
+// subscribers within the same process. It is safe for concurrent use.
+// Messages published to a topic without subscribers are dropped.
+type Bus struct {
+	mu sync.RWMutex
+
+	// subscribers maps a topic to its subscribers.
+	subscribers map[string][]*subscriber
+}
+
+// subscriber is a subscription to a topic of the bus.
+type subscriber struct {
+	// msgs is used to deliver the messages to the subscriber.
+	msgs chan []byte
+
+	// done is closed when the subscription is cancelled.
+	done chan struct{}
+}
+
+var _ service.MessageBus = (*Bus)(nil)
+
+// subscriberBuffer is the number of messages that are buffered for every
+// subscriber.
+const subscriberBuffer = 64
+
+// NewBus creates a new [Bus] instance.
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]*subscriber)}
+}
+
+// Publish implements the [service.MessageBus] interface. The function blocks
+// until every subscriber of the topic has received the message, or until the
+// context is cancelled. Subscribers receive the messages through a buffer, so
+// that a slow subscriber does not immediately block the publishers.
+func (b *Bus) Publish(ctx context.Context, topic string, msg []byte) error {
+	b.mu.RLock()
+	subs := b.subscribers[topic]
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		// Copy the message, so that subscribers do not share memory.
+		select {
+		case sub.msgs <- append([]byte(nil), msg...):
+		case <-sub.done: // the subscription was cancelled in the meantime
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck // context errors are returned as is
+		}
+	}
+	return nil
+}
+
+// Subscribe implements the [service.MessageBus] interface. The event handler is
+// executed for every message published to the topic, one message at a time.
+// This is a blocking function. Canceling the context will cancel the
+// subscription.
+func (b *Bus) Subscribe(ctx context.Context, topic string, h service.EventHandler) error {
+	sub := &subscriber{
+		msgs: make(chan []byte, subscriberBuffer),
+		done: make(chan struct{}),
+	}
+	b.mu.Lock()
+	b.subscribers[topic] = append(b.subscribers[topic], sub)
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := b.subscribers[topic]
+		for i := range subs {
+			if subs[i] == sub {
+				b.subscribers[topic] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+		close(sub.done)
+	}()
+
+	for {
+		select {
+		case msg := <-sub.msgs:
+			h(ctx, msg)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Close implements the [service.MessageBus] interface.
+func (b *Bus) Close() error {
+	return nil
+}
+
+// Ping checks that the bus can deliver messages. The in-memory bus is always
+// able to deliver messages.
+func (b *Bus) Ping(ctx context.Context) error {
+	return ctx.Err() //nolint:wrapcheck // context errors are returned as is
+}
@@ -0,0 +1,607 @@
+// Package memory provides implementations of the service components that keep
+// their state in memory. They are meant for tests and for running the service
+// locally without any external dependencies.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+
+	. "github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// Container is a container that keeps the entries in memory. It is safe for
+// concurrent use. Entries are copied when they are stored and when they are
+// retrieved, so callers cannot modify the stored entries.
+type Container struct {
+	// mu guards the collections. A transaction holds the lock
+	// exclusively until it is finished, see [Container.lock].
+	mu sync.RWMutex
+
+	// collections maps the name of a collection to the entries
+	// of every tenant, which are keyed by their id.
+	collections map[string]map[string]map[string]any
+}
+
+var (
+	_ EventsContainer = (*Container)(nil)
+	_ io.Closer       = (*Container)(nil)
+)
+
+// kinds maps the name of every known collection to the type of its entries.
+var kinds = map[string]reflect.Type{
+	EventsCollection:    reflect.TypeOf(Event{}),
+	LocationsCollection: reflect.TypeOf(Location{}),
+	OutboxCollection:    reflect.TypeOf(OutboxRecord{}),
+	BookingsCollection:  reflect.TypeOf(Booking{}),
+}
+
+// tx is a transaction of a container. Every change made within the transaction
+// records how it can be undone, so that the transaction can be rolled back.
+type tx struct {
+	m    *Container
+	undo []func()
+}
+
+// txKey is the context key under which the transaction is stored.
+type txKey struct{}
+
+// NewContainer creates a new, empty [Container] instance.
+func NewContainer() *Container {
+	collections := make(map[string]map[string]map[string]any, len(kinds))
+	for name := range kinds {
+		collections[name] = make(map[string]map[string]any)
+	}
+	return &Container{collections: collections}
+}
+
+// Create implements the [EventsContainer] interface.
+func (m *Container) Create(ctx context.Context, collection string, data any) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck // context errors are returned as is
+	}
+	elem, err := decode(collection, data)
+	if err != nil {
+		return err
+	}
+	id := idOf(elem)
+
+	defer m.lock(ctx)()
+	entries, err := m.entries(ctx, collection, true)
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[id]; ok {
+		return fmt.Errorf("%w: %s %q", service.ErrAlreadyExists, collection, id)
+	}
+	m.set(ctx, entries, id, elem)
+	return nil
+}
+
+// GetByID implements the [EventsContainer] interface.
+func (m *Container) GetByID(ctx context.Context, collection string, id string) (any, error) {
+	return m.findOne(ctx, collection, func(elem any) bool { return idOf(elem) == id })
+}
+
+// GetByName implements the [EventsContainer] interface.
+func (m *Container) GetByName(ctx context.Context, collection string, name string) (any, error) {
+	return m.findOne(ctx, collection, func(elem any) bool { return nameOf(elem) == name })
+}
+
+// GetAll implements the [EventsContainer] interface. The entries are returned
+// sorted by their id.
+func (m *Container) GetAll(ctx context.Context, collection string) ([]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	defer m.rlock(ctx)()
+	entries, err := m.entries(ctx, collection, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	res := make([]any, 0, len(entries))
+	for _, id := range ids {
+		res = append(res, clone(entries[id]))
+	}
+	return res, nil
+}
+
+// Replace implements the [EventsContainer] interface.
+func (m *Container) Replace(ctx context.Context, collection string, id string, data any) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck // context errors are returned as is
+	}
+	elem, err := decode(collection, data)
+	if err != nil {
+		return err
+	}
+	if idOf(elem) != id {
+		return fmt.Errorf("%w: id cannot be changed", service.ErrBadRequest)
+	}
+
+	defer m.lock(ctx)()
+	entries, err := m.entries(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[id]; !ok {
+		return fmt.Errorf("%w: %s %q", service.ErrNotFound, collection, id)
+	}
+	m.set(ctx, entries, id, elem)
+	return nil
+}
+
+// Update implements the [EventsContainer] interface.
+func (m *Container) Update(
+	ctx context.Context,
+	collection string,
+	id string,
+	patch []byte,
+) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	defer m.lock(ctx)()
+	entries, err := m.entries(ctx, collection, false)
+	if err != nil {
+		return nil, err
+	}
+	elem, ok := entries[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %q", service.ErrNotFound, collection, id)
+	}
+
+	// Note that the patch is applied while holding the lock, so that
+	// concurrent updates are not lost.
+	updated, err := ApplyMergePatch(elem, patch)
+	if err != nil {
+		return nil, fmt.Errorf("apply patch: %w", err)
+	}
+	m.set(ctx, entries, id, updated)
+	return clone(updated), nil
+}
+
+// Delete implements the [EventsContainer] interface.
+func (m *Container) Delete(ctx context.Context, collection string, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	defer m.lock(ctx)()
+	entries, err := m.entries(ctx, collection, false)
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[id]; !ok {
+		return fmt.Errorf("%w: %s %q", service.ErrNotFound, collection, id)
+	}
+	m.set(ctx, entries, id, nil)
+	return nil
+}
+
+// CountEvents implements the [EventsContainer] interface.
+func (m *Container) CountEvents(ctx context.Context, filter *Filter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	events, err := m.events(ctx, filter.Match)
+	if err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// QueryEvents implements the [EventsContainer] interface. Note that the events
+// are always retrieved in full, regardless of the requested fields.
+func (m *Container) QueryEvents(ctx context.Context, q *Query) (*Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // context errors are returned as is
+	}
+	if err := checkQuery(q); err != nil {
+		return nil, err
+	}
+	var cursor *Cursor
+	if q.PageToken != "" {
+		c, err := ParsePageToken(q.Sort, q.PageToken)
+		if err != nil {
+			return nil, fmt.Errorf("parse page token: %w", err)
+		}
+		cursor = c
+	}
+
+	// Collect the matching events that come after the cursor.
+	events, err := m.events(ctx, func(e *Event) bool {
+		return q.Filter.Match(e) && (cursor == nil || q.Sort.Compare(e, cursor) > 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return q.Sort.Compare(&events[i], &Cursor{
+			Value: q.Sort.Value(&events[j]),
+			ID:    events[j].ID,
+		}) < 0
+	})
+
+	page := Page{Events: events}
+	if len(events) > q.Limit {
+		page.Events = events[:q.Limit]
+		page.NextPageToken = NewPageToken(q.Sort, &page.Events[q.Limit-1])
+	}
+	return &page, nil
+}
+
+// checkQuery makes sure that the query is valid. This function returns
+// [service.ErrBadRequest] if the limit is not positive or a field is unknown.
+func checkQuery(q *Query) error {
+	if q.Limit <= 0 {
+		return fmt.Errorf("%w: limit must be positive", service.ErrBadRequest)
+	}
+	for _, f := range q.Fields {
+		if _, ok := EventField(f); !ok {
+			return fmt.Errorf("%w: unknown field %q", service.ErrBadRequest, f)
+		}
+	}
+	return nil
+}
+
+// ForEachEvent implements the [EventsContainer] interface. The matching events
+// are copied before fn is called, so that fn can access the container.
+func (m *Container) ForEachEvent(ctx context.Context, filter *Filter, fn func(*Event) error) error {
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck // context errors are returned as is
+	}
+	events, err := m.events(ctx, filter.Match)
+	if err != nil {
+		return err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	for i := range events {
+		if err := fn(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchEvents implements the [Searcher] interface.
+func (m *Container) SearchEvents(
+	ctx context.Context,
+	query string,
+	limit int,
+) ([]SearchHit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // context errors are returned as is
+	}
+	events, err := m.events(ctx, func(*Event) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	entries, err := m.GetAll(ctx, LocationsCollection)
+	if err != nil {
+		return nil, err
+	}
+	locations := make([]Location, len(entries))
+	for i, elem := range entries {
+		locations[i] = elem.(Location) //nolint:forcetypeassert // by construction
+	}
+	return SearchEvents(events, locations, query, limit), nil
+}
+
+// WithTransaction implements the [Outbox] interface. Transactions are
+// serialized, i.e. a transaction holds the container exclusively until it is
+// finished.
+func (m *Container) WithTransaction(ctx context.Context, fn func(context.Context) error) error {
+	if m.txFrom(ctx) != nil {
+		return fn(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &tx{m: m}
+
+	// Roll back the transaction if fn fails, or if it panics.
+	committed := false
+	defer func() {
+		if !committed {
+			for i := len(t.undo) - 1; i >= 0; i-- {
+				t.undo[i]()
+			}
+		}
+	}()
+	if err := fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// PendingOutbox implements the [Outbox] interface.
+func (m *Container) PendingOutbox(ctx context.Context, limit int) ([]OutboxRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // context errors are returned as is
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", service.ErrBadRequest)
+	}
+
+	defer m.rlock(ctx)()
+	res := make([]OutboxRecord, 0)
+	for tenant, entries := range m.collections[OutboxCollection] {
+		for _, elem := range entries {
+			r := clone(elem).(OutboxRecord) //nolint:forcetypeassert // by construction
+			r.Tenant = tenant
+			res = append(res, r)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// Tenants implements the [EventsContainer] interface.
+func (m *Container) Tenants(ctx context.Context, collection string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	defer m.rlock(ctx)()
+	tenants, ok := m.collections[collection]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+	res := make([]string, 0, len(tenants))
+	for tenant, entries := range tenants {
+		if len(entries) > 0 {
+			res = append(res, tenant)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// CountAll implements the [EventsContainer] interface.
+func (m *Container) CountAll(ctx context.Context, collection string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	defer m.rlock(ctx)()
+	tenants, ok := m.collections[collection]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+	var n int
+	for _, entries := range tenants {
+		n += len(entries)
+	}
+	return n, nil
+}
+
+// CountBookings implements the [Bookings] interface.
+func (m *Container) CountBookings(
+	ctx context.Context,
+	eventIDs ...string,
+) (map[string]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	wanted := make(map[string]bool, len(eventIDs))
+	for _, id := range eventIDs {
+		wanted[id] = true
+	}
+
+	defer m.rlock(ctx)()
+	entries, err := m.entries(ctx, BookingsCollection, false)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]int)
+	for _, elem := range entries {
+		if b := elem.(Booking); wanted[b.EventID] { //nolint:forcetypeassert // by construction
+			res[b.EventID]++
+		}
+	}
+	return res, nil
+}
+
+// Close implements the [io.Closer] interface.
+func (m *Container) Close() error {
+	return nil
+}
+
+// Ping implements the [EventsContainer] interface. The in-memory container is
+// always able to serve requests.
+func (m *Container) Ping(ctx context.Context) error {
+	return ctx.Err() //nolint:wrapcheck // context errors are returned as is
+}
+
+// txFrom returns the transaction of the container to which the context
+// belongs, or nil if the context does not belong to a transaction.
+func (m *Container) txFrom(ctx context.Context) *tx {
+	if t, ok := ctx.Value(txKey{}).(*tx); ok && t.m == m {
+		return t
+	}
+	return nil
+}
+
+// lock locks the container for writing and returns the function that unlocks
+// it. Within a transaction the container is already locked, so nothing is
+// done.
+func (m *Container) lock(ctx context.Context) (unlock func()) {
+	if m.txFrom(ctx) != nil {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+// rlock is like [Container.lock], but locks the container for reading.
+func (m *Container) rlock(ctx context.Context) (unlock func()) {
+	if m.txFrom(ctx) != nil {
+		return func() {}
+	}
+	m.mu.RLock()
+	return m.mu.RUnlock
+}
+
+// entries returns the entries of the collection that belong to the tenant of
+// the context. If create is set, then the entries of a new tenant are created,
+// otherwise a nil map is returned for it. The caller must hold the lock, for
+// writing if create is set. This function returns [service.ErrNotAllowed] if the
+// collection is not known, or if the context holds no tenant.
+func (m *Container) entries(
+	ctx context.Context,
+	collection string,
+	create bool,
+) (map[string]any, error) {
+	tenant, err := RequireTenant(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // intentional
+	}
+	tenants, ok := m.collections[collection]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+	entries, ok := tenants[tenant]
+	if !ok && create {
+		entries = make(map[string]any)
+		tenants[tenant] = entries
+	}
+	return entries, nil
+}
+
+// set stores the entry under the given id, or removes the entry with the given
+// id if elem is nil. The entries are those of a tenant, see
+// [Container.entries]. Within a transaction, the change is recorded so that it
+// can be undone. The caller must hold the lock.
+func (m *Container) set(ctx context.Context, entries map[string]any, id string, elem any) {
+	prev, existed := entries[id]
+	if elem == nil {
+		delete(entries, id)
+	} else {
+		entries[id] = elem
+	}
+
+	if t := m.txFrom(ctx); t != nil {
+		t.undo = append(t.undo, func() {
+			if existed {
+				entries[id] = prev
+			} else {
+				delete(entries, id)
+			}
+		})
+	}
+}
+
+// events returns copies of the stored events of the tenant of the context that
+// satisfy the given predicate.
+func (m *Container) events(ctx context.Context, pred func(*Event) bool) ([]Event, error) {
+	defer m.rlock(ctx)()
+	entries, err := m.entries(ctx, EventsCollection, false)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Event, 0)
+	for _, elem := range entries {
+		e := elem.(Event) //nolint:forcetypeassert // by construction
+		if pred(&e) {
+			res = append(res, clone(e).(Event)) //nolint:forcetypeassert // by construction
+		}
+	}
+	return res, nil
+}
+
+func (m *Container) findOne(
+	ctx context.Context,
+	collection string,
+	pred func(elem any) bool,
+) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // context errors are returned as is
+	}
+
+	defer m.rlock(ctx)()
+	entries, err := m.entries(ctx, collection, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the match with the smallest id, so that the result is
+	// deterministic in case many entries match.
+	var match any
+	for _, elem := range entries {
+		if pred(elem) && (match == nil || idOf(elem) < idOf(match)) {
+			match = elem
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: no matching entry in %s", service.ErrNotFound, collection)
+	}
+	return clone(match), nil
+}
+
+// decode converts the data into an entry of the given collection. The data is
+// converted through its json representation, which also makes sure that the
+// stored entry does not share memory with the caller. This function returns
+// [service.ErrNotAllowed] if the collection is not known.
+func decode(collection string, data any) (any, error) {
+	kind, ok := kinds[collection]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: encode data: %v", service.ErrBadRequest, err)
+	}
+	elem := reflect.New(kind)
+	if err := json.Unmarshal(raw, elem.Interface()); err != nil {
+		return nil, fmt.Errorf("%w: decode data: %v", service.ErrBadRequest, err)
+	}
+	return elem.Elem().Interface(), nil
+}
+
+// clone returns a deep copy of the given entry.
+func clone(elem any) any {
+	raw, err := json.Marshal(elem)
+	if err != nil {
+		panic(fmt.Sprintf("encode entry: %v", err)) // entries are always encodable
+	}
+	res := reflect.New(reflect.TypeOf(elem))
+	if err := json.Unmarshal(raw, res.Interface()); err != nil {
+		panic(fmt.Sprintf("decode entry: %v", err)) // entries are always decodable
+	}
+	return res.Elem().Interface()
+}
+
+// idOf returns the id of the given entry.
+func idOf(elem any) string {
+	return reflect.ValueOf(elem).FieldByName("ID").String()
+}
+
+// nameOf returns the name of the given entry.
+func nameOf(elem any) string {
+	return reflect.ValueOf(elem).FieldByName("Name").String()
+}
@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/containertest"
+	"github.com/eventscompass/events-service/src/internal/memory"
+)
+
+func TestConformance(t *testing.T) {
+	containertest.Run(t, func(*testing.T) internal.EventsContainer {
+		return memory.NewContainer()
+	})
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// ApplyMergePatch applies the given JSON Merge Patch (RFC 7396) to the JSON
+// representation of elem and decodes the result into a new value of the same
+// type as elem. The "id" member of elem cannot be changed by the patch. This
+// function returns [service.ErrBadRequest] if the patch is not valid JSON, if
+// it tries to change the id, or if the patched document cannot be decoded.
+func ApplyMergePatch(elem any, patch []byte) (any, error) {
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: decode patch: %v", service.ErrBadRequest, err)
+	}
+
+	// Convert the element into its generic JSON representation.
+	raw, err := json.Marshal(elem)
+	if err != nil {
+		return nil, fmt.Errorf("%w: encode elem: %v", service.ErrUnexpected, err)
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%w: decode elem: %v", service.ErrUnexpected, err)
+	}
+
+	// Apply the patch and make sure the id was not modified. Note that the
+	// patch is applied in place, so the original id is read beforehand.
+	id := idOf(doc)
+	patched := mergePatch(doc, p)
+	if idOf(patched) != id {
+		return nil, fmt.Errorf("%w: id cannot be changed", service.ErrBadRequest)
+	}
+
+	// Decode the patched document into a value of the original type.
+	raw, err = json.Marshal(patched)
+	if err != nil {
+		return nil, fmt.Errorf("%w: encode patched: %v", service.ErrUnexpected, err)
+	}
+	res := reflect.New(reflect.TypeOf(elem))
+	if err := json.Unmarshal(raw, res.Interface()); err != nil {
+		return nil, fmt.Errorf("%w: decode patched: %v", service.ErrBadRequest, err)
+	}
+	return res.Elem().Interface(), nil
+}
+
+// mergePatch implements the MergePatch algorithm as defined in RFC 7396,
+// section 2. If the patch is not a JSON object, then it replaces the target.
+// Otherwise, every member of the patch is recursively merged into the target,
+// and members with a null value are removed from the target.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// idOf returns the "id" member of the given JSON document, or nil if the
+// document is not an object.
+func idOf(doc any) any {
+	if m, ok := doc.(map[string]any); ok {
+		return m["id"]
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+// Package metrics implements counters, histograms and gauges that are exposed
+// in the Prometheus text exposition format, see
+// https://prometheus.io/docs/instrumenting/exposition_formats/. Only the
+// subset of the format that is needed by the service is implemented.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default upper bounds of the buckets of a histogram,
+// which fit the latency of requests in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// validName matches the valid names of metrics and labels.
+var validName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Registry holds a set of metrics, and writes them in the text format. It
+// implements the [http.Handler] interface for serving the metrics. It is safe
+// for concurrent use.
+type Registry struct {
+	mu sync.Mutex
+
+	// families maps the name of a metric to the metric.
+	families map[string]family
+}
+
+// family is a metric together with its samples.
+type family interface {
+	// write writes the samples of the metric. The header of the
+	// metric is written by the registry.
+	write(ctx context.Context, w io.Writer) error
+}
+
+// NewRegistry creates a new [Registry] instance.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register adds the metric with the given name to the registry. This function
+// panics if the name or the label names are not valid, or if the name is
+// already registered, since that is a programming error.
+func (r *Registry) register(name, help, kind string, labels []string, f family) {
+	for _, n := range append([]string{name}, labels...) {
+		if !validName.MatchString(n) || n == "le" {
+			panic(fmt.Sprintf("metrics: invalid name %q", n))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", name))
+	}
+	r.families[name] = &header{name: name, help: help, kind: kind, family: f}
+}
+
+// header writes the HELP and TYPE lines of a metric before its samples.
+type header struct {
+	name, help, kind string
+	family
+}
+
+func (h *header) write(ctx context.Context, w io.Writer) error {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(h.help)
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", h.name, help, h.name, h.kind)
+	if err != nil {
+		return err //nolint:wrapcheck // the writer errors are returned as is
+	}
+	return h.family.write(ctx, w)
+}
+
+// Write writes all the metrics in the text format, ordered by their name.
+func (r *Registry) Write(ctx context.Context, w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]family, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if err := f.write(ctx, bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush() //nolint:wrapcheck // the writer errors are returned as is
+}
+
+// ServeHTTP implements the [http.Handler] interface.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Write(req.Context(), w); err != nil {
+		slog.Info("failed to write metrics", slog.String("error", err.Error()))
+	}
+}
+
+// vec holds the series of a metric with labels. A series is identified by its
+// label values.
+type vec[T any] struct {
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series[T]
+
+	// init initializes the value of a new series.
+	init func() T
+}
+
+// series is the value of a metric with the given label values.
+type series[T any] struct {
+	labelValues []string
+	value       T
+}
+
+// with calls fn with the value of the series with the given label values,
+// creating the series if needed. This function panics if the number of label
+// values does not match the number of labels, since that is a programming
+// error.
+func (v *vec[T]) with(labelValues []string, fn func(T) T) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values, want %d",
+			len(labelValues), len(v.labels)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{labelValues: slices.Clone(labelValues), value: v.init()}
+		v.series[key] = s
+	}
+	s.value = fn(s.value)
+}
+
+// snapshot returns a copy of all the series, ordered by their label values.
+func (v *vec[T]) snapshot(clone func(T) T) []series[T] {
+	v.mu.Lock()
+	res := make([]series[T], 0, len(v.series))
+	for _, s := range v.series {
+		res = append(res, series[T]{labelValues: s.labelValues, value: clone(s.value)})
+	}
+	v.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		return slices.Compare(res[i].labelValues, res[j].labelValues) < 0
+	})
+	return res
+}
+
+// CounterVec is a counter partitioned by labels. A counter only goes up.
+type CounterVec struct {
+	name string
+	vec[float64]
+}
+
+// NewCounterVec registers a new counter with the given name, help text and
+// labels.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		name: name,
+		vec: vec[float64]{
+			labels: labels,
+			series: make(map[string]*series[float64]),
+			init:   func() float64 { return 0 },
+		},
+	}
+	r.register(name, help, "counter", labels, c)
+	return c
+}
+
+// Inc increments the counter with the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds the given non-negative value to the counter with the given label
+// values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.with(labelValues, func(old float64) float64 { return old + math.Max(v, 0) })
+}
+
+func (c *CounterVec) write(_ context.Context, w io.Writer) error {
+	for _, s := range c.snapshot(func(v float64) float64 { return v }) {
+		if err := writeSample(w, c.name, c.labels, s.labelValues, s.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HistogramVec is a histogram partitioned by labels. It counts the observed
+// values in buckets, and keeps track of their count and sum.
+type HistogramVec struct {
+	name    string
+	buckets []float64
+	vec[*histogram]
+}
+
+// histogram is the value of a series of a histogram. The counts of the buckets
+// are not cumulative, i.e. every value is counted only in the first bucket
+// that fits it. The last count is that of the +Inf bucket.
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a new histogram with the given name, help text,
+// bucket upper bounds and labels. The buckets must be sorted in increasing
+// order.
+func (r *Registry) NewHistogramVec(
+	name, help string,
+	buckets []float64,
+	labels ...string,
+) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		buckets: slices.Clone(buckets),
+		vec: vec[*histogram]{
+			labels: labels,
+			series: make(map[string]*series[*histogram]),
+			init: func() *histogram {
+				return &histogram{counts: make([]uint64, len(buckets)+1)}
+			},
+		},
+	}
+	r.register(name, help, "histogram", labels, h)
+	return h
+}
+
+// Observe adds the given value to the histogram with the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.with(labelValues, func(old *histogram) *histogram {
+		old.counts[i]++
+		old.sum += v
+		return old
+	})
+}
+
+func (h *HistogramVec) write(_ context.Context, w io.Writer) error {
+	clone := func(v *histogram) *histogram {
+		return &histogram{counts: slices.Clone(v.counts), sum: v.sum}
+	}
+	for _, s := range h.snapshot(clone) {
+		if err := h.writeSeries(w, s.labelValues, s.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSeries writes the cumulative buckets, the sum and the count of a series
+// of the histogram.
+func (h *HistogramVec) writeSeries(w io.Writer, labelValues []string, v *histogram) error {
+	labels := append(slices.Clone(h.labels), "le")
+	var count uint64
+	for i, c := range v.counts {
+		count += c
+		le := math.Inf(1)
+		if i < len(h.buckets) {
+			le = h.buckets[i]
+		}
+		values := append(slices.Clone(labelValues), formatFloat(le))
+		if err := writeSample(w, h.name+"_bucket", labels, values, float64(count)); err != nil {
+			return err
+		}
+	}
+	if err := writeSample(w, h.name+"_sum", h.labels, labelValues, v.sum); err != nil {
+		return err
+	}
+	return writeSample(w, h.name+"_count", h.labels, labelValues, float64(count))
+}
+
+// GaugeFunc is a gauge whose value is computed whenever the metrics are
+// written.
+type GaugeFunc struct {
+	name string
+	fn   func(context.Context) (float64, error)
+}
+
+// NewGaugeFunc registers a new gauge with the given name and help text, whose
+// value is computed by calling fn. If fn fails, then the gauge is left out.
+func (r *Registry) NewGaugeFunc(
+	name, help string,
+	fn func(context.Context) (float64, error),
+) *GaugeFunc {
+	g := &GaugeFunc{name: name, fn: fn}
+	r.register(name, help, "gauge", nil, g)
+	return g
+}
+
+func (g *GaugeFunc) write(ctx context.Context, w io.Writer) error {
+	v, err := g.fn(ctx)
+	if err != nil {
+		slog.Info("failed to compute gauge",
+			slog.String("name", g.name),
+			slog.String("error", err.Error()),
+		)
+		return nil
+	}
+	return writeSample(w, g.name, nil, nil, v)
+}
+
+// writeSample writes a single sample line.
+func writeSample(w io.Writer, name string, labels, labelValues []string, v float64) error {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(l)
+			b.WriteString(`="`)
+			b.WriteString(labelEscaper.Replace(labelValues[i]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err //nolint:wrapcheck // the writer errors are returned as is
+}
+
+// labelEscaper escapes the label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatFloat formats a value as required by the text format.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
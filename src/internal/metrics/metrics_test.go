@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var errBoom = errors.New("boom")
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Number of requests.", "route", "code")
+	latency := r.NewHistogramVec("latency_seconds", "Request latency.",
+		[]float64{0.1, 1}, "route")
+	r.NewGaugeFunc("events", "Number of events.", func(context.Context) (float64, error) {
+		return 42, nil
+	})
+	r.NewGaugeFunc("broken", "Always fails.", func(context.Context) (float64, error) {
+		return 0, errBoom
+	})
+
+	requests.Inc("/events", "200")
+	requests.Add(2, "/events", "200")
+	requests.Inc(`/a"b\c`, "404")
+	latency.Observe(0.05, "/events")
+	latency.Observe(0.1, "/events")
+	latency.Observe(3, "/events")
+
+	var buf strings.Builder
+	if err := r.Write(context.Background(), &buf); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"# HELP broken Always fails.",
+		"# TYPE broken gauge",
+		"# HELP events Number of events.",
+		"# TYPE events gauge",
+		"events 42",
+		"# HELP latency_seconds Request latency.",
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{route="/events",le="0.1"} 2`,
+		`latency_seconds_bucket{route="/events",le="1"} 2`,
+		`latency_seconds_bucket{route="/events",le="+Inf"} 3`,
+		`latency_seconds_sum{route="/events"} 3.15`,
+		`latency_seconds_count{route="/events"} 3`,
+		"# HELP requests_total Number of requests.",
+		"# TYPE requests_total counter",
+		`requests_total{route="/a\"b\\c",code="404"} 1`,
+		`requests_total{route="/events",code="200"} 3`,
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("requests_total", "Number of requests.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	got, want := rec.Header().Get("Content-Type"), "text/plain; version=0.0.4"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("Content-Type = %q, want prefix %q", got, want)
+	}
+	got, want = rec.Body.String(), "requests_total 1\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("body = %q, want suffix %q", got, want)
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	tests := map[string]func(r *Registry){
+		"invalid name": func(r *Registry) {
+			r.NewCounterVec("requests-total", "Number of requests.")
+		},
+		"reserved label": func(r *Registry) {
+			r.NewHistogramVec("latency_seconds", "Request latency.", DefaultBuckets, "le")
+		},
+		"duplicate name": func(r *Registry) {
+			r.NewCounterVec("requests_total", "Number of requests.")
+			r.NewCounterVec("requests_total", "Number of requests.")
+		},
+		"label count": func(r *Registry) {
+			r.NewCounterVec("requests_total", "Number of requests.", "code").Inc()
+		},
+	}
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+			fn(NewRegistry())
+		})
+	}
+}
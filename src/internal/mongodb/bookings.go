@@ -0,0 +1,51 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	. "github.com/eventscompass/events-service/src/internal"
+)
+
+// CountBookings implements the [Bookings] interface.
+func (m *MongoDBContainer) CountBookings(
+	ctx context.Context,
+	eventIDs ...string,
+) (map[string]int, error) {
+	if len(eventIDs) == 0 {
+		return map[string]int{}, nil
+	}
+
+	match, err := scope(ctx, bson.M{"eventid": bson.M{"$in": eventIDs}})
+	if err != nil {
+		return nil, err
+	}
+	c := m.database.Collection(BookingsCollection)
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": "$eventid", "n": bson.M{"$sum": 1}}},
+	}
+	cursor, err := c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("aggregate: %w", err))
+	}
+
+	// Use context.Background() to ensure Close completes even if the ctx passed
+	// to this function has errored.
+	defer cursor.Close(context.Background()) //nolint:errcheck, contextcheck // intentional
+
+	var groups []struct {
+		EventID string `bson:"_id"`
+		N       int    `bson:"n"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
+	}
+	res := make(map[string]int, len(groups))
+	for _, g := range groups {
+		res[g.EventID] = g.N
+	}
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package mongodb
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+
+	. "github.com/eventscompass/events-service/src/internal"
+)
+
+// toBSON converts the given filter into a mongo query document. Note that the
+// fields of the stored documents are named after the lower-cased names of the
+// struct fields, e.g. "enddate" for [Event.EndDate].
+func toBSON(f *Filter) bson.M {
+	q := bson.M{}
+	startDate := bson.M{}
+	if !f.StartsAfter.IsZero() {
+		startDate["$gte"] = f.StartsAfter
+	}
+	if !f.StartsBefore.IsZero() {
+		startDate["$lt"] = f.StartsBefore
+	}
+	if len(startDate) > 0 {
+		q["startdate"] = startDate
+	}
+	endDate := bson.M{}
+	if !f.EndsAfter.IsZero() {
+		endDate["$gt"] = f.EndsAfter
+	}
+	if !f.EndsBefore.IsZero() {
+		endDate["$lte"] = f.EndsBefore
+	}
+	if len(endDate) > 0 {
+		q["enddate"] = endDate
+	}
+	if f.Country != "" {
+		q["location.country"] = f.Country
+	}
+	if f.LocationID != "" {
+		q["location.id"] = f.LocationID
+	}
+	if f.Hall != "" {
+		q["hall"] = f.Hall
+	}
+	if f.Recurring {
+		q["recurrence"] = bson.M{"$ne": nil}
+	}
+	if f.NonRecurring {
+		q["recurrence"] = nil
+	}
+	if f.MinCapacity > 0 {
+		// The capacity of an event is the capacity of its hall, which is
+		// looked up by name in the halls of its location.
+		q["$expr"] = bson.M{"$gte": bson.A{
+			bson.M{"$max": bson.M{"$map": bson.M{
+				"input": bson.M{"$filter": bson.M{
+					"input": "$location.halls",
+					"cond":  bson.M{"$eq": bson.A{"$$this.name", "$hall"}},
+				}},
+				"in": "$$this.capacity",
+			}}},
+			f.MinCapacity,
+		}}
+	}
+	return q
+}
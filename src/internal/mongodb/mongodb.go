@@ -24,6 +24,10 @@ type Config struct {
 	Username string
 	Password string
 	Database string
+
+	// UniqueNames makes sure that no two entries of the same
+	// collection and tenant have the same name.
+	UniqueNames bool
 }
 
 // MongoDBContainer is a container backed by a Mongo database.
@@ -53,29 +57,124 @@ func NewMongoDBContainer(ctx context.Context, cfg *Config) (*MongoDBContainer, e
 	var err error
 	once.Do(func() { client, err = mongo.Connect(ctx, clientOptions) })
 	if err != nil {
-		return nil, service.Unexpected(ctx, fmt.Errorf("mongo connect: %w", err))
+		return nil, Unexpected(ctx, fmt.Errorf("mongo connect: %w", err))
 	}
 	if err := client.Ping(ctx, readpref.Primary()); err != nil {
 		_ = client.Disconnect(ctx) //nolint:errcheck // intentional
-		return nil, service.Unexpected(ctx, fmt.Errorf("ping mongo: %w", err))
+		return nil, Unexpected(ctx, fmt.Errorf("ping mongo: %w", err))
 	}
 
 	database := client.Database(cfg.Database)
+	if err := ensureIndexes(ctx, database, cfg.UniqueNames); err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("ensure indexes: %w", err))
+	}
+	if err := migrate(ctx, database); err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("migrate: %w", err))
+	}
+	if err := indexGrams(ctx, database); err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("index grams: %w", err))
+	}
+
 	return &MongoDBContainer{
 		client:   client,
 		database: database,
 	}, nil
 }
 
+// ensureIndexes creates the indexes needed by the container, unless they
+// already exist. Every entry is uniquely identified by its tenant and id, and
+// optionally by its tenant and name. Since every query is scoped to a tenant,
+// the indexes start with the tenant. Only the outbox is indexed by id first,
+// since it is read across the tenants. Events are additionally indexed for
+// sorting and filtering, and events and locations for searching. Note that
+// this also creates the collections, which cannot be created implicitly by
+// inserting into them within a transaction.
+func ensureIndexes(ctx context.Context, database *mongo.Database, uniqueNames bool) error {
+	collections := []string{
+		EventsCollection, LocationsCollection, OutboxCollection, BookingsCollection,
+	}
+	for _, collection := range collections {
+		key := bson.D{{Key: tenantField, Value: 1}, {Key: "id", Value: 1}}
+		if collection == OutboxCollection {
+			key = bson.D{{Key: "id", Value: 1}, {Key: tenantField, Value: 1}}
+		}
+		models := []mongo.IndexModel{{Keys: key, Options: options.Index().SetUnique(true)}}
+		if uniqueNames && (collection == EventsCollection || collection == LocationsCollection) {
+			models = append(models, mongo.IndexModel{
+				Keys:    bson.D{{Key: tenantField, Value: 1}, {Key: "name", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+		}
+		if collection == EventsCollection {
+			models = append(models, eventIndexes()...)
+		}
+		if collection == LocationsCollection {
+			// Support searching the locations by their grams.
+			models = append(models, mongo.IndexModel{Keys: bson.D{
+				{Key: tenantField, Value: 1},
+				{Key: gramsField, Value: 1},
+			}})
+		}
+		if collection == BookingsCollection {
+			// Support counting the bookings of an event.
+			models = append(models, mongo.IndexModel{Keys: bson.D{
+				{Key: tenantField, Value: 1},
+				{Key: "eventid", Value: 1},
+			}})
+		}
+
+		c := database.Collection(collection)
+		if _, err := c.Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("create %s indexes: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// eventIndexes returns the indexes that support paginating the events sorted by
+// start date or by name, filtering them by location and date, and searching
+// them by the grams of their names.
+func eventIndexes() []mongo.IndexModel {
+	keys := []bson.D{
+		{{Key: "startdate", Value: 1}, {Key: "id", Value: 1}},
+		{{Key: "name", Value: 1}, {Key: "id", Value: 1}},
+		{{Key: "enddate", Value: 1}},
+		{{Key: "location.id", Value: 1}, {Key: "hall", Value: 1}, {Key: "startdate", Value: 1}},
+		{{Key: "location.country", Value: 1}, {Key: "startdate", Value: 1}},
+		{{Key: gramsField, Value: 1}},
+	}
+	models := make([]mongo.IndexModel, 0, len(keys))
+	for _, k := range keys {
+		models = append(models, mongo.IndexModel{
+			Keys: append(bson.D{{Key: tenantField, Value: 1}}, k...),
+		})
+	}
+	return models
+}
+
 // Create implements the [EventsContainer] interface.
 func (m *MongoDBContainer) Create(
 	ctx context.Context,
 	collection string,
 	data any,
 ) error {
+	if !isKnown(collection) {
+		return fmt.Errorf(
+			"%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+
+	doc, err := withTenant(ctx, data)
+	if err != nil {
+		return err
+	}
+	doc = withGrams(collection, doc)
+
 	c := m.database.Collection(collection)
-	if _, err := c.InsertOne(ctx, data); err != nil {
-		return service.Unexpected(ctx, fmt.Errorf("insert one: %w", err))
+	if _, err := c.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%w: %v", service.ErrAlreadyExists, err)
+		}
+		return Unexpected(ctx, fmt.Errorf("insert one: %w", err))
 	}
 	return nil
 }
@@ -103,11 +202,20 @@ func (m *MongoDBContainer) GetAll(
 	ctx context.Context,
 	collection string,
 ) ([]any, error) {
-	// Get all elements from the requested collection.
+	if !isKnown(collection) {
+		return nil, fmt.Errorf(
+			"%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+
+	// Get all elements of the tenant from the requested collection.
+	filter, err := scope(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
 	c := m.database.Collection(collection)
-	cursor, err := c.Find(ctx, bson.D{})
+	cursor, err := c.Find(ctx, filter)
 	if err != nil {
-		return nil, service.Unexpected(ctx, fmt.Errorf("find: %w", err))
+		return nil, Unexpected(ctx, fmt.Errorf("find: %w", err))
 	}
 
 	// Use context.Background() to ensure Close completes even if the ctx passed
@@ -121,7 +229,7 @@ func (m *MongoDBContainer) GetAll(
 	case EventsCollection:
 		var elems []Event
 		if err := cursor.All(ctx, &elems); err != nil {
-			return nil, service.Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
+			return nil, Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
 		}
 		for _, e := range elems {
 			res = append(res, e)
@@ -129,7 +237,23 @@ func (m *MongoDBContainer) GetAll(
 	case LocationsCollection:
 		var elems []Location
 		if err := cursor.All(ctx, &elems); err != nil {
-			return nil, service.Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
+			return nil, Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
+		}
+		for _, e := range elems {
+			res = append(res, e)
+		}
+	case OutboxCollection:
+		var elems []OutboxRecord
+		if err := cursor.All(ctx, &elems); err != nil {
+			return nil, Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
+		}
+		for _, e := range elems {
+			res = append(res, e)
+		}
+	case BookingsCollection:
+		var elems []Booking
+		if err := cursor.All(ctx, &elems); err != nil {
+			return nil, Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
 		}
 		for _, e := range elems {
 			res = append(res, e)
@@ -142,20 +266,137 @@ func (m *MongoDBContainer) GetAll(
 	return res, nil
 }
 
+// Replace implements the [EventsContainer] interface.
+func (m *MongoDBContainer) Replace(
+	ctx context.Context,
+	collection string,
+	id string,
+	data any,
+) error {
+	if !isKnown(collection) {
+		return fmt.Errorf(
+			"%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+
+	// Make sure that the id of the entry is not changed.
+	doc, err := withTenant(ctx, data)
+	if err != nil {
+		return err
+	}
+	if dataID, _ := doc.Map()["id"].(string); dataID != id {
+		return fmt.Errorf("%w: id cannot be changed", service.ErrBadRequest)
+	}
+	doc = withGrams(collection, doc)
+
+	filter, err := scope(ctx, bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	c := m.database.Collection(collection)
+	res, err := c.ReplaceOne(ctx, filter, doc)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%w: %v", service.ErrAlreadyExists, err)
+		}
+		return Unexpected(ctx, fmt.Errorf("replace one: %w", err))
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: %s %q", service.ErrNotFound, collection, id)
+	}
+	return nil
+}
+
+// Update implements the [EventsContainer] interface. The element is read and
+// replaced within a transaction, so that concurrent updates are not lost. If
+// the element is changed by another transaction in the meantime, then the
+// transaction is retried with the new version of the element.
+func (m *MongoDBContainer) Update(
+	ctx context.Context,
+	collection string,
+	id string,
+	patch []byte,
+) (any, error) {
+	var updated any
+	err := m.WithTransaction(ctx, func(ctx context.Context) error {
+		// Get the current version of the element and apply the patch to it.
+		elem, err := m.findOne(ctx, collection, "id", id)
+		if err != nil {
+			return err
+		}
+		if updated, err = ApplyMergePatch(elem, patch); err != nil {
+			return fmt.Errorf("apply patch: %w", err)
+		}
+		return m.Replace(ctx, collection, id, updated)
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // errors of fn are returned as is
+	}
+	return updated, nil
+}
+
+// Delete implements the [EventsContainer] interface.
+func (m *MongoDBContainer) Delete(
+	ctx context.Context,
+	collection string,
+	id string,
+) error {
+	if !isKnown(collection) {
+		return fmt.Errorf(
+			"%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+
+	filter, err := scope(ctx, bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	c := m.database.Collection(collection)
+	res, err := c.DeleteOne(ctx, filter)
+	if err != nil {
+		return Unexpected(ctx, fmt.Errorf("delete one: %w", err))
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%w: %s %q", service.ErrNotFound, collection, id)
+	}
+	return nil
+}
+
+// CountEvents implements the [EventsContainer] interface.
+func (m *MongoDBContainer) CountEvents(ctx context.Context, filter *Filter) (int, error) {
+	q, err := scope(ctx, toBSON(filter))
+	if err != nil {
+		return 0, err
+	}
+	c := m.database.Collection(EventsCollection)
+	n, err := c.CountDocuments(ctx, q)
+	if err != nil {
+		return 0, Unexpected(ctx, fmt.Errorf("count documents: %w", err))
+	}
+	return int(n), nil
+}
+
 func (m *MongoDBContainer) findOne(
 	ctx context.Context,
 	collection string,
 	filterKey string,
 	filterValue any,
 ) (any, error) {
-	// Get the element from the collection.
+	if !isKnown(collection) {
+		return nil, fmt.Errorf(
+			"%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+
+	// Get the element of the tenant from the collection.
+	filter, err := scope(ctx, bson.M{filterKey: filterValue})
+	if err != nil {
+		return nil, err
+	}
 	c := m.database.Collection(collection)
-	one := c.FindOne(ctx, bson.M{filterKey: filterValue})
+	one := c.FindOne(ctx, filter)
 	if err := one.Err(); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, fmt.Errorf("%w: %v", service.ErrNotFound, err)
 		}
-		return nil, service.Unexpected(ctx, fmt.Errorf("find one: %w", err))
+		return nil, Unexpected(ctx, fmt.Errorf("find one: %w", err))
 	}
 
 	// Infer the type of the requested element and decode it.
@@ -163,13 +404,25 @@ func (m *MongoDBContainer) findOne(
 	case EventsCollection:
 		var elem Event
 		if err := one.Decode(&elem); err != nil {
-			return nil, service.Unexpected(ctx, fmt.Errorf("decode one: %w", err))
+			return nil, Unexpected(ctx, fmt.Errorf("decode one: %w", err))
 		}
 		return elem, nil
 	case LocationsCollection:
 		var elem Location
 		if err := one.Decode(&elem); err != nil {
-			return nil, service.Unexpected(ctx, fmt.Errorf("decode one: %w", err))
+			return nil, Unexpected(ctx, fmt.Errorf("decode one: %w", err))
+		}
+		return elem, nil
+	case OutboxCollection:
+		var elem OutboxRecord
+		if err := one.Decode(&elem); err != nil {
+			return nil, Unexpected(ctx, fmt.Errorf("decode one: %w", err))
+		}
+		return elem, nil
+	case BookingsCollection:
+		var elem Booking
+		if err := one.Decode(&elem); err != nil {
+			return nil, Unexpected(ctx, fmt.Errorf("decode one: %w", err))
 		}
 		return elem, nil
 	default:
@@ -178,6 +431,18 @@ func (m *MongoDBContainer) findOne(
 	}
 }
 
+// Ping implements the [EventsContainer] interface. It pings the primary of the
+// replica set, since the writes go to the primary.
+func (m *MongoDBContainer) Ping(ctx context.Context) error {
+	if err := m.client.Ping(ctx, readpref.Primary()); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err() //nolint:wrapcheck // context errors are returned as is
+		}
+		return fmt.Errorf("%w: ping mongo: %v", service.ErrConnectionClosed, err)
+	}
+	return nil
+}
+
 // Close implements the [io.Closer] interface.
 func (m *MongoDBContainer) Close() error {
 	// Disconnect the client by waiting up to 10 seconds for
@@ -185,11 +450,21 @@ func (m *MongoDBContainer) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) //nolint:gomnd // intentional
 	defer cancel()
 	if err := m.client.Disconnect(ctx); err != nil {
-		return service.Unexpected(ctx, err)
+		return Unexpected(ctx, err)
 	}
 	return nil
 }
 
+// isKnown returns true if the given collection is stored in the container.
+func isKnown(collection string) bool {
+	switch collection {
+	case EventsCollection, LocationsCollection, OutboxCollection, BookingsCollection:
+		return true
+	default:
+		return false
+	}
+}
+
 var (
 	// Use a singleton to make sure only one connection is open.
 	once   sync.Once
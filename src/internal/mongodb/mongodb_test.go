@@ -0,0 +1,58 @@
+//go:build integration
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/containertest"
+)
+
+// TestConformance runs the conformance test suite against a Mongo database.
+// The database server is configured with the same environment variables as
+// the service. Every test uses a separate database, which is dropped at the
+// end of the test.
+func TestConformance(t *testing.T) {
+	port, err := strconv.Atoi(getenv("MONGO_DB_PORT", "27017"))
+	if err != nil {
+		t.Fatalf("parse port: %v", err)
+	}
+
+	containertest.Run(t, func(t *testing.T) EventsContainer {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		m, err := NewMongoDBContainer(ctx, &Config{
+			Host:     getenv("MONGO_DB_HOST", "mongodb"),
+			Port:     port,
+			Username: getenv("MONGO_DB_USERNAME", "eventsservice"),
+			Password: getenv("MONGO_DB_PASSWORD", "mongo_password"),
+			Database: fmt.Sprintf("conformance_%s", strings.ReplaceAll(NewID(), "-", "")),
+		})
+		if err != nil {
+			t.Fatalf("NewMongoDBContainer() error = %v", err)
+		}
+
+		// Note that the container is not closed, since all containers
+		// share the same client.
+		t.Cleanup(func() {
+			if err := m.database.Drop(context.Background()); err != nil {
+				t.Errorf("drop database: %v", err)
+			}
+		})
+		return m
+	})
+}
+
+func getenv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	. "github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// WithTransaction implements the [Outbox] interface. The function fn is run
+// within a session of the Mongo client, and the context passed to it carries
+// the session. Note that transactions require the database server to be a
+// member of a replica set.
+//
+// The transaction is retried on transient errors, e.g. if it conflicts with a
+// concurrent transaction. Thus, fn might be executed more than once.
+func (m *MongoDBContainer) WithTransaction(
+	ctx context.Context,
+	fn func(context.Context) error,
+) error {
+	// Join the transaction of the context, if any.
+	if sc := mongo.SessionFromContext(ctx); sc != nil {
+		return fn(ctx)
+	}
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return Unexpected(ctx, fmt.Errorf("start session: %w", err))
+	}
+	defer session.EndSession(context.Background()) //nolint:contextcheck // intentional
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return err //nolint:wrapcheck // errors of fn are returned as is
+}
+
+// PendingOutbox implements the [Outbox] interface.
+func (m *MongoDBContainer) PendingOutbox(ctx context.Context, limit int) ([]OutboxRecord, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", service.ErrBadRequest)
+	}
+
+	c := m.database.Collection(OutboxCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := c.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("find: %w", err))
+	}
+
+	// Use context.Background() to ensure Close completes even if the ctx passed
+	// to this function has errored.
+	defer cursor.Close(context.Background()) //nolint:errcheck, contextcheck // intentional
+
+	res := make([]OutboxRecord, 0)
+	if err := cursor.All(ctx, &res); err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
+	}
+	return res, nil
+}
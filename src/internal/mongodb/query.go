@@ -0,0 +1,173 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	. "github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// QueryEvents implements the [EventsContainer] interface.
+func (m *MongoDBContainer) QueryEvents(ctx context.Context, q *Query) (*Page, error) {
+	if q.Limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", service.ErrBadRequest)
+	}
+	sortField, err := bsonName(string(q.Sort.Key()))
+	if err != nil {
+		return nil, err
+	}
+	dir := 1
+	if q.Sort.Desc() {
+		dir = -1
+	}
+
+	// Continue after the cursor encoded in the page token. The events are
+	// sorted by the sort field and the ties are broken by the event id.
+	filter, err := scope(ctx, toBSON(&q.Filter))
+	if err != nil {
+		return nil, err
+	}
+	if q.PageToken != "" {
+		cursor, err := ParsePageToken(q.Sort, q.PageToken)
+		if err != nil {
+			return nil, fmt.Errorf("parse page token: %w", err)
+		}
+		filter = bson.M{"$and": bson.A{filter, after(sortField, dir, cursor)}}
+	}
+
+	// Fetch one more event than requested to find out whether there is a
+	// next page.
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: dir}, {Key: "id", Value: dir}}).
+		SetLimit(int64(q.Limit) + 1)
+	if len(q.Fields) > 0 {
+		fields := append([]string{"id", string(q.Sort.Key())}, q.Fields...)
+		projection, err := project(fields)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetProjection(projection)
+	}
+
+	c := m.database.Collection(EventsCollection)
+	cur, err := c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("find: %w", err))
+	}
+
+	// Use context.Background() to ensure Close completes even if the ctx passed
+	// to this function has errored.
+	defer cur.Close(context.Background()) //nolint:errcheck, contextcheck // intentional
+
+	page := Page{Events: make([]Event, 0, q.Limit)}
+	if err := cur.All(ctx, &page.Events); err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
+	}
+	if len(page.Events) > q.Limit {
+		page.Events = page.Events[:q.Limit]
+		page.NextPageToken = NewPageToken(q.Sort, &page.Events[q.Limit-1])
+	}
+	return &page, nil
+}
+
+// ForEachEvent implements the [EventsContainer] interface. The events are
+// decoded one by one while iterating the cursor.
+func (m *MongoDBContainer) ForEachEvent(
+	ctx context.Context,
+	filter *Filter,
+	fn func(*Event) error,
+) error {
+	q, err := scope(ctx, toBSON(filter))
+	if err != nil {
+		return err
+	}
+	c := m.database.Collection(EventsCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: 1}})
+	cur, err := c.Find(ctx, q, opts)
+	if err != nil {
+		return Unexpected(ctx, fmt.Errorf("find: %w", err))
+	}
+
+	// Use context.Background() to ensure Close completes even if the ctx passed
+	// to this function has errored.
+	defer cur.Close(context.Background()) //nolint:errcheck, contextcheck // intentional
+
+	for cur.Next(ctx) {
+		var e Event
+		if err := cur.Decode(&e); err != nil {
+			return Unexpected(ctx, fmt.Errorf("decode: %w", err))
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return Unexpected(ctx, fmt.Errorf("cursor: %w", err))
+	}
+	return nil
+}
+
+// after returns a filter matching the events that come after the cursor, when
+// sorting by the given field in the given direction.
+func after(sortField string, dir int, cursor *Cursor) bson.M {
+	op := "$gt"
+	if dir < 0 {
+		op = "$lt"
+	}
+	if sortField == "id" {
+		return bson.M{"id": bson.M{op: cursor.ID}}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{sortField: bson.M{op: cursor.Value}},
+		bson.M{sortField: cursor.Value, "id": bson.M{op: cursor.ID}},
+	}}
+}
+
+// project returns a projection document including the fields with the given
+// json paths. Note that mongo rejects projections including both a field and
+// one of its sub-fields, thus sub-fields of included fields are left out.
+func project(fields []string) (bson.M, error) {
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		name, err := bsonName(f)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	projection := bson.M{}
+	for _, name := range names {
+		covered := false
+		for _, other := range names {
+			if strings.HasPrefix(name, other+".") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			projection[name] = 1
+		}
+	}
+	return projection, nil
+}
+
+// bsonName returns the name of the field of the stored event documents that
+// corresponds to the given json path. Note that the stored documents use the
+// lower-cased names of the struct fields, e.g. "startdate" for "start_date".
+// This function returns [service.ErrBadRequest] if the path does not exist.
+func bsonName(jsonPath string) (string, error) {
+	if jsonPath == "" {
+		return "id", nil
+	}
+	names, ok := EventField(jsonPath)
+	if !ok {
+		return "", fmt.Errorf("%w: unknown field %q", service.ErrBadRequest, jsonPath)
+	}
+	return strings.ToLower(strings.Join(names, ".")), nil
+}
@@ -0,0 +1,195 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	. "github.com/eventscompass/events-service/src/internal"
+)
+
+// gramsField is the field of the stored events and locations that holds the
+// grams of their names, and of the addresses of the locations, see
+// [WordGrams]. It is indexed for narrowing down the events that are ranked by
+// a search, and the locations that match it.
+const gramsField = "searchgrams"
+
+// SearchEvents implements the [Searcher] interface.
+//
+// Mongo text indexes match whole words only and do not tolerate typos. Thus,
+// the candidate events are ranked by a [Ranker], the same way as in-memory
+// implementations do. The candidates are the events whose name shares a gram
+// with every term of the query, or which take place at a location matching
+// the term. Only the most relevant events are kept in memory.
+func (m *MongoDBContainer) SearchEvents(
+	ctx context.Context,
+	query string,
+	limit int,
+) ([]SearchHit, error) {
+	grams := NewRanker(query, limit, nil).TermGrams()
+	if len(grams) == 0 {
+		return nil, nil
+	}
+
+	// A location matching a term shares a gram with the term, so only the
+	// locations sharing a gram with any of the terms are matched against the
+	// query. They also fill in the matching events that only reference their
+	// location, before the events are ranked.
+	var all []string
+	for _, g := range grams {
+		all = append(all, g...)
+	}
+	locations, err := m.findLocations(ctx, bson.M{gramsField: bson.M{"$in": all}})
+	if err != nil {
+		return nil, err
+	}
+	r := NewRanker(query, limit, locations)
+	matching := r.MatchingLocations(locations)
+	terms := make(bson.A, len(grams))
+	for i := range grams {
+		terms[i] = bson.M{"$or": bson.A{
+			bson.M{gramsField: bson.M{"$in": grams[i]}},
+			bson.M{"location.id": bson.M{"$in": matching[i]}},
+		}}
+	}
+
+	filter, err := scope(ctx, bson.M{"$and": terms})
+	if err != nil {
+		return nil, err
+	}
+	c := m.database.Collection(EventsCollection)
+	cursor, err := c.Find(ctx, filter)
+	if err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("find: %w", err))
+	}
+
+	// Use context.Background() to ensure Close completes even if the ctx passed
+	// to this function has errored.
+	defer cursor.Close(context.Background()) //nolint:errcheck, contextcheck // intentional
+
+	for cursor.Next(ctx) {
+		var e Event
+		if err := cursor.Decode(&e); err != nil {
+			return nil, Unexpected(ctx, fmt.Errorf("decode: %w", err))
+		}
+		r.Add(&e)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("cursor next: %w", err))
+	}
+	hits := r.Hits()
+	if err := m.fillHitLocations(ctx, hits); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// fillHitLocations fills in the location data of the hits whose events only
+// reference a location that does not match the query, and was therefore not
+// retrieved before ranking the events.
+func (m *MongoDBContainer) fillHitLocations(ctx context.Context, hits []SearchHit) error {
+	var ids []string
+	for _, h := range hits {
+		if h.Event.Location.ID != "" && h.Event.Location.Name == "" {
+			ids = append(ids, h.Event.Location.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	locations, err := m.findLocations(ctx, bson.M{"id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+	for i := range hits {
+		e := &hits[i].Event
+		for _, l := range locations {
+			if e.Location.Name == "" && e.Location.ID == l.ID {
+				e.Location = l
+			}
+		}
+	}
+	return nil
+}
+
+// findLocations returns the locations of the tenant of the context matching
+// the given query.
+func (m *MongoDBContainer) findLocations(ctx context.Context, q bson.M) ([]Location, error) {
+	filter, err := scope(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := m.database.Collection(LocationsCollection).Find(ctx, filter)
+	if err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("find: %w", err))
+	}
+
+	// Use context.Background() to ensure Close completes even if the ctx passed
+	// to this function has errored.
+	defer cursor.Close(context.Background()) //nolint:errcheck, contextcheck // intentional
+
+	res := make([]Location, 0)
+	if err := cursor.All(ctx, &res); err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("cursor all: %w", err))
+	}
+	return res, nil
+}
+
+// withGrams adds the grams of the name of the event or of the name and the
+// address of the location to the given document, which is about to be stored
+// in the given collection. Other documents are returned unchanged.
+func withGrams(collection string, doc bson.D) bson.D {
+	if collection != EventsCollection && collection != LocationsCollection {
+		return doc
+	}
+	fields := doc.Map()
+	name, _ := fields["name"].(string)
+	address, _ := fields["address"].(string)
+	return append(doc, bson.E{Key: gramsField, Value: WordGrams(name + " " + address)})
+}
+
+// indexGrams adds the grams of their names and addresses to the events and the
+// locations that were stored before they were indexed for searching. Indexing
+// a database whose entries were already indexed changes nothing.
+func indexGrams(ctx context.Context, database *mongo.Database) error {
+	for _, collection := range []string{EventsCollection, LocationsCollection} {
+		if err := indexCollectionGrams(ctx, database.Collection(collection)); err != nil {
+			return fmt.Errorf("%s: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// indexCollectionGrams adds the grams to the entries of the collection that
+// have none, see [indexGrams].
+func indexCollectionGrams(ctx context.Context, c *mongo.Collection) error {
+	cursor, err := c.Find(ctx, bson.M{gramsField: bson.M{"$exists": false}})
+	if err != nil {
+		return fmt.Errorf("find: %w", err)
+	}
+
+	// Use context.Background() to ensure Close completes even if the ctx passed
+	// to this function has errored.
+	defer cursor.Close(context.Background()) //nolint:errcheck, contextcheck // intentional
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID      any    `bson:"_id"`
+			Name    string `bson:"name"`
+			Address string `bson:"address"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		update := bson.M{"$set": bson.M{gramsField: WordGrams(doc.Name + " " + doc.Address)}}
+		if _, err := c.UpdateByID(ctx, doc.ID, update); err != nil {
+			return fmt.Errorf("update by id: %w", err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor next: %w", err)
+	}
+	return nil
+}
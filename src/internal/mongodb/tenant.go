@@ -0,0 +1,114 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	. "github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// tenantField is the field of the stored documents that holds their tenant.
+const tenantField = "tenant"
+
+// scope adds to the given query document the condition that matches only the
+// documents of the tenant of the context. This function returns
+// [service.ErrNotAllowed] if the context holds no tenant.
+func scope(ctx context.Context, q bson.M) (bson.M, error) {
+	tenant, err := RequireTenant(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // intentional
+	}
+	q[tenantField] = tenant
+	return q, nil
+}
+
+// withTenant encodes the data into a document that belongs to the tenant of the
+// context. This function returns [service.ErrNotAllowed] if the context holds no
+// tenant.
+func withTenant(ctx context.Context, data any) (bson.D, error) {
+	tenant, err := RequireTenant(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // intentional
+	}
+	raw, err := bson.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: encode data: %v", service.ErrBadRequest, err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%w: decode data: %v", service.ErrBadRequest, err)
+	}
+
+	// Note that some entries, e.g. the outbox records, hold their tenant
+	// already, which is overwritten.
+	for i := range doc {
+		if doc[i].Key == tenantField {
+			doc[i].Value = tenant
+			return doc, nil
+		}
+	}
+	return append(doc, bson.E{Key: tenantField, Value: tenant}), nil
+}
+
+// migrate prepares the database for storing the entries of many tenants. The
+// documents stored before the container became aware of tenants are assigned
+// to the [DefaultTenant]. Migrating a database that was already migrated
+// changes nothing.
+func migrate(ctx context.Context, database *mongo.Database) error {
+	collections := []string{
+		EventsCollection, LocationsCollection, OutboxCollection, BookingsCollection,
+	}
+	for _, collection := range collections {
+		c := database.Collection(collection)
+		_, err := c.UpdateMany(ctx,
+			bson.M{tenantField: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{tenantField: DefaultTenant}})
+		if err != nil {
+			return fmt.Errorf("assign %s to the default tenant: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// Tenants implements the [EventsContainer] interface.
+func (m *MongoDBContainer) Tenants(ctx context.Context, collection string) ([]string, error) {
+	if !isKnown(collection) {
+		return nil, fmt.Errorf(
+			"%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+
+	c := m.database.Collection(collection)
+	values, err := c.Distinct(ctx, tenantField, bson.D{})
+	if err != nil {
+		return nil, Unexpected(ctx, fmt.Errorf("distinct: %w", err))
+	}
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		if tenant, ok := v.(string); ok {
+			res = append(res, tenant)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// CountAll implements the [EventsContainer] interface. The number is estimated
+// from the metadata of the collection, so that the documents are not scanned.
+func (m *MongoDBContainer) CountAll(ctx context.Context, collection string) (int, error) {
+	if !isKnown(collection) {
+		return 0, fmt.Errorf(
+			"%w: unknown collection %q", service.ErrNotAllowed, collection)
+	}
+
+	c := m.database.Collection(collection)
+	n, err := c.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return 0, Unexpected(ctx, fmt.Errorf("estimated document count: %w", err))
+	}
+	return int(n), nil
+}
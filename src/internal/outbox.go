@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxCollection is the collection of the container that holds the messages
+// waiting to be published to the message bus. Messages are written to the
+// outbox in the same transaction as the change they announce, and are later
+// published by a relay. Thus, a change is announced if and only if it is
+// committed.
+var OutboxCollection = "outbox"
+
+// OutboxRecord is a message waiting in the outbox to be published.
+type OutboxRecord struct {
+	// ID identifies the record. Records are published in the
+	// order of their ids, see [NewID].
+	ID string `json:"id"`
+
+	// Topic is the topic to which the message is published.
+	Topic string `json:"topic"`
+
+	// Payload is the encoded message.
+	Payload []byte `json:"payload"`
+
+	// CreatedAt is the time at which the record was created.
+	CreatedAt time.Time `json:"created_at"`
+
+	// Tenant is the tenant of the change announced by the
+	// message. It is set by the container to the tenant of the
+	// context in which the record is created.
+	Tenant string `json:"tenant"`
+
+	// TraceParent is the trace context of the change announced
+	// by the message, in the W3C traceparent format. It is empty
+	// if the change was not traced. The message is published
+	// within the same trace.
+	TraceParent string `json:"trace_parent,omitempty"`
+}
+
+// Outbox is implemented by containers that provide a transactional outbox.
+type Outbox interface {
+
+	// WithTransaction runs fn in a transaction. The changes that
+	// fn makes through the container, using the context passed
+	// to it, are either all committed or, if fn returns an error,
+	// all discarded. If the context already belongs to a
+	// transaction, then fn simply joins that transaction.
+	WithTransaction(_ context.Context, fn func(context.Context) error) error
+
+	// PendingOutbox retrieves at most limit records from the
+	// outbox collection of all the tenants, ordered by their ids.
+	// Records are removed from the outbox with the Delete method,
+	// within the tenant of the record, once they are published.
+	// This function returns [service.ErrBadRequest] if the limit
+	// is not positive.
+	PendingOutbox(_ context.Context, limit int) ([]OutboxRecord, error)
+}
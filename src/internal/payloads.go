@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"time"
+)
+
+// The topics and payloads in this file complement the ones defined in the
+// [pubsub] package of the service framework. They are published on the
+// [pubsub.EventsExchange] exchange.
+
+var (
+	// EventUpdatedTopic is the routing key with which messages
+	// about updated events will be published.
+	EventUpdatedTopic = "event.updated"
+
+	// EventDeletedTopic is the routing key with which messages
+	// about deleted events will be published.
+	EventDeletedTopic = "event.deleted"
+
+	// LocationUpdatedTopic is the routing key with which messages
+	// about updated locations will be published.
+	LocationUpdatedTopic = "location.updated"
+)
+
+// EventUpdated is the payload for notifying for the update of an event.
+type EventUpdated struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	LocationID string    `json:"location_id"`
+	Start      time.Time `json:"start_time"`
+	End        time.Time `json:"end_time"`
+}
+
+// EventDeleted is the payload for notifying for the deletion of an event.
+type EventDeleted struct {
+	ID string `json:"id"`
+}
+
+// LocationUpdated is the payload for notifying for the update of a location.
+type LocationUpdated struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// Query describes a page of events to be retrieved from the container.
+type Query struct {
+	// Filter restricts the events that are retrieved.
+	Filter Filter
+
+	// Sort is the order in which the events are retrieved.
+	Sort Sort
+
+	// Fields lists the json names of the fields of the events
+	// that should be retrieved, e.g. "name" or "location.name".
+	// The remaining fields might be left empty. If no fields are
+	// given, then the events are retrieved in full.
+	Fields []string
+
+	// Limit is the maximum number of events in the page.
+	Limit int
+
+	// PageToken is the token of the page to be retrieved, as
+	// returned by a previous query with the same sort order. An
+	// empty token retrieves the first page.
+	PageToken string
+}
+
+// Page is a page of events retrieved from the container.
+type Page struct {
+	// Events are the events of the page.
+	Events []Event
+
+	// NextPageToken is the token for retrieving the next page,
+	// or empty if this is the last page.
+	NextPageToken string
+}
+
+// Sort is the order in which events are retrieved from the container. Ties are
+// broken by the id of the events.
+type Sort string
+
+// The sort orders supported by the container. Prefixing a sort order with "-"
+// reverses it.
+const (
+	SortByID        Sort = ""
+	SortByStartDate Sort = "start_date"
+	SortByName      Sort = "name"
+)
+
+// ParseSort parses the given sort order. This function returns
+// [service.ErrBadRequest] if the sort order is not supported.
+func ParseSort(s string) (Sort, error) {
+	switch Sort(strings.TrimPrefix(s, "-")) {
+	case SortByID, SortByStartDate, SortByName:
+		return Sort(s), nil
+	default:
+		return "", fmt.Errorf("%w: unsupported sort order %q", service.ErrBadRequest, s)
+	}
+}
+
+// Key returns the sort order without its direction.
+func (s Sort) Key() Sort {
+	return Sort(strings.TrimPrefix(string(s), "-"))
+}
+
+// Desc returns true if the sort order is descending.
+func (s Sort) Desc() bool {
+	return strings.HasPrefix(string(s), "-")
+}
+
+// Value returns the value of the given event that is used for sorting. The
+// returned value is either a string or a [time.Time].
+func (s Sort) Value(e *Event) any {
+	switch s.Key() {
+	case SortByStartDate:
+		return e.StartDate
+	case SortByName:
+		return e.Name
+	default:
+		return e.ID
+	}
+}
+
+// Compare compares the event to the cursor in the sort order. It returns
+// a negative number if the event comes before the cursor, and a positive
+// number if the event comes after the cursor.
+func (s Sort) Compare(e *Event, c *Cursor) int {
+	var res int
+	switch v := s.Value(e).(type) {
+	case time.Time:
+		cv, _ := c.Value.(time.Time) //nolint:errcheck // zero value is fine
+		res = v.Compare(cv)
+	case string:
+		cv, _ := c.Value.(string) //nolint:errcheck // zero value is fine
+		res = strings.Compare(v, cv)
+	}
+	if res == 0 || s.Key() == SortByID {
+		res = strings.Compare(e.ID, c.ID)
+	}
+	if s.Desc() {
+		res = -res
+	}
+	return res
+}
+
+// Cursor is the position in a sorted sequence of events after which the next
+// page starts.
+type Cursor struct {
+	// Value is the sort value of the last event of the previous
+	// page. It is either a string or a [time.Time].
+	Value any
+
+	// ID is the id of the last event of the previous page.
+	ID string
+}
+
+// pageToken is the decoded form of a page token.
+type pageToken struct {
+	Sort  Sort   `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// NewPageToken creates the token for the page that starts after the given
+// event in the given sort order. Page tokens are opaque to the clients.
+func NewPageToken(s Sort, last *Event) string {
+	t := pageToken{Sort: s, ID: last.ID}
+	switch v := s.Value(last).(type) {
+	case time.Time:
+		t.Value = v.UTC().Format(time.RFC3339Nano)
+	case string:
+		t.Value = v
+	}
+	raw, _ := json.Marshal(&t) //nolint:errcheck // cannot fail
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// ParsePageToken decodes the given page token into a cursor. The token must
+// have been created for the given sort order. This function returns
+// [service.ErrBadRequest] if the token is not valid.
+func ParsePageToken(s Sort, token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed page token: %v", service.ErrBadRequest, err)
+	}
+	var t pageToken
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return nil, fmt.Errorf("%w: malformed page token: %v", service.ErrBadRequest, err)
+	}
+	if t.Sort != s {
+		return nil, fmt.Errorf(
+			"%w: page token was issued for sort order %q", service.ErrBadRequest, t.Sort)
+	}
+
+	c := Cursor{Value: t.Value, ID: t.ID}
+	if s.Key() == SortByStartDate {
+		v, err := time.Parse(time.RFC3339Nano, t.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed page token: %v", service.ErrBadRequest, err)
+		}
+		c.Value = v
+	}
+	return &c, nil
+}
+
+// EventField returns the names of the struct fields of [Event] along the given
+// dot-separated path of json names. For example, "location.name" resolves to
+// ["Location", "Name"]. Paths can reach into the elements of slices, e.g.
+// "location.halls.capacity". The second return value is false if the path does
+// not exist.
+func EventField(path string) ([]string, bool) {
+	t := reflect.TypeOf(Event{})
+	var names []string
+	for _, part := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		f, ok := fieldByJSONName(t, part)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, f.Name)
+		t = f.Type
+	}
+	return names, true
+}
+
+// fieldByJSONName returns the field of the struct type t with the given json
+// name.
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == name && f.IsExported() {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	// The zones of the recurrences are loaded by name, and the service image
+	// has no zone database.
+	_ "time/tzdata"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// Recurrence describes how an event recurs. A recurring event is a series of
+// occurrences, the first of which takes place at the start and end dates of
+// the event. The occurrences are not stored, but expanded from the rule.
+type Recurrence struct {
+	// RRule is the recurrence rule of the series in the format
+	// of RFC 5545, e.g. "FREQ=WEEKLY;BYDAY=TU;COUNT=10".
+	RRule string `json:"rrule"`
+
+	// TZID is the IANA time zone in which the rule is expanded,
+	// e.g. "Europe/Amsterdam". The occurrences start at the same
+	// local time of the day in this zone, even across daylight
+	// saving time changes. The rule is expanded in UTC if empty.
+	TZID string `json:"tzid,omitempty"`
+
+	// ExDates are the original start dates of the occurrences
+	// that were removed from the series.
+	ExDates []time.Time `json:"exdates,omitempty"`
+
+	// Exceptions are the occurrences that were changed.
+	Exceptions []Exception `json:"exceptions,omitempty"`
+
+	// Announced is the time up to which the creation of the
+	// occurrences was announced, i.e. the occurrences starting
+	// before this time were announced. It is maintained by the
+	// service.
+	Announced time.Time `json:"announced,omitempty"`
+}
+
+// Exception is a change to an occurrence of a recurring event. If
+// ThisAndFuture is set, then the change applies to all the following
+// occurrences as well. In that case the start and end dates of the following
+// occurrences are moved by the same amount as those of the changed occurrence.
+type Exception struct {
+	// RecurrenceID is the original start date of the changed
+	// occurrence.
+	RecurrenceID  time.Time `json:"recurrence_id"`
+	ThisAndFuture bool      `json:"this_and_future,omitempty"`
+
+	Name      string    `json:"name"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	Hall      string    `json:"hall"`
+}
+
+// occurrenceIDLayout is the layout of the original start date within the id
+// of an occurrence.
+const occurrenceIDLayout = "20060102T150405Z"
+
+// OccurrenceID returns the id of the occurrence of the series with the given
+// id, which originally starts at the given time. The id is stable, i.e. it
+// does not change if the occurrence is moved.
+func OccurrenceID(seriesID string, recurrenceID time.Time) string {
+	return seriesID + "_" + recurrenceID.UTC().Format(occurrenceIDLayout)
+}
+
+// ParseOccurrenceID splits the id of an occurrence into the id of its series
+// and its original start date. The last return value is false if the id is
+// not the id of an occurrence.
+func ParseOccurrenceID(id string) (string, time.Time, bool) {
+	i := strings.LastIndexByte(id, '_')
+	if i <= 0 {
+		return "", time.Time{}, false
+	}
+	t, err := time.Parse(occurrenceIDLayout, id[i+1:])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return id[:i], t, true
+}
+
+// Occurrences returns the occurrences of the recurring event that overlap with
+// the time between from and to, ordered by their original start dates. At most
+// limit occurrences are returned. A non-recurring event is returned as is, if
+// it overlaps with the given time.
+func (e *Event) Occurrences(from, to time.Time, limit int) ([]Event, error) {
+	if e.Recurrence == nil {
+		if e.StartDate.Before(to) && e.EndDate.After(from) {
+			return []Event{*e}, nil
+		}
+		return nil, nil
+	}
+
+	// Occurrences can be moved by exceptions, so the expansion has to
+	// continue beyond the end of the time by the largest move.
+	shift := e.Recurrence.maxShift()
+	var res []Event
+	err := e.iterate(func(t time.Time) bool {
+		if !t.Before(to.Add(shift)) || len(res) == limit {
+			return false
+		}
+		if o, ok := e.occurrence(t); ok && o.StartDate.Before(to) && o.EndDate.After(from) {
+			res = append(res, o)
+		}
+		return true
+	})
+	return res, err
+}
+
+// Occurrence returns the occurrence of the recurring event, which originally
+// starts at the given time. The second return value is false if there is no
+// such occurrence.
+func (e *Event) Occurrence(recurrenceID time.Time) (Event, bool, error) {
+	if e.Recurrence == nil {
+		return Event{}, false, nil
+	}
+	var res Event
+	var found bool
+	err := e.iterate(func(t time.Time) bool {
+		if t.Equal(recurrenceID) {
+			res, found = e.occurrence(t)
+		}
+		return t.Before(recurrenceID)
+	})
+	return res, found, err
+}
+
+// iterate calls yield with the original start times of the occurrences of the
+// recurring event in UTC, until yield returns false or the series ends. See
+// [Rule.Iterate]. This function returns [service.ErrBadRequest] if the rule or
+// the time zone of the recurrence is not valid.
+func (e *Event) iterate(yield func(time.Time) bool) error {
+	rule, err := ParseRule(e.Recurrence.RRule)
+	if err != nil {
+		return err
+	}
+	loc, err := e.Recurrence.Location()
+	if err != nil {
+		return err
+	}
+	rule.Iterate(e.StartDate.In(loc), func(t time.Time) bool {
+		return yield(t.UTC())
+	})
+	return nil
+}
+
+// Location returns the time zone in which the rule of the recurrence is
+// expanded. See [Recurrence.TZID]. This function returns
+// [service.ErrBadRequest] if the zone is not a known IANA time zone.
+func (r *Recurrence) Location() (*time.Location, error) {
+	if r.TZID == "" {
+		return time.UTC, nil
+	}
+
+	// The local zone of the service is not a zone of the events.
+	if r.TZID == "Local" {
+		return nil, fmt.Errorf("%w: unknown time zone %q", service.ErrBadRequest, r.TZID)
+	}
+	loc, err := time.LoadLocation(r.TZID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", service.ErrBadRequest, r.TZID)
+	}
+	return loc, nil
+}
+
+// occurrence returns the occurrence of the recurring event, which originally
+// starts at the given time, with the exceptions applied. The second return
+// value is false if the occurrence was removed.
+func (e *Event) occurrence(t time.Time) (Event, bool) {
+	if slices.ContainsFunc(e.Recurrence.ExDates, t.Equal) {
+		return Event{}, false
+	}
+
+	o := *e
+	o.ID = OccurrenceID(e.ID, t)
+	o.Recurrence = nil
+	o.SeriesID = e.ID
+	o.RecurrenceID = &t
+	o.StartDate, o.EndDate = t, t.Add(e.EndDate.Sub(e.StartDate))
+
+	// Apply the latest change to this and the following occurrences, and
+	// then the change to this occurrence only.
+	following, single := e.Recurrence.exceptionsAt(t)
+	if x := following; x != nil {
+		o.Name, o.Hall = x.Name, x.Hall
+		o.StartDate = t.Add(x.StartDate.Sub(x.RecurrenceID))
+		o.EndDate = o.StartDate.Add(x.EndDate.Sub(x.StartDate))
+	}
+	if x := single; x != nil {
+		o.Name, o.Hall = x.Name, x.Hall
+		o.StartDate, o.EndDate = x.StartDate, x.EndDate
+	}
+	if o.Duration != 0 {
+		o.Duration = o.EndDate.Sub(o.StartDate)
+	}
+	return o, true
+}
+
+// exceptionsAt returns the exceptions that apply to the occurrence, which
+// originally starts at the given time: the latest change to this and the
+// following occurrences, and the change to this occurrence only. Either can be
+// nil.
+func (r *Recurrence) exceptionsAt(t time.Time) (following, single *Exception) {
+	for i, x := range r.Exceptions {
+		switch {
+		case x.RecurrenceID.After(t):
+		case x.ThisAndFuture && (following == nil || x.RecurrenceID.After(following.RecurrenceID)):
+			following = &r.Exceptions[i]
+		case !x.ThisAndFuture && x.RecurrenceID.Equal(t):
+			single = &r.Exceptions[i]
+		}
+	}
+	return following, single
+}
+
+// maxShift returns the largest amount by which an exception moves an
+// occurrence to an earlier time.
+func (r *Recurrence) maxShift() time.Duration {
+	var shift time.Duration
+	for _, x := range r.Exceptions {
+		shift = max(shift, x.RecurrenceID.Sub(x.StartDate))
+	}
+	return shift
+}
+
+// SetException records the given exception. It replaces the exceptions that
+// it overrides, i.e. those for the same occurrence and, if the exception
+// applies to the following occurrences, the later exceptions that apply to
+// the following occurrences as well.
+func (r *Recurrence) SetException(x Exception) {
+	r.Exceptions = slices.DeleteFunc(r.Exceptions, func(y Exception) bool {
+		return y.RecurrenceID.Equal(x.RecurrenceID) ||
+			(x.ThisAndFuture && y.ThisAndFuture && y.RecurrenceID.After(x.RecurrenceID))
+	})
+	r.Exceptions = append(r.Exceptions, x)
+	slices.SortFunc(r.Exceptions, func(a, b Exception) int {
+		return a.RecurrenceID.Compare(b.RecurrenceID)
+	})
+}
+
+// Exclude removes the occurrence, which originally starts at the given time,
+// from the series.
+func (r *Recurrence) Exclude(recurrenceID time.Time) {
+	r.ExDates = append(r.ExDates, recurrenceID)
+	r.Exceptions = slices.DeleteFunc(r.Exceptions, func(x Exception) bool {
+		return !x.ThisAndFuture && x.RecurrenceID.Equal(recurrenceID)
+	})
+}
+
+// Truncate ends the series before the occurrence, which originally starts at
+// the given time. This function returns [service.ErrBadRequest] if the rule of
+// the series is not valid.
+func (r *Recurrence) Truncate(recurrenceID time.Time) error {
+	rule, err := ParseRule(r.RRule)
+	if err != nil {
+		return err
+	}
+	rule.Count, rule.Until = 0, recurrenceID.Add(-time.Second)
+	r.RRule = rule.String()
+	r.ExDates = slices.DeleteFunc(r.ExDates, func(t time.Time) bool {
+		return !t.Before(recurrenceID)
+	})
+	r.Exceptions = slices.DeleteFunc(r.Exceptions, func(x Exception) bool {
+		return !x.RecurrenceID.Before(recurrenceID)
+	})
+	return nil
+}
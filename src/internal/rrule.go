@@ -0,0 +1,474 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// Frequency is the frequency at which a recurrence rule repeats.
+type Frequency string
+
+// The frequencies supported by recurrence rules.
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// WeekdayNum is a day of the week as used by the BYDAY rule part. A non-zero
+// N selects the N-th such day within the month, counting from the end of the
+// month if N is negative, e.g. "-1FR" is the last Friday of the month.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// Rule is a recurrence rule as defined by RFC 5545. Only the rule parts FREQ,
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH are supported. Rules
+// are evaluated in the time zone of the start time of their first occurrence,
+// see [Rule.Iterate].
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// maxRecurrenceYears bounds the expansion of rules, which would otherwise
+// never end if they do not match any day, e.g. "FREQ=YEARLY;BYMONTH=2;
+// BYMONTHDAY=30".
+const maxRecurrenceYears = 100
+
+// The limits of the values of the rule parts.
+const (
+	maxInterval  = 1000
+	maxCount     = 10000
+	maxWeekNum   = 5
+	maxMonthDay  = 31
+	monthsInYear = 12
+	daysInWeek   = 7
+)
+
+// dayLength is the length of a day in UTC.
+const dayLength = 24 * time.Hour
+
+// weekdays maps the two-letter day names of RFC 5545 to weekdays.
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseRule parses the given recurrence rule, e.g. "FREQ=WEEKLY;BYDAY=TU,TH".
+// The value may be prefixed with "RRULE:". This function returns
+// [service.ErrBadRequest] if the rule is not valid or not supported.
+func ParseRule(s string) (*Rule, error) {
+	r := Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(strings.TrimPrefix(s, "RRULE:"), ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: malformed rule part %q", service.ErrBadRequest, part)
+		}
+		key = strings.ToUpper(key)
+		if seen[key] {
+			return nil, fmt.Errorf("%w: duplicate rule part %q", service.ErrBadRequest, key)
+		}
+		seen[key] = true
+		if err := r.parsePart(key, strings.ToUpper(value)); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", service.ErrBadRequest, key, err)
+		}
+	}
+	if err := r.check(); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrBadRequest, err)
+	}
+	return &r, nil
+}
+
+// parsePart parses the value of a single rule part into the rule.
+func (r *Rule) parsePart(key, value string) error {
+	var err error
+	switch key {
+	case "FREQ":
+		r.Freq = Frequency(value)
+		if !slices.Contains([]Frequency{Daily, Weekly, Monthly, Yearly}, r.Freq) {
+			return fmt.Errorf("unsupported frequency %q", value)
+		}
+	case "INTERVAL":
+		r.Interval, err = parseInt(value, 1, maxInterval)
+	case "COUNT":
+		r.Count, err = parseInt(value, 1, maxCount)
+	case "UNTIL":
+		r.Until, err = parseUntil(value)
+	case "BYDAY":
+		r.ByDay, err = parseList(value, parseWeekdayNum)
+	case "BYMONTHDAY":
+		r.ByMonthDay, err = parseList(value, parseMonthDay)
+	case "BYMONTH":
+		r.ByMonth, err = parseList(value, parseMonth)
+	default:
+		return fmt.Errorf("unsupported rule part")
+	}
+	return err
+}
+
+// parseUntil parses the value of the UNTIL rule part, which is either a UTC
+// time or a date. A date includes the whole day.
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(icalTimeLayout, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed time %q", value)
+	}
+	return t.Add(dayLength - time.Second), nil
+}
+
+// parseWeekdayNum parses a day of the BYDAY rule part, e.g. "-1FR".
+func parseWeekdayNum(v string) (WeekdayNum, error) {
+	if len(v) < 2 {
+		return WeekdayNum{}, fmt.Errorf("malformed day %q", v)
+	}
+	day, ok := weekdays[v[len(v)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("malformed day %q", v)
+	}
+	wd := WeekdayNum{Day: day}
+	if n := v[:len(v)-2]; n != "" {
+		var err error
+		if wd.N, err = parseInt(n, -maxWeekNum, maxWeekNum); err != nil || wd.N == 0 {
+			return WeekdayNum{}, fmt.Errorf("malformed day %q", v)
+		}
+	}
+	return wd, nil
+}
+
+// parseMonthDay parses a day of the BYMONTHDAY rule part, e.g. "-1".
+func parseMonthDay(v string) (int, error) {
+	d, err := parseInt(v, -maxMonthDay, maxMonthDay)
+	if err != nil || d == 0 {
+		return 0, fmt.Errorf("malformed day of month %q", v)
+	}
+	return d, nil
+}
+
+// parseMonth parses a month of the BYMONTH rule part, e.g. "12".
+func parseMonth(v string) (time.Month, error) {
+	m, err := parseInt(v, 1, monthsInYear)
+	if err != nil {
+		return 0, fmt.Errorf("malformed month %q", v)
+	}
+	return time.Month(m), nil
+}
+
+// parseList parses a comma-separated list of values using the given function.
+func parseList[T any](value string, parse func(string) (T, error)) ([]T, error) {
+	var res []T
+	for _, v := range strings.Split(value, ",") {
+		x, err := parse(v)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, x)
+	}
+	return res, nil
+}
+
+// check makes sure that the rule parts are consistent with each other.
+func (r *Rule) check() error {
+	switch {
+	case r.Freq == "":
+		return fmt.Errorf("missing FREQ")
+	case r.Count > 0 && !r.Until.IsZero():
+		return fmt.Errorf("COUNT and UNTIL are mutually exclusive")
+	case r.Freq == Weekly && len(r.ByMonthDay) > 0:
+		return fmt.Errorf("BYMONTHDAY is not supported with FREQ=WEEKLY")
+	}
+	return r.checkByDay()
+}
+
+// checkByDay makes sure that the BYDAY rule part is consistent with the
+// frequency of the rule.
+func (r *Rule) checkByDay() error {
+	if r.Freq == Yearly && len(r.ByDay) > 0 && len(r.ByMonth) == 0 {
+		return fmt.Errorf("BYDAY requires BYMONTH with FREQ=YEARLY")
+	}
+	numbered := slices.ContainsFunc(r.ByDay, func(wd WeekdayNum) bool { return wd.N != 0 })
+	if numbered && r.Freq != Monthly && r.Freq != Yearly {
+		return fmt.Errorf("numbered BYDAY requires FREQ=MONTHLY, or FREQ=YEARLY with BYMONTH")
+	}
+	return nil
+}
+
+// String returns the rule in the format of RFC 5545.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(icalTimeLayout))
+	}
+	if len(r.ByDay) > 0 {
+		parts = append(parts, "BYDAY="+joinList(r.ByDay, func(wd WeekdayNum) string {
+			day := strings.ToUpper(wd.Day.String()[:2])
+			if wd.N != 0 {
+				day = strconv.Itoa(wd.N) + day
+			}
+			return day
+		}))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinList(r.ByMonthDay, strconv.Itoa))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinList(r.ByMonth, func(m time.Month) string {
+			return strconv.Itoa(int(m))
+		}))
+	}
+	return strings.Join(parts, ";")
+}
+
+// joinList formats the values using the given function and joins them into a
+// comma-separated list.
+func joinList[T any](values []T, format func(T) string) string {
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		res = append(res, format(v))
+	}
+	return strings.Join(res, ",")
+}
+
+// Iterate calls yield with the start times of the occurrences of the rule in
+// chronological order, beginning with the given start time of the first
+// occurrence, until yield returns false or the rule ends. Candidates before the
+// start time are skipped. The rule is evaluated in the location of the start
+// time, i.e. the days are those of the location, and the occurrences start at
+// the same local time of the day as the first one, even across daylight saving
+// time changes.
+func (r *Rule) Iterate(start time.Time, yield func(time.Time) bool) {
+	count := 0
+	for period := 0; ; period++ {
+		candidates := r.candidates(start, period)
+		if candidates == nil {
+			return
+		}
+		for _, c := range candidates {
+			if c.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && c.After(r.Until) {
+				return
+			}
+			count++
+			if !yield(c) || count == r.Count {
+				return
+			}
+		}
+	}
+}
+
+// candidates returns the sorted start times of the occurrences within the
+// given period of the rule, e.g. within the n-th week for a weekly rule. It
+// returns nil once the rule is expanded beyond [maxRecurrenceYears].
+func (r *Rule) candidates(start time.Time, period int) []time.Time {
+	n := period * r.Interval
+	var periodStart time.Time
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		periodStart, days = r.dailyCandidates(start, n)
+	case Weekly:
+		periodStart, days = r.weeklyCandidates(start, n)
+	case Monthly:
+		periodStart, days = r.monthlyCandidates(start, n)
+	case Yearly:
+		periodStart, days = r.yearlyCandidates(start, n)
+	}
+	if periodStart.Year() > start.Year()+maxRecurrenceYears {
+		return nil
+	}
+
+	// The occurrences start at the local time of the day of the start, which
+	// is not a fixed offset from midnight on days of a daylight saving change.
+	hour, minute, sec := start.Clock()
+	res := make([]time.Time, 0, len(days))
+	for _, d := range days {
+		y, m, day := d.Date()
+		res = append(res, time.Date(y, m, day, hour, minute, sec, start.Nanosecond(), d.Location()))
+	}
+	return res
+}
+
+// dailyCandidates returns the start of the n-th day after the start, and the
+// day itself if it matches the rule.
+func (r *Rule) dailyCandidates(start time.Time, n int) (time.Time, []time.Time) {
+	y, m, d := start.Date()
+	periodStart := time.Date(y, m, d+n, 0, 0, 0, 0, start.Location())
+	if r.matchMonth(periodStart) && r.matchMonthDay(periodStart) && r.matchWeekday(periodStart) {
+		return periodStart, []time.Time{periodStart}
+	}
+	return periodStart, nil
+}
+
+// weeklyCandidates returns the start of the n-th week after the start, and the
+// days of the week that match the rule. Weeks start on Monday.
+func (r *Rule) weeklyCandidates(start time.Time, n int) (time.Time, []time.Time) {
+	y, m, d := start.Date()
+	monday := d - (int(start.Weekday())+daysInWeek-1)%daysInWeek
+	periodStart := time.Date(y, m, monday+daysInWeek*n, 0, 0, 0, 0, start.Location())
+	var days []time.Time
+	for i := 0; i < daysInWeek; i++ {
+		day := periodStart.AddDate(0, 0, i)
+		match := day.Weekday() == start.Weekday()
+		if len(r.ByDay) > 0 {
+			match = r.matchWeekday(day)
+		}
+		if match && r.matchMonth(day) {
+			days = append(days, day)
+		}
+	}
+	return periodStart, days
+}
+
+// monthlyCandidates returns the start of the n-th month after the start, and
+// the days of the month that match the rule.
+func (r *Rule) monthlyCandidates(start time.Time, n int) (time.Time, []time.Time) {
+	y, m, d := start.Date()
+	periodStart := time.Date(y, m+time.Month(n), 1, 0, 0, 0, 0, start.Location())
+	if !r.matchMonth(periodStart) {
+		return periodStart, nil
+	}
+	return periodStart, r.daysOfMonth(periodStart, d)
+}
+
+// yearlyCandidates returns the start of the n-th year after the start, and the
+// days of the year that match the rule. Without BYMONTH, only the month of the
+// start is considered.
+func (r *Rule) yearlyCandidates(start time.Time, n int) (time.Time, []time.Time) {
+	y, m, d := start.Date()
+	periodStart := time.Date(y+n, time.January, 1, 0, 0, 0, 0, start.Location())
+	months := slices.Clone(r.ByMonth)
+	if len(months) == 0 {
+		months = []time.Month{m}
+	}
+	slices.Sort(months)
+	var days []time.Time
+	for _, month := range months {
+		first := time.Date(y+n, month, 1, 0, 0, 0, 0, start.Location())
+		days = append(days, r.daysOfMonth(first, d)...)
+	}
+	return periodStart, days
+}
+
+// daysOfMonth returns the sorted days of the month starting at first, which
+// match the BYMONTHDAY and BYDAY rule parts. If neither is given, then the
+// given day of the month is used, if the month has such a day.
+func (r *Rule) daysOfMonth(first time.Time, day int) []time.Time {
+	var candidates []int
+	switch n := first.AddDate(0, 1, -1).Day(); {
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			candidates = append(candidates, weekdaysOfMonth(first, wd)...)
+		}
+		slices.Sort(candidates)
+		candidates = slices.Compact(candidates)
+	case len(r.ByMonthDay) > 0:
+		for d := 1; d <= n; d++ {
+			candidates = append(candidates, d)
+		}
+	case day <= n:
+		candidates = []int{day}
+	}
+
+	var days []time.Time
+	for _, d := range candidates {
+		if date := first.AddDate(0, 0, d-1); r.matchMonthDay(date) {
+			days = append(days, date)
+		}
+	}
+	return days
+}
+
+// weekdaysOfMonth returns the days of the month starting at first, which match
+// the given day of the BYDAY rule part.
+func weekdaysOfMonth(first time.Time, wd WeekdayNum) []int {
+	n := first.AddDate(0, 1, -1).Day()
+	var days []int
+	// The first day of the month that falls on the weekday.
+	for d := 1 + (int(wd.Day)-int(first.Weekday())+daysInWeek)%daysInWeek; d <= n; d += daysInWeek {
+		days = append(days, d)
+	}
+	switch {
+	case wd.N == 0:
+		return days
+	case wd.N > 0 && wd.N <= len(days):
+		return days[wd.N-1 : wd.N]
+	case wd.N < 0 && -wd.N <= len(days):
+		return days[len(days)+wd.N : len(days)+wd.N+1]
+	default:
+		return nil
+	}
+}
+
+// matchMonth returns true if the day satisfies the BYMONTH rule part.
+func (r *Rule) matchMonth(day time.Time) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, day.Month())
+}
+
+// matchMonthDay returns true if the day satisfies the BYMONTHDAY rule part.
+func (r *Rule) matchMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	n := day.AddDate(0, 1, -day.Day()).Day()
+	for _, d := range r.ByMonthDay {
+		if d == day.Day() || n+1+d == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekday returns true if the day falls on one of the days of the BYDAY
+// rule part, ignoring their numbers.
+func (r *Rule) matchWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// parseInt parses an integer within the range [lo, hi].
+func parseInt(s string, lo, hi int) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "+"))
+	if err != nil {
+		return 0, fmt.Errorf("malformed number %q", s)
+	}
+	if n < lo || n > hi {
+		return 0, fmt.Errorf("%d is out of range [%d, %d]", n, lo, hi)
+	}
+	return n, nil
+}
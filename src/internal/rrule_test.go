@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+func TestRuleIterate(t *testing.T) {
+	// 2030-01-01 is a Tuesday.
+	start := time.Date(2030, time.January, 1, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"FREQ=DAILY;COUNT=3", []string{"2030-01-01", "2030-01-02", "2030-01-03"}},
+		{"FREQ=DAILY;INTERVAL=10;UNTIL=20300121", []string{"2030-01-01", "2030-01-11", "2030-01-21"}},
+		{"FREQ=DAILY;BYDAY=SA,SU;COUNT=3", []string{"2030-01-05", "2030-01-06", "2030-01-12"}},
+		{"FREQ=WEEKLY;COUNT=3", []string{"2030-01-01", "2030-01-08", "2030-01-15"}},
+		{
+			"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;COUNT=4",
+			[]string{"2030-01-01", "2030-01-03", "2030-01-15", "2030-01-17"},
+		},
+		{"FREQ=MONTHLY;COUNT=3", []string{"2030-01-01", "2030-02-01", "2030-03-01"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3", []string{"2030-01-31", "2030-02-28", "2030-03-31"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", []string{"2030-01-25", "2030-02-22", "2030-03-29"}},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=2", []string{"2030-09-13", "2030-12-13"}},
+		{"FREQ=YEARLY;COUNT=2", []string{"2030-01-01", "2031-01-01"}},
+		{"FREQ=YEARLY;BYMONTH=3,1;BYDAY=1MO;COUNT=3", []string{"2030-01-07", "2030-03-04", "2031-01-06"}},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", nil},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRule(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRule(%q) error = %v", tt.rule, err)
+		}
+		var got []string
+		rule.Iterate(start, func(t time.Time) bool {
+			got = append(got, t.Format(time.DateOnly))
+			return len(got) < 10
+		})
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.rule, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: got %v, want %v", tt.rule, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"RRULE:FREQ=weekly;byday=tu,TH", "FREQ=WEEKLY;BYDAY=TU,TH"},
+		{"FREQ=MONTHLY;BYDAY=+2MO;INTERVAL=3", "FREQ=MONTHLY;INTERVAL=3;BYDAY=2MO"},
+		{"FREQ=DAILY;UNTIL=20300101T120000Z", "FREQ=DAILY;UNTIL=20300101T120000Z"},
+		{"FREQ=HOURLY", ""},
+		{"INTERVAL=2", ""},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20300101", ""},
+		{"FREQ=DAILY;COUNT=2;COUNT=3", ""},
+		{"FREQ=WEEKLY;BYDAY=1MO", ""},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", ""},
+		{"FREQ=YEARLY;BYDAY=MO", ""},
+		{"FREQ=DAILY;BYSETPOS=1", ""},
+		{"FREQ=DAILY;BYDAY=XX", ""},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRule(tt.rule)
+		if tt.want == "" {
+			if !errors.Is(err, service.ErrBadRequest) {
+				t.Errorf("ParseRule(%q) error = %v, want %v", tt.rule, err, service.ErrBadRequest)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRule(%q) error = %v", tt.rule, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("ParseRule(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	start := time.Date(2030, time.January, 1, 18, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	e := Event{
+		ID:        "e1",
+		Name:      "Meetup",
+		StartDate: start,
+		EndDate:   start.Add(2 * time.Hour),
+		Hall:      "A",
+		Recurrence: &Recurrence{
+			RRule:   "FREQ=WEEKLY;COUNT=6",
+			ExDates: []time.Time{start.Add(week)},
+			Exceptions: []Exception{
+				{
+					RecurrenceID:  start.Add(3 * week),
+					ThisAndFuture: true,
+					Name:          "Late Meetup",
+					StartDate:     start.Add(3*week + time.Hour),
+					EndDate:       start.Add(3*week + 4*time.Hour),
+					Hall:          "B",
+				},
+				{
+					RecurrenceID: start.Add(4 * week),
+					Name:         "Moved Meetup",
+					StartDate:    start.Add(2*week - 4*time.Hour),
+					EndDate:      start.Add(2*week - 2*time.Hour),
+					Hall:         "A",
+				},
+			},
+		},
+	}
+
+	got, err := e.Occurrences(start.Add(week), start.Add(6*week), 10)
+	if err != nil {
+		t.Fatalf("Occurrences() error = %v", err)
+	}
+	want := []struct {
+		id         string
+		name, hall string
+		start      time.Time
+		duration   time.Duration
+	}{
+		{"e1_20300115T180000Z", "Meetup", "A", start.Add(2 * week), 2 * time.Hour},
+		{"e1_20300122T180000Z", "Late Meetup", "B", start.Add(3*week + time.Hour), 3 * time.Hour},
+		{"e1_20300129T180000Z", "Moved Meetup", "A", start.Add(2*week - 4*time.Hour), 2 * time.Hour},
+		{"e1_20300205T180000Z", "Late Meetup", "B", start.Add(5*week + time.Hour), 3 * time.Hour},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Occurrences() returned %d occurrences, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		o := got[i]
+		if o.ID != w.id || o.Name != w.name || o.Hall != w.hall || !o.StartDate.Equal(w.start) ||
+			o.EndDate.Sub(o.StartDate) != w.duration || o.SeriesID != "e1" || o.Recurrence != nil {
+			t.Errorf("occurrence %d = %+v, want %+v", i, o, w)
+		}
+	}
+
+	o, ok, err := e.Occurrence(start.Add(week))
+	if err != nil || ok {
+		t.Errorf("Occurrence() of excluded date = %+v, %v, %v, want none", o, ok, err)
+	}
+	seriesID, recurrenceID, ok := ParseOccurrenceID(got[1].ID)
+	if !ok || seriesID != "e1" || !recurrenceID.Equal(start.Add(3*week)) {
+		t.Errorf("ParseOccurrenceID(%q) = %q, %v, %v", got[1].ID, seriesID, recurrenceID, ok)
+	}
+}
+
+func TestOccurrencesTimeZone(t *testing.T) {
+	// Daylight saving time starts on 2030-03-31 in Amsterdam, so the meetup at
+	// 18:00 local time moves from 17:00 to 16:00 UTC.
+	start := time.Date(2030, time.March, 26, 17, 0, 0, 0, time.UTC)
+	e := Event{
+		ID:         "e1",
+		StartDate:  start,
+		EndDate:    start.Add(2 * time.Hour),
+		Recurrence: &Recurrence{RRule: "FREQ=WEEKLY;COUNT=2", TZID: "Europe/Amsterdam"},
+	}
+	got, err := e.Occurrences(start, start.Add(14*24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("Occurrences() error = %v", err)
+	}
+	want := []time.Time{start, time.Date(2030, time.April, 2, 16, 0, 0, 0, time.UTC)}
+	if len(got) != len(want) {
+		t.Fatalf("Occurrences() returned %d occurrences, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if !got[i].StartDate.Equal(w) || got[i].StartDate.Location() != time.UTC {
+			t.Errorf("occurrence %d starts at %v, want %v", i, got[i].StartDate, w)
+		}
+	}
+
+	for _, tzid := range []string{"Mars/Olympus", "Local"} {
+		e.Recurrence.TZID = tzid
+		_, err := e.Occurrences(start, start.Add(time.Hour), 10)
+		if !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("Occurrences() in %q error = %v, want %v", tzid, err, service.ErrBadRequest)
+		}
+	}
+}
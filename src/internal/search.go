@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Searcher searches the events by text.
+type Searcher interface {
+
+	// SearchEvents searches the names of the events and the
+	// names and addresses of their locations for the given text
+	// query. The search is case-insensitive and tolerates typos.
+	// At most limit hits are returned, ranked by their relevance
+	// to the query. The events of the hits hold the data of their
+	// locations, even if only location references are stored.
+	SearchEvents(_ context.Context, query string, limit int) ([]SearchHit, error)
+}
+
+// SearchHit is an event matching a search query.
+type SearchHit struct {
+	// Score is the relevance of the event to the query. Higher
+	// scores are more relevant. Scores are in the range (0, 1].
+	Score float64 `json:"score"`
+
+	// Event is the matching event.
+	Event Event `json:"event"`
+}
+
+// SearchEvents searches the given events, which take place at the given
+// locations, for the query. This function can be used by in-memory [Searcher]
+// implementations.
+func SearchEvents(events []Event, locations []Location, query string, limit int) []SearchHit {
+	r := NewRanker(query, limit, locations)
+	for i := range events {
+		r.Add(&events[i])
+	}
+	return r.Hits()
+}
+
+// Ranker ranks events by their relevance to a search query and keeps the most
+// relevant ones. Implementations of [Searcher] should feed the candidate events
+// to a ranker, so that all of them rank the events in the same way.
+type Ranker struct {
+	terms     []string
+	limit     int
+	locations map[string]*Location
+	hits      []SearchHit
+}
+
+// NewRanker creates a new [Ranker] keeping at most limit events that are
+// relevant to the given query. The given locations fill in the location data
+// of the events that only reference their location by id, before the events
+// are ranked.
+func NewRanker(query string, limit int, locations []Location) *Ranker {
+	r := &Ranker{
+		terms:     tokenize(query),
+		limit:     limit,
+		locations: make(map[string]*Location, len(locations)),
+	}
+	for i := range locations {
+		r.locations[locations[i].ID] = &locations[i]
+	}
+	return r
+}
+
+// Add ranks the given event. The event is kept if it matches the query and is
+// among the most relevant events added so far.
+func (r *Ranker) Add(e *Event) {
+	hit := SearchHit{Event: *e}
+	if l, ok := r.locations[e.Location.ID]; ok && e.Location.Name == "" {
+		hit.Event.Location = *l
+	}
+	hit.Score = r.score(&hit.Event)
+	if hit.Score == 0 || r.limit <= 0 {
+		return
+	}
+
+	if len(r.hits) == r.limit {
+		if !less(&hit, &r.hits[len(r.hits)-1]) {
+			return
+		}
+		r.hits = r.hits[:len(r.hits)-1]
+	}
+
+	// Insert the hit keeping the hits sorted by relevance.
+	i := sort.Search(len(r.hits), func(i int) bool { return less(&hit, &r.hits[i]) })
+	r.hits = append(r.hits, SearchHit{})
+	copy(r.hits[i+1:], r.hits[i:])
+	r.hits[i] = hit
+}
+
+// Hits returns the kept events, the most relevant first.
+func (r *Ranker) Hits() []SearchHit {
+	return r.hits
+}
+
+// less returns true if hit a should be ranked before hit b. Ties are broken by
+// the name and the id of the events, so that the ranking is stable.
+func less(a, b *SearchHit) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if a.Event.Name != b.Event.Name {
+		return a.Event.Name < b.Event.Name
+	}
+	return a.Event.ID < b.Event.ID
+}
+
+// The weights of the searched fields. A match in the name of the event is more
+// relevant than a match in the name or in the address of its location.
+const (
+	nameWeight     = 1.0
+	locationWeight = 0.6
+	addressWeight  = 0.4
+)
+
+// score returns the relevance of the event to the query terms, or 0 if the
+// event does not match. Every term of the query must match a word of one of
+// the searched fields.
+func (r *Ranker) score(e *Event) float64 {
+	if len(r.terms) == 0 {
+		return 0
+	}
+
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{tokenize(e.Name), nameWeight},
+		{tokenize(e.Location.Name), locationWeight},
+		{tokenize(e.Location.Address), addressWeight},
+	}
+
+	var total float64
+	for _, term := range r.terms {
+		var best float64
+		for _, f := range fields {
+			for _, w := range f.words {
+				if s := f.weight * matchWord(term, w); s > best {
+					best = s
+				}
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total / float64(len(r.terms))
+}
+
+// TermGrams returns the grams of every term of the query. An event may only
+// match the query if, for every term, the name of the event holds one of the
+// grams of the term, see [WordGrams], or the term matches its location, see
+// [Ranker.MatchingLocations]. Thus, the grams can be indexed for narrowing down
+// the events that are ranked.
+func (r *Ranker) TermGrams() [][]string {
+	res := make([][]string, len(r.terms))
+	for i, term := range r.terms {
+		if len([]rune(term)) == 1 {
+			res[i] = []string{term}
+		} else {
+			res[i] = bigrams(term)
+		}
+	}
+	return res
+}
+
+// MatchingLocations returns, for every term of the query, the ids of the given
+// locations whose name or address matches the term. The ids of a term are
+// empty, but not nil, if no location matches it.
+func (r *Ranker) MatchingLocations(locations []Location) [][]string {
+	res := make([][]string, len(r.terms))
+	for i := range res {
+		res[i] = make([]string, 0)
+	}
+	for i := range locations {
+		l := &locations[i]
+		words := append(tokenize(l.Name), tokenize(l.Address)...)
+		for j, term := range r.terms {
+			if slices.ContainsFunc(words, func(w string) bool { return matchWord(term, w) > 0 }) {
+				res[j] = append(res[j], l.ID)
+			}
+		}
+	}
+	return res
+}
+
+// WordGrams returns the grams of the words of the given text, i.e. the first
+// letter and every pair of adjacent letters of every word. Every word matching
+// a query term shares at least one gram with the term: a prefix or a substring
+// of the word shares its pairs of letters, and a word that differs from a term
+// of more than three letters by one typo, or from a term of more than six
+// letters by two typos, still shares at least one pair of adjacent letters.
+func WordGrams(text string) []string {
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+	for _, w := range tokenize(text) {
+		grams := append(bigrams(w), string([]rune(w)[0]))
+		for _, g := range grams {
+			if !seen[g] {
+				seen[g] = true
+				res = append(res, g)
+			}
+		}
+	}
+	return res
+}
+
+// bigrams returns the pairs of adjacent letters of the given word.
+func bigrams(word string) []string {
+	runes := []rune(word)
+	res := make([]string, 0, len(runes))
+	for i := 1; i < len(runes); i++ {
+		res = append(res, string(runes[i-1:i+1]))
+	}
+	return res
+}
+
+// matchWord returns how well the query term matches the given word, in the
+// range [0, 1]. Exact matches score highest, followed by prefix matches, typos
+// and substring matches.
+func matchWord(term, word string) float64 {
+	switch {
+	case term == word:
+		return 1
+	case strings.HasPrefix(word, term):
+		return 0.8
+	}
+
+	// Tolerate one typo for short terms and two typos for longer ones.
+	maxTypos := 1
+	if len([]rune(term)) > 6 { //nolint:gomnd // intentional
+		maxTypos = 2
+	}
+	if len([]rune(term)) > 3 { //nolint:gomnd // intentional
+		if d := levenshtein(term, word); d <= maxTypos {
+			return 0.7 - 0.1*float64(d)
+		}
+	}
+
+	if len([]rune(term)) >= 3 && strings.Contains(word, term) { //nolint:gomnd // intentional
+		return 0.5
+	}
+	return 0
+}
+
+// tokenize splits the text into lower-cased words. Punctuation and whitespace
+// separate the words.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// levenshtein returns the edit distance between the two strings, i.e. the
+// number of single-character insertions, deletions or substitutions needed to
+// change one string into the other.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
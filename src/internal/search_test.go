@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchEvents(t *testing.T) {
+	arena := Location{ID: "l1", Name: "Ziggo Dome", Address: "De Passage 100, Amsterdam"}
+	events := []Event{
+		{ID: "e1", Name: "Jazz Night", Location: Location{ID: arena.ID}},
+		{ID: "e2", Name: "Rock Concert", Location: arena},
+		{ID: "e3", Name: "Jazz Brunch", Location: Location{ID: "missing"}},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"jazz", []string{"e3", "e1"}},
+		{"jaz night", []string{"e1"}},
+		{"zigo", []string{"e1", "e2"}},
+		{"jazz amsterdam", []string{"e1"}},
+		{"opera", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var got []string
+			for _, hit := range SearchEvents(events, []Location{arena}, tt.query, 10) {
+				got = append(got, hit.Event.ID)
+				if hit.Event.Location.ID == arena.ID && hit.Event.Location.Name != arena.Name {
+					t.Errorf("SearchEvents() hit %s has no location data", hit.Event.ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SearchEvents(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTermGrams(t *testing.T) {
+	// Every word matching a term shares a gram with the term.
+	words := []string{"jazz", "concertgebouw", "a", "amsterdam", "dome", "passage", "100"}
+	terms := []string{
+		"j", "ja", "jaz", "jizz", "jzaz", "azz", "concertgebow", "konsertgebouw",
+		"a", "amstedam", "dom", "oe", "pasage", "10", "00",
+	}
+	for _, term := range terms {
+		grams := NewRanker(term, 1, nil).TermGrams()[0]
+		for _, w := range words {
+			if matchWord(term, w) == 0 {
+				continue
+			}
+			if !slices.ContainsFunc(grams, func(g string) bool {
+				return slices.Contains(WordGrams(w), g)
+			}) {
+				t.Errorf("term %q matches %q, but shares no gram with it", term, w)
+			}
+		}
+	}
+}
+
+func TestMatchingLocations(t *testing.T) {
+	locations := []Location{
+		{ID: "l1", Name: "Ziggo Dome", Address: "Amsterdam"},
+		{ID: "l2", Name: "Concertgebouw", Address: "Amsterdam"},
+	}
+	got := NewRanker("zigo amsterdam opera", 1, nil).MatchingLocations(locations)
+	want := [][]string{{"l1"}, {"l1", "l2"}, {}}
+	if !slices.EqualFunc(got, want, slices.Equal[[]string]) || got[2] == nil {
+		t.Errorf("MatchingLocations() = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// DefaultTenant is the tenant of the entries that were stored before the
+// service became aware of tenants. The containers assign such entries to it
+// when they are opened.
+const DefaultTenant = "default"
+
+// tenantPattern is the pattern of valid tenant ids. The ids are used in
+// database documents, log records and message payloads, thus they are kept
+// simple.
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// tenantKey is the context key of the tenant.
+type tenantKey struct{}
+
+// ValidTenant returns true if the given string is a valid tenant id, i.e. it
+// consists of at most 63 lower-case letters, digits, dashes and underscores,
+// and starts with a letter or a digit.
+func ValidTenant(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// WithTenant returns a copy of the context that holds the given tenant. The
+// operations of the containers are scoped to the tenant of their context.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant held by the given context, and false if
+// the context does not hold a tenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// RequireTenant returns the tenant held by the given context. The containers
+// call it before every operation, so that no operation runs unscoped. This
+// function returns [service.ErrNotAllowed] if the context does not hold a
+// tenant.
+func RequireTenant(ctx context.Context) (string, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("%w: the operation is not scoped to a tenant", service.ErrNotAllowed)
+	}
+	return tenant, nil
+}
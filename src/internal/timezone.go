@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// icalZoneYears is the number of years for which the transitions of a time
+// zone are listed one by one, if they do not follow yearly rules.
+const icalZoneYears = 10
+
+// observance is a STANDARD or DAYLIGHT component of a VTIMEZONE component,
+// i.e. a transition of a time zone. If the rule is not empty, then the
+// transition recurs every year.
+type observance struct {
+	kind  string
+	start string
+	from  string
+	to    string
+	name  string
+	rrule string
+}
+
+// writeTimezone writes the VTIMEZONE component of the zone with the given
+// name, whose observances start in the year before the given time, so that
+// every time from then on is covered.
+func (c *CalendarWriter) writeTimezone(tzid string, loc *time.Location, from time.Time) {
+	c.line("BEGIN", "VTIMEZONE")
+	c.line("TZID", tzid)
+	for _, o := range zoneObservances(loc, from.In(loc).Year()-1) {
+		c.line("BEGIN", o.kind)
+		c.line("DTSTART", o.start)
+		c.line("TZOFFSETFROM", o.from)
+		c.line("TZOFFSETTO", o.to)
+		if o.name != "" {
+			c.line("TZNAME", escapeText(o.name))
+		}
+		if o.rrule != "" {
+			c.line("RRULE", o.rrule)
+		}
+		c.line("END", o.kind)
+	}
+	c.line("END", "VTIMEZONE")
+}
+
+// zoneObservances returns the observances of the time zone from the given year
+// on. A zone without transitions has a single observance. If the transitions
+// follow the same yearly rules in the given year and the two following years,
+// e.g. "the last Sunday of March at 02:00", then they are described by these
+// rules. Otherwise, the transitions of the next [icalZoneYears] are listed.
+func zoneObservances(loc *time.Location, year int) []observance {
+	first := yearlyObservances(loc, year)
+	if len(first) == 0 {
+		name, offset := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Zone()
+		return []observance{{
+			kind:  "STANDARD",
+			start: "19700101T000000",
+			from:  formatOffset(offset),
+			to:    formatOffset(offset),
+			name:  name,
+		}}
+	}
+	regular := true
+	for y := year + 1; y < year+3 && regular; y++ {
+		regular = slices.EqualFunc(first, yearlyObservances(loc, y), sameRule)
+	}
+	if regular {
+		return first
+	}
+	var res []observance
+	for y := year; y < year+icalZoneYears; y++ {
+		for _, o := range yearlyObservances(loc, y) {
+			o.rrule = ""
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// yearlyObservances returns the transitions of the time zone within the given
+// year, each together with the yearly rule on which it takes place.
+func yearlyObservances(loc *time.Location, year int) []observance {
+	var res []observance
+	t := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc)
+	for {
+		_, next := t.ZoneBounds()
+		if next.IsZero() || !next.Before(end) {
+			return res
+		}
+		res = append(res, newObservance(next))
+		t = next
+	}
+}
+
+// newObservance returns the observance of the transition of a time zone at the
+// given time. Its start is the local time before the transition. The rule
+// selects the same day of the week within the same week of the month, counting
+// from the end of the month in its last week.
+func newObservance(transition time.Time) observance {
+	_, fromOffset := transition.Add(-time.Second).Zone()
+	name, toOffset := transition.Zone()
+	wall := transition.UTC().Add(time.Duration(fromOffset) * time.Second)
+	n := (wall.Day()-1)/daysInWeek + 1
+	lastDay := time.Date(wall.Year(), wall.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if wall.Day()+daysInWeek > lastDay {
+		n = -1
+	}
+	rule := Rule{
+		Freq:    Yearly,
+		ByDay:   []WeekdayNum{{N: n, Day: wall.Weekday()}},
+		ByMonth: []time.Month{wall.Month()},
+	}
+	o := observance{
+		kind:  "STANDARD",
+		start: wall.Format(icalLocalTimeLayout),
+		from:  formatOffset(fromOffset),
+		to:    formatOffset(toOffset),
+		name:  name,
+		rrule: rule.String(),
+	}
+	if transition.IsDST() {
+		o.kind = "DAYLIGHT"
+	}
+	return o
+}
+
+// sameRule returns true if the observances take place on the same yearly rule
+// at the same time of the day.
+func sameRule(a, b observance) bool {
+	clock := len("20060102T")
+	return a.kind == b.kind && a.from == b.from && a.to == b.to && a.name == b.name &&
+		a.rrule == b.rrule && a.start[clock:] == b.start[clock:]
+}
+
+// formatOffset formats the UTC offset in seconds as a UTC-OFFSET value, e.g.
+// "+0100" or "-0330".
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	h, m, s := offset/3600, offset/60%60, offset%60
+	if s != 0 {
+		return fmt.Sprintf("%c%02d%02d%02d", sign, h, m, s)
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, h, m)
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import "sync"
+
+// MemoryExporter keeps the exported spans in memory. It is meant for tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewMemoryExporter creates a new [MemoryExporter] instance.
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// Export implements the [Exporter] interface.
+func (e *MemoryExporter) Export(span *SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, *span)
+}
+
+// Spans returns the exported spans, in the order in which they ended.
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res := make([]SpanData, len(e.spans))
+	copy(res, e.spans)
+	return res
+}
+
+// Reset removes the exported spans.
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
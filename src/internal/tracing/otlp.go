@@ -0,0 +1,266 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+const (
+	// otlpMaxQueueSize is the maximum number of spans waiting to
+	// be exported. Spans are dropped while the queue is full.
+	otlpMaxQueueSize = 2048
+
+	// otlpBatchSize is the maximum number of spans that are
+	// exported in a single request.
+	otlpBatchSize = 512
+
+	// otlpTimeout is the timeout of a request to the collector,
+	// and of the final export when the exporter stops.
+	otlpTimeout = 10 * time.Second
+)
+
+// OTLPConfig holds the configuration of an [OTLPExporter].
+type OTLPConfig struct {
+	// Endpoint is the base url of the collector, e.g.
+	// http://localhost:4318. The spans are posted to the
+	// /v1/traces path of the endpoint.
+	Endpoint string
+
+	// ServiceName identifies the service in the exported
+	// spans.
+	ServiceName string
+
+	// Interval is the interval at which the queued spans are
+	// exported.
+	Interval time.Duration
+}
+
+// OTLPExporter exports spans to an OpenTelemetry collector, using the JSON
+// encoding of OTLP over http, see
+// https://opentelemetry.io/docs/specs/otlp/#otlphttp. The spans are queued and
+// exported in batches by [OTLPExporter.Run]. Spans that fail to be exported
+// are dropped.
+type OTLPExporter struct {
+	cfg    OTLPConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	queue   []SpanData
+	dropped int
+}
+
+// NewOTLPExporter creates a new [OTLPExporter] instance.
+func NewOTLPExporter(cfg *OTLPConfig) *OTLPExporter {
+	return &OTLPExporter{
+		cfg:    *cfg,
+		client: &http.Client{Timeout: otlpTimeout},
+	}
+}
+
+// Export implements the [Exporter] interface.
+func (e *OTLPExporter) Export(span *SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.queue) >= otlpMaxQueueSize {
+		e.dropped++
+		return
+	}
+	e.queue = append(e.queue, *span)
+}
+
+// Run exports the queued spans periodically until the context is cancelled.
+// The spans that are still queued at that point are exported before returning.
+func (e *OTLPExporter) Run(ctx context.Context) error {
+	slog.Info("starting span exporter", slog.String("endpoint", e.cfg.Endpoint))
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.Flush(ctx)
+		case <-ctx.Done():
+			// Use a fresh context for the final export, since
+			// the given one is already cancelled.
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), otlpTimeout)
+			e.Flush(flushCtx)
+			cancel()
+			slog.Info("stopping span exporter")
+			return nil
+		}
+	}
+}
+
+// Flush exports all the queued spans. Failures are logged.
+func (e *OTLPExporter) Flush(ctx context.Context) {
+	e.mu.Lock()
+	spans, dropped := e.queue, e.dropped
+	e.queue, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("dropped spans, the export queue is full", slog.Int("count", dropped))
+	}
+	for len(spans) > 0 {
+		batch := spans[:min(len(spans), otlpBatchSize)]
+		spans = spans[len(batch):]
+		if err := e.export(ctx, batch); err != nil {
+			slog.Warn("failed to export spans",
+				slog.Int("count", len(batch)),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+// export posts the given spans to the collector.
+func (e *OTLPExporter) export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return fmt.Errorf("%w: marshal spans: %v", service.ErrUnexpected, err)
+	}
+	url := strings.TrimSuffix(e.cfg.Endpoint, "/") + "/v1/traces"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: new request: %v", service.ErrUnexpected, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: post spans: %v", service.ErrConnectionClosed, err)
+	}
+	defer resp.Body.Close()               //nolint:errcheck // intentional
+	_, _ = io.Copy(io.Discard, resp.Body) //nolint:errcheck // drain for reuse
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: collector responded %s", service.ErrUnexpected, resp.Status)
+	}
+	return nil
+}
+
+// request builds the OTLP request that exports the given spans.
+func (e *OTLPExporter) request(spans []SpanData) *otlpRequest {
+	res := make([]otlpSpan, 0, len(spans))
+	for i := range spans {
+		res = append(res, toOTLPSpan(&spans[i]))
+	}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			toOTLPAttribute(String("service.name", e.cfg.ServiceName)),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/eventscompass/events-service"},
+			Spans: res,
+		}},
+	}}}
+}
+
+// toOTLPSpan converts the span to its OTLP representation.
+func toOTLPSpan(s *SpanData) otlpSpan {
+	res := otlpSpan{
+		TraceID:           s.SpanContext.TraceID.String(),
+		SpanID:            s.SpanContext.SpanID.String(),
+		Name:              s.Name,
+		Kind:              int(s.Kind),
+		StartTimeUnixNano: fmt.Sprint(s.StartTime.UnixNano()),
+		EndTimeUnixNano:   fmt.Sprint(s.EndTime.UnixNano()),
+	}
+	if s.ParentSpanID != (SpanID{}) {
+		res.ParentSpanID = s.ParentSpanID.String()
+	}
+	for _, a := range s.Attributes {
+		res.Attributes = append(res.Attributes, toOTLPAttribute(a))
+	}
+	if s.Error != "" {
+		res.Status = otlpStatus{Code: otlpStatusError, Message: s.Error}
+	}
+	return res
+}
+
+// toOTLPAttribute converts the attribute to its OTLP representation. Values of
+// unsupported types are formatted as strings.
+func toOTLPAttribute(a Attribute) otlpAttribute {
+	var v otlpValue
+	switch value := a.Value.(type) {
+	case string:
+		v.StringValue = &value
+	case int:
+		s := strconv.Itoa(value)
+		v.IntValue = &s
+	case bool:
+		v.BoolValue = &value
+	case float64:
+		v.DoubleValue = &value
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: a.Key, Value: v}
+}
+
+// otlpStatusError is the OTLP status code of failed spans.
+const otlpStatusError = 2
+
+// The following types are the JSON encoding of the OTLP messages. Note that
+// the ids are hex encoded, and 64-bit integers are encoded as strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	requests := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		requests <- body
+	}))
+	defer srv.Close()
+
+	exporter := NewOTLPExporter(&OTLPConfig{
+		Endpoint:    srv.URL + "/",
+		ServiceName: "events-service",
+		Interval:    time.Hour,
+	})
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	start := time.Unix(1, 0)
+	exporter.Export(&SpanData{
+		Name:         "GET /api/events",
+		Kind:         SpanKindServer,
+		SpanContext:  sc,
+		ParentSpanID: SpanID{1},
+		StartTime:    start,
+		EndTime:      start.Add(time.Second),
+		Attributes:   []Attribute{String("http.route", "/api/events"), Int("code", 500)},
+		Error:        "boom",
+	})
+
+	// Cancelling the context stops the exporter, which exports
+	// the queued spans before returning.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := exporter.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got map[string]any
+	select {
+	case got = <-requests:
+	default:
+		t.Fatal("no spans were exported")
+	}
+	want := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []any{
+				map[string]any{"key": "service.name", "value": map[string]any{
+					"stringValue": "events-service",
+				}},
+			}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/eventscompass/events-service"},
+				"spans": []any{map[string]any{
+					"traceId":           "4bf92f3577b34da6a3ce929d0e0e4736",
+					"spanId":            "00f067aa0ba902b7",
+					"parentSpanId":      "0100000000000000",
+					"name":              "GET /api/events",
+					"kind":              float64(2),
+					"startTimeUnixNano": "1000000000",
+					"endTimeUnixNano":   "2000000000",
+					"attributes": []any{
+						map[string]any{"key": "http.route", "value": map[string]any{
+							"stringValue": "/api/events",
+						}},
+						map[string]any{"key": "code", "value": map[string]any{
+							"intValue": "500",
+						}},
+					},
+					"status": map[string]any{"code": float64(2), "message": "boom"},
+				}},
+			}},
+		}},
+	}
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("request =\n%s\nwant\n%s", gotJSON, wantJSON)
+	}
+}
+
+func TestOTLPExporterQueueFull(t *testing.T) {
+	exporter := NewOTLPExporter(&OTLPConfig{Endpoint: "http://localhost:0"})
+	for i := 0; i < otlpMaxQueueSize+10; i++ {
+		exporter.Export(&SpanData{Name: "span"})
+	}
+	if len(exporter.queue) != otlpMaxQueueSize || exporter.dropped != 10 {
+		t.Errorf("queued %d and dropped %d spans, want %d and 10",
+			len(exporter.queue), exporter.dropped, otlpMaxQueueSize)
+	}
+}
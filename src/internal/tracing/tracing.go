@@ -0,0 +1,284 @@
+// Package tracing implements distributed tracing in the style of OpenTelemetry.
+// The trace context is propagated between services in the W3C traceparent
+// format, see https://www.w3.org/TR/trace-context/, and the spans are exported
+// to a pluggable [Exporter].
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the hex encoding of the id.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the hex encoding of the id.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span that is propagated to the child spans,
+// including those in other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+
+	// Sampled is set if the trace is recorded. Spans of traces
+	// that are not sampled are not exported.
+	Sampled bool
+}
+
+// IsValid returns true if both the trace id and the span id are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent encodes the span context as the value of the traceparent header.
+// It returns the empty string if the span context is not valid.
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent decodes the value of the traceparent header. The second
+// return value is false if the value is not valid. Values of versions newer
+// than 00 are accepted as long as they start like those of version 00.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) ||
+		!decodeHex(sc.SpanID[:], parts[2]) ||
+		!decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// decodeHex decodes the lowercase hex string s into dst, which it must fill
+// exactly.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// SpanKind describes the relationship of a span to its parent and children.
+// The values match those of OTLP.
+type SpanKind int
+
+const (
+	// SpanKindInternal is the kind of spans that are internal to
+	// the service.
+	SpanKindInternal SpanKind = iota + 1
+
+	// SpanKindServer is the kind of spans that handle a request
+	// of a remote client.
+	SpanKindServer
+
+	// SpanKindClient is the kind of spans that send a request to
+	// a remote server, e.g. a database.
+	SpanKindClient
+
+	// SpanKindProducer is the kind of spans that publish a
+	// message, which is handled later by a consumer.
+	SpanKindProducer
+
+	// SpanKindConsumer is the kind of spans that handle a
+	// message.
+	SpanKindConsumer
+)
+
+// Attribute is a key-value pair describing a span. The value is a string, an
+// int, a bool or a float64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String creates a string attribute.
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int creates an int attribute.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is the recorded data of a span, which is exported once the span
+// ends.
+type SpanData struct {
+	Name        string
+	Kind        SpanKind
+	SpanContext SpanContext
+
+	// ParentSpanID is the id of the parent span. It is not set
+	// on the root span of a trace.
+	ParentSpanID SpanID
+
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes []Attribute
+
+	// Error is the message of the error that made the span
+	// fail. It is empty if the span succeeded.
+	Error string
+}
+
+// Exporter exports the spans that ended. Only the spans of sampled traces are
+// exported.
+type Exporter interface {
+	// Export exports the given span. This function must not
+	// block, thus it is expected to queue the span, and export
+	// it later.
+	Export(span *SpanData)
+}
+
+// Tracer creates spans and exports them.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer creates a new [Tracer] instance that exports the spans to the
+// given exporter. If the exporter is nil, then the spans are not exported, but
+// the trace context is still propagated.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start starts a new span, which is a child of the span in the given context.
+// If the context does not hold a span, then a new trace is started. The
+// returned context holds the new span. The span must be ended by calling
+// [Span.End].
+func (t *Tracer) Start(
+	ctx context.Context,
+	name string,
+	kind SpanKind,
+	attrs ...Attribute,
+) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+	if !parent.IsValid() {
+		sc = SpanContext{TraceID: TraceID(randomBytes(len(TraceID{}))), Sampled: true}
+	}
+	sc.SpanID = SpanID(randomBytes(len(SpanID{})))
+
+	s := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			SpanContext:  sc,
+			ParentSpanID: parent.SpanID,
+			StartTime:    time.Now(),
+			Attributes:   attrs,
+		},
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc), s
+}
+
+// randomBytes returns n random bytes.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) //nolint:errcheck // never fails, see [rand.Read]
+	return b
+}
+
+// Span records an operation. A span is not safe for concurrent use.
+type Span struct {
+	tracer *Tracer
+	data   SpanData
+	once   sync.Once
+}
+
+// SpanContext returns the span context of the span.
+func (s *Span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+// SetName changes the name of the span, e.g. once the route of a request is
+// known.
+func (s *Span) SetName(name string) {
+	s.data.Name = name
+}
+
+// SetAttributes adds the given attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// SetError marks the span as failed with the given error. A nil error is
+// ignored.
+func (s *Span) SetError(err error) {
+	if err != nil {
+		s.SetErrorMessage(err.Error())
+	}
+}
+
+// SetErrorMessage marks the span as failed with the given message, e.g. when
+// the failure is not described by an error.
+func (s *Span) SetErrorMessage(msg string) {
+	s.data.Error = msg
+}
+
+// End ends the span and exports it. Calling End more than once has no effect.
+func (s *Span) End() {
+	s.once.Do(func() {
+		s.data.EndTime = time.Now()
+		if s.tracer.exporter != nil && s.data.SpanContext.Sampled {
+			data := s.data
+			s.tracer.exporter.Export(&data)
+		}
+	})
+}
+
+// spanContextKey is the context key of the current span context.
+type spanContextKey struct{}
+
+// SpanContextFromContext returns the span context held by the given context.
+// The returned span context is not valid if the context does not hold one.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext) //nolint:errcheck // zero value if missing
+	return sc
+}
+
+// ContextWithRemoteSpanContext returns a copy of the context that holds the
+// given span context, e.g. one received from another service, so that it
+// becomes the parent of the spans started from the context. An invalid span
+// context is ignored.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var errBoom = errors.New("boom")
+
+func TestParseTraceparent(t *testing.T) {
+	tests := map[string]struct {
+		value   string
+		want    string
+		sampled bool
+		ok      bool
+	}{
+		"sampled": {
+			value:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			sampled: true,
+			ok:      true,
+		},
+		"not sampled": {
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			want:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			ok:    true,
+		},
+		"future version": {
+			value:   "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09-extra",
+			want:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			sampled: true,
+			ok:      true,
+		},
+		"empty":           {value: ""},
+		"invalid version": {value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"extra field":     {value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x"},
+		"zero trace id":   {value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		"zero span id":    {value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		"uppercase":       {value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		"short trace id":  {value: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01"},
+		"not hex":         {value: "00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tc.value)
+			if ok != tc.ok {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", tc.value, ok, tc.ok)
+			}
+			if got := sc.Traceparent(); got != tc.want {
+				t.Errorf("Traceparent() = %q, want %q", got, tc.want)
+			}
+			if sc.Sampled != tc.sampled {
+				t.Errorf("Sampled = %v, want %v", sc.Sampled, tc.sampled)
+			}
+		})
+	}
+}
+
+func TestTracer(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	// A span without a parent starts a new trace.
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	if got := SpanContextFromContext(ctx); got != root.SpanContext() {
+		t.Errorf("SpanContextFromContext() = %v, want %v", got, root.SpanContext())
+	}
+	_, child := tracer.Start(ctx, "child", SpanKindClient, String("db.collection", "events"))
+	child.SetError(errBoom)
+	child.End()
+	root.SetName("renamed")
+	root.SetAttributes(Int("http.response.status_code", 200))
+	root.End()
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if !r.SpanContext.IsValid() || !r.SpanContext.Sampled {
+		t.Errorf("root span context = %+v, want valid and sampled", r.SpanContext)
+	}
+	if r.Name != "renamed" || r.Kind != SpanKindServer || r.ParentSpanID != (SpanID{}) {
+		t.Errorf("root span = %+v", r)
+	}
+	if len(r.Attributes) != 1 || r.Attributes[0] != Int("http.response.status_code", 200) {
+		t.Errorf("root attributes = %v", r.Attributes)
+	}
+	if c.SpanContext.TraceID != r.SpanContext.TraceID || c.ParentSpanID != r.SpanContext.SpanID {
+		t.Errorf("child span %+v is not a child of %+v", c.SpanContext, r.SpanContext)
+	}
+	if c.SpanContext.SpanID == r.SpanContext.SpanID {
+		t.Errorf("child span has the span id of its parent")
+	}
+	if c.Error != "boom" {
+		t.Errorf("child error = %q, want %q", c.Error, "boom")
+	}
+	if r.EndTime.Before(r.StartTime) {
+		t.Errorf("root span ends before it starts")
+	}
+}
+
+func TestTracerRemoteParent(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	// The spans of traces that are not sampled are propagated, but
+	// not exported.
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx := ContextWithRemoteSpanContext(context.Background(), remote)
+	_, span := tracer.Start(ctx, "server", SpanKindServer)
+	span.End()
+	if got := span.SpanContext(); got.TraceID != remote.TraceID || got.Sampled {
+		t.Errorf("span context = %+v, want trace %v not sampled", got, remote.TraceID)
+	}
+	if spans := exporter.Spans(); len(spans) != 0 {
+		t.Errorf("got %d spans, want 0", len(spans))
+	}
+
+	remote.Sampled = true
+	ctx = ContextWithRemoteSpanContext(context.Background(), remote)
+	_, span = tracer.Start(ctx, "server", SpanKindServer)
+	span.End()
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].ParentSpanID != remote.SpanID {
+		t.Errorf("spans = %+v, want one child of %v", spans, remote.SpanID)
+	}
+}
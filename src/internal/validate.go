@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eventscompass/service-framework/service"
+)
+
+// FieldError describes a single field of a payload that failed validation.
+// Nested fields are addressed using their json names separated by dots, e.g.
+// "location.halls[0].capacity".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a payload fails validation. It lists all
+// the fields that failed validation. The error wraps [service.ErrBadRequest].
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error implements the [error] interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", f.Field, f.Message))
+	}
+	return fmt.Sprintf("%v: validation failed: %s", service.ErrBadRequest, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns [service.ErrBadRequest], so that validation errors can be
+// handled as any other bad request.
+func (e *ValidationError) Unwrap() error {
+	return service.ErrBadRequest
+}
+
+// validator collects field errors while validating a payload.
+type validator struct {
+	fields []FieldError
+}
+
+// check records a field error with the given message if ok is false.
+func (v *validator) check(ok bool, field string, format string, args ...any) {
+	if !ok {
+		v.fields = append(v.fields, FieldError{
+			Field:   field,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+}
+
+// err returns a [ValidationError] listing the recorded field errors, or nil if
+// there are none.
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// ValidateEvent checks that the given event is well-formed. The event must
+// have a name and its end date must be after its start date. If a duration is
+// given, then it must agree with the dates. The event must take place in one
+// of the halls of its location and within the opening hours of the location.
+// The id of the event must not have the form of an occurrence id, since only
+// the occurrences of recurring events have such ids. This function returns a
+// [ValidationError] listing all the fields that failed validation.
+func ValidateEvent(e *Event) error {
+	var v validator
+	_, _, occurrence := ParseOccurrenceID(e.ID)
+	v.check(!occurrence, "id", "must not have the form of an occurrence id")
+	v.check(e.SeriesID == "" && e.RecurrenceID == nil,
+		"series_id", "must not be set, since only occurrences belong to a series")
+	v.check(strings.TrimSpace(e.Name) != "", "name", "must not be empty")
+	v.check(!e.StartDate.IsZero(), "start_date", "must be set")
+	v.check(!e.EndDate.IsZero(), "end_date", "must be set")
+	if !e.StartDate.IsZero() && !e.EndDate.IsZero() {
+		v.check(e.EndDate.After(e.StartDate), "end_date", "must be after start_date")
+		if e.Duration != 0 {
+			v.check(e.Duration == e.EndDate.Sub(e.StartDate),
+				"duration", "must be equal to end_date - start_date (%v)",
+				e.EndDate.Sub(e.StartDate))
+		}
+		v.check(e.Location.IsOpen(e.StartDate, e.EndDate),
+			"start_date", "event must take place within the opening hours of the location")
+	}
+	if e.Hall != "" {
+		v.check(e.Location.Hall(e.Hall) != nil,
+			"hall", "location %q has no hall %q", e.Location.ID, e.Hall)
+	}
+	if e.Recurrence != nil && !e.StartDate.IsZero() {
+		validateRecurrence(&v, e)
+	}
+	return v.err()
+}
+
+// validateRecurrence checks the recurrence of the given event. The rule must be
+// valid and the event must take place at the first occurrence of the rule. The
+// exceptions must change occurrences of the rule, and the changed occurrences
+// must be valid as well.
+func validateRecurrence(v *validator, e *Event) {
+	rule, err := ParseRule(e.Recurrence.RRule)
+	if err != nil {
+		v.check(false, "recurrence.rrule", "%v", err)
+		return
+	}
+	loc, err := e.Recurrence.Location()
+	if err != nil {
+		v.check(false, "recurrence.tzid", "%v", err)
+		return
+	}
+
+	// Collect the occurrences up to the last exception.
+	var last time.Time
+	for _, x := range e.Recurrence.Exceptions {
+		if x.RecurrenceID.After(last) {
+			last = x.RecurrenceID
+		}
+	}
+	var first time.Time
+	occurrences := make(map[int64]bool)
+	rule.Iterate(e.StartDate.In(loc), func(t time.Time) bool {
+		if first.IsZero() {
+			first = t
+		}
+		occurrences[t.UnixNano()] = true
+		return t.Before(last)
+	})
+	v.check(first.Equal(e.StartDate),
+		"start_date", "must be the first occurrence of the recurrence rule")
+
+	for i, x := range e.Recurrence.Exceptions {
+		field := fmt.Sprintf("recurrence.exceptions[%d]", i)
+		v.check(occurrences[x.RecurrenceID.UnixNano()],
+			field+".recurrence_id", "must be an occurrence of the recurrence rule")
+		v.check(strings.TrimSpace(x.Name) != "", field+".name", "must not be empty")
+		v.check(x.EndDate.After(x.StartDate), field+".end_date", "must be after start_date")
+		v.check(e.Location.IsOpen(x.StartDate, x.EndDate), field+".start_date",
+			"event must take place within the opening hours of the location")
+		if x.Hall != "" {
+			v.check(e.Location.Hall(x.Hall) != nil,
+				field+".hall", "location %q has no hall %q", e.Location.ID, x.Hall)
+		}
+	}
+}
+
+// ValidateLocation checks that the given location is well-formed. The location
+// must have a name and its halls must have unique names and non-negative
+// capacities. Halls must belong to the location. This function returns a
+// [ValidationError] listing all the fields that failed validation.
+func ValidateLocation(l *Location) error {
+	var v validator
+	v.check(strings.TrimSpace(l.Name) != "", "name", "must not be empty")
+
+	names := make(map[string]bool, len(l.Halls))
+	for i, h := range l.Halls {
+		field := fmt.Sprintf("halls[%d]", i)
+		v.check(strings.TrimSpace(h.Name) != "", field+".name", "must not be empty")
+		v.check(!names[h.Name], field+".name", "duplicate hall %q", h.Name)
+		v.check(h.Capacity >= 0, field+".capacity", "must not be negative")
+		v.check(h.Location == "" || h.Location == l.ID,
+			field+".location", "hall belongs to location %q", h.Location)
+		names[h.Name] = true
+	}
+	return v.err()
+}
+
+// Hall returns the hall of the location with the given name, or nil if the
+// location has no such hall.
+func (l *Location) Hall(name string) *Hall {
+	for i := range l.Halls {
+		if l.Halls[i].Name == name {
+			return &l.Halls[i]
+		}
+	}
+	return nil
+}
+
+// IsOpen returns true if the location is open for the whole time between start
+// and end. Only the time of day of [Location.OpenTime] and [Location.CloseTime]
+// is taken into account. If the close time is before the open time, then the
+// location closes on the next day. A location without opening hours is always
+// open.
+func (l *Location) IsOpen(start, end time.Time) bool {
+	if l.OpenTime.IsZero() && l.CloseTime.IsZero() {
+		return true
+	}
+
+	tz := l.OpenTime.Location()
+	opens, closes := timeOfDay(l.OpenTime), timeOfDay(l.CloseTime)
+	if closes <= opens {
+		closes += 24 * time.Hour
+	}
+
+	// The event could have started during the opening hours of the previous
+	// day, if the location closes after midnight.
+	start = start.In(tz)
+	midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, tz)
+	for _, day := range []time.Time{midnight.AddDate(0, 0, -1), midnight} {
+		from, to := day.Add(opens), day.Add(closes)
+		if !start.Before(from) && !end.After(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// timeOfDay returns the time elapsed since midnight of the day of t.
+func timeOfDay(t time.Time) time.Duration {
+	h, m, s := t.Clock()
+	return time.Duration(h)*time.Hour +
+		time.Duration(m)*time.Minute +
+		time.Duration(s)*time.Second
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidateEvent(t *testing.T) {
+	start := time.Date(2030, time.January, 1, 18, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		name   string
+		change func(e *Event)
+		want   []string
+	}{
+		{"valid", func(e *Event) {}, nil},
+		{"occurrence id", func(e *Event) { e.ID = OccurrenceID("e1", start) }, []string{"id"}},
+		{"series", func(e *Event) { e.SeriesID = "s1" }, []string{"series_id"}},
+		{"blank name", func(e *Event) { e.Name = " " }, []string{"name"}},
+		{
+			"no dates",
+			func(e *Event) { e.StartDate, e.EndDate = time.Time{}, time.Time{} },
+			[]string{"start_date", "end_date"},
+		},
+		{
+			"end before start",
+			func(e *Event) { e.EndDate = start.Add(-time.Hour) },
+			[]string{"end_date"},
+		},
+		{"end at start", func(e *Event) { e.EndDate = start }, []string{"end_date"}},
+		{"duration", func(e *Event) { e.Duration = 2 * time.Hour }, nil},
+		{"wrong duration", func(e *Event) { e.Duration = time.Hour }, []string{"duration"}},
+		{"no hall", func(e *Event) { e.Hall = "" }, nil},
+		{"unknown hall", func(e *Event) { e.Hall = "Z" }, []string{"hall"}},
+		{"several fields", func(e *Event) { e.Name, e.Hall = "", "Z" }, []string{"name", "hall"}},
+		{
+			"closed",
+			func(e *Event) {
+				e.Location.OpenTime = time.Date(0, 1, 1, 9, 0, 0, 0, time.UTC)
+				e.Location.CloseTime = time.Date(0, 1, 1, 17, 0, 0, 0, time.UTC)
+			},
+			[]string{"start_date"},
+		},
+		{"recurring", func(e *Event) { e.Recurrence = &Recurrence{RRule: "FREQ=DAILY"} }, nil},
+		{
+			"unknown rule",
+			func(e *Event) { e.Recurrence = &Recurrence{RRule: "FREQ=HOURLY"} },
+			[]string{"recurrence.rrule"},
+		},
+		{
+			"unknown time zone",
+			func(e *Event) {
+				e.Recurrence = &Recurrence{RRule: "FREQ=DAILY", TZID: "Mars/Olympus"}
+			},
+			[]string{"recurrence.tzid"},
+		},
+		{
+			// 2030-01-01 is a Tuesday.
+			"not the first occurrence",
+			func(e *Event) { e.Recurrence = &Recurrence{RRule: "FREQ=WEEKLY;BYDAY=MO"} },
+			[]string{"start_date"},
+		},
+		{
+			"exception",
+			func(e *Event) {
+				e.Recurrence = &Recurrence{RRule: "FREQ=DAILY", Exceptions: []Exception{{
+					RecurrenceID: start.Add(day), Name: "Moved", Hall: "A",
+					StartDate: start.Add(day + time.Hour), EndDate: start.Add(day + 2*time.Hour),
+				}}}
+			},
+			nil,
+		},
+		{
+			"invalid exception",
+			func(e *Event) {
+				e.Recurrence = &Recurrence{RRule: "FREQ=DAILY", Exceptions: []Exception{{
+					RecurrenceID: start.Add(day + time.Hour), Hall: "Z",
+					StartDate: start.Add(day), EndDate: start.Add(day),
+				}}}
+			},
+			[]string{
+				"recurrence.exceptions[0].recurrence_id",
+				"recurrence.exceptions[0].name",
+				"recurrence.exceptions[0].end_date",
+				"recurrence.exceptions[0].hall",
+			},
+		},
+	}
+	for _, tt := range tests {
+		e := Event{
+			ID:        "e1",
+			Name:      "Meetup",
+			StartDate: start,
+			EndDate:   start.Add(2 * time.Hour),
+			Location:  Location{ID: "l1", Halls: []Hall{{Name: "A", Capacity: 100}}},
+			Hall:      "A",
+		}
+		tt.change(&e)
+		if got := invalidFields(ValidateEvent(&e)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: ValidateEvent() fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(l *Location)
+		want   []string
+	}{
+		{"valid", func(l *Location) {}, nil},
+		{"no halls", func(l *Location) { l.Halls = nil }, nil},
+		{"blank name", func(l *Location) { l.Name = "\t" }, []string{"name"}},
+		{"blank hall name", func(l *Location) { l.Halls[1].Name = "" }, []string{"halls[1].name"}},
+		{"duplicate hall", func(l *Location) { l.Halls[1].Name = "A" }, []string{"halls[1].name"}},
+		{"no capacity", func(l *Location) { l.Halls[0].Capacity = 0 }, nil},
+		{
+			"negative capacity",
+			func(l *Location) { l.Halls[0].Capacity = -1 },
+			[]string{"halls[0].capacity"},
+		},
+		{"own hall", func(l *Location) { l.Halls[0].Location = "l1" }, nil},
+		{
+			"hall of another location",
+			func(l *Location) { l.Halls[0].Location = "l2" },
+			[]string{"halls[0].location"},
+		},
+	}
+	for _, tt := range tests {
+		l := Location{
+			ID:    "l1",
+			Name:  "Arena",
+			Halls: []Hall{{Name: "A", Capacity: 100}, {Name: "B", Capacity: 200}},
+		}
+		tt.change(&l)
+		if got := invalidFields(ValidateLocation(&l)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: ValidateLocation() fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLocationIsOpen(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	hours := func(open, close int, tz *time.Location) Location {
+		return Location{
+			OpenTime:  time.Date(0, 1, 1, open, 0, 0, 0, tz),
+			CloseTime: time.Date(0, 1, 1, close, 0, 0, 0, tz),
+		}
+	}
+	at := func(day, hour int) time.Time {
+		return time.Date(2030, time.January, day, hour, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		location   Location
+		start, end time.Time
+		want       bool
+	}{
+		{"always open", Location{}, at(1, 3), at(1, 5), true},
+		{"within hours", hours(9, 17, time.UTC), at(1, 10), at(1, 12), true},
+		{"whole hours", hours(9, 17, time.UTC), at(1, 9), at(1, 17), true},
+		{"before opening", hours(9, 17, time.UTC), at(1, 8), at(1, 10), false},
+		{"after closing", hours(9, 17, time.UTC), at(1, 16), at(1, 18), false},
+		{"next day", hours(9, 17, time.UTC), at(1, 16), at(2, 10), false},
+		{"over midnight", hours(20, 2, time.UTC), at(1, 23), at(2, 1), true},
+		{"after midnight", hours(20, 2, time.UTC), at(2, 1), at(2, 2), true},
+		{"past closing", hours(20, 2, time.UTC), at(2, 1), at(2, 3), false},
+		{"before evening", hours(20, 2, time.UTC), at(1, 18), at(1, 21), false},
+		// The location opens at 8:00 UTC in the winter.
+		{"time zone", hours(9, 17, amsterdam), at(1, 8), at(1, 10), true},
+		{"time zone closed", hours(9, 17, amsterdam), at(1, 15), at(1, 17), false},
+	}
+	for _, tt := range tests {
+		if got := tt.location.IsOpen(tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: IsOpen(%v, %v) = %v, want %v", tt.name, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+// invalidFields returns the fields listed by the given validation error, or nil
+// if the error is nil. Other errors are returned as their message.
+func invalidFields(err error) []string {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		if err != nil {
+			return []string{err.Error()}
+		}
+		return nil
+	}
+	fields := make([]string, len(verr.Fields))
+	for i, f := range verr.Fields {
+		fields[i] = f.Field
+	}
+	return fields
+}
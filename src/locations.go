@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// linkLocation makes sure that the location referenced by the given event
+// exists and replaces the location data of the event with the stored one. The
+// function returns the version of the event that should be stored in the
+// container. If only location references are stored, then the returned event
+// holds just the id of its location. This function returns a
+// [internal.ValidationError] if the location of the event does not exist.
+func (h *restHandler) linkLocation(
+	ctx context.Context,
+	event *internal.Event,
+) (internal.Event, error) {
+	if event.Location.ID == "" {
+		return internal.Event{}, &internal.ValidationError{Fields: []internal.FieldError{
+			{Field: "location.id", Message: "must not be empty"},
+		}}
+	}
+
+	location, err := h.getLocation(ctx, event.Location.ID)
+	if errors.Is(err, service.ErrNotFound) {
+		return internal.Event{}, unknownLocation(event.Location.ID)
+	}
+	if err != nil {
+		return internal.Event{}, err
+	}
+	event.Location = location
+
+	stored := *event
+	if h.locationRefs {
+		stored.Location = internal.Location{ID: location.ID}
+	}
+	return stored, nil
+}
+
+// lockLocation retrieves the location with the given id and rewrites it
+// unchanged, so that concurrent transactions writing the location conflict with
+// each other, e.g. one deleting the location and another storing an event at
+// it, or two changing the location. One of them is then aborted and retried,
+// and sees the changes of the other. It must run within the transaction that
+// writes the location or the events taking place at it. This function returns
+// [service.ErrNotFound] if the location does not exist.
+func (h *restHandler) lockLocation(ctx context.Context, id string) (internal.Location, error) {
+	location, err := h.getLocation(ctx, id)
+	if err != nil {
+		return internal.Location{}, err
+	}
+	err = h.eventsDB.Replace(ctx, internal.LocationsCollection, location.ID, location)
+	return location, err //nolint:wrapcheck // the container returns service errors
+}
+
+// changeLocation replaces the location with the given id by the location that
+// change returns for the stored one, and writes an [internal.LocationUpdated]
+// message to the outbox. The location is locked, authorized, changed,
+// validated and stored within the same transaction, so that concurrent changes
+// of the location and of its events do not get lost. The owner of the location
+// does not change. The function returns the stored location. It returns
+// [service.ErrNotAllowed] if the change removes or renames a hall, in which
+// upcoming events are taking place.
+func (h *restHandler) changeLocation(
+	ctx context.Context,
+	id string,
+	change func(current internal.Location) (internal.Location, error),
+) (internal.Location, error) {
+	var location internal.Location
+	err := h.transact(ctx, func(ctx context.Context) error {
+		current, err := h.lockLocation(ctx, id)
+		if err != nil {
+			return err
+		}
+		err = h.authorize(ctx, auth.ActionUpdate, auth.ResourceLocation, current.Owner)
+		if err != nil {
+			return err
+		}
+		if location, err = change(current); err != nil {
+			return err
+		}
+		location.Owner = current.Owner
+		if err := internal.ValidateLocation(&location); err != nil {
+			return err
+		}
+		if err := h.checkHallsUnused(ctx, id, removedHalls(&current, &location)); err != nil {
+			return err
+		}
+		err = h.eventsDB.Replace(ctx, internal.LocationsCollection, id, location)
+		if err != nil {
+			return err //nolint:wrapcheck // the container returns service errors
+		}
+		msg := internal.LocationUpdated{ID: location.ID, Name: location.Name}
+		return enqueue(ctx, h.eventsDB, internal.LocationUpdatedTopic, msg)
+	})
+	return location, err
+}
+
+// lockEventLocation makes sure that the location of the event still exists,
+// and locks it, see [restHandler.lockLocation]. It must run within the
+// transaction that stores the event. This function returns a
+// [internal.ValidationError] if the location does not exist.
+func (h *restHandler) lockEventLocation(ctx context.Context, event *internal.Event) error {
+	_, err := h.lockLocation(ctx, event.Location.ID)
+	if errors.Is(err, service.ErrNotFound) {
+		return unknownLocation(event.Location.ID)
+	}
+	return err
+}
+
+// unknownLocation returns the validation error of an event referencing the
+// location with the given id, which does not exist.
+func unknownLocation(id string) error {
+	return &internal.ValidationError{Fields: []internal.FieldError{
+		{Field: "location.id", Message: fmt.Sprintf("unknown location %q", id)},
+	}}
+}
+
+// fillLocations fills in the location data of the given events, in case only
+// location references are stored. Events without a location id, or referencing
+// a location that no longer exists, are left unchanged.
+func (h *restHandler) fillLocations(ctx context.Context, events ...*internal.Event) error {
+	return h.fillLocationsFrom(ctx, make(map[string]internal.Location), events...)
+}
+
+// fillLocationsFrom is like [restHandler.fillLocations], but looks up the
+// locations in the given cache first, and adds the retrieved locations to it.
+// Many events usually take place at the same location, so the cache can be
+// shared while filling in many batches of events.
+func (h *restHandler) fillLocationsFrom(
+	ctx context.Context,
+	cache map[string]internal.Location,
+	events ...*internal.Event,
+) error {
+	if !h.locationRefs {
+		return nil
+	}
+	for _, e := range events {
+		if e.Location.ID == "" {
+			continue
+		}
+		location, ok := cache[e.Location.ID]
+		if !ok {
+			l, err := h.getLocation(ctx, e.Location.ID)
+			switch {
+			case errors.Is(err, service.ErrNotFound):
+				internal.Logger(ctx).Info("event references a missing location",
+					slog.String("id", e.ID),
+					slog.String("location_id", e.Location.ID),
+				)
+			case err != nil:
+				return err
+			}
+			location, cache[e.Location.ID] = l, l
+		}
+		if location.ID != "" {
+			e.Location = location
+		}
+	}
+	return nil
+}
+
+// checkLocationUnused makes sure that no upcoming events are taking place at
+// the location with the given id. The stored dates of a recurring event are
+// those of its first occurrence, so the recurring events are checked for
+// upcoming occurrences instead. This function returns [service.ErrNotAllowed]
+// if the location is still in use.
+func (h *restHandler) checkLocationUnused(ctx context.Context, id string) error {
+	now := time.Now()
+	filter := internal.Filter{LocationID: id, EndsAfter: now, NonRecurring: true}
+	n, err := h.eventsDB.CountEvents(ctx, &filter)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf(
+			"%w: location %q has %d upcoming events", service.ErrNotAllowed, id, n)
+	}
+
+	filter = internal.Filter{LocationID: id, Recurring: true}
+	err = h.eventsDB.ForEachEvent(ctx, &filter, func(e *internal.Event) error {
+		occurrences, err := e.Occurrences(now, now.AddDate(maxRecurrenceYears, 0, 0), 1)
+		if err != nil {
+			return internal.Unexpected(ctx, err)
+		}
+		if len(occurrences) > 0 {
+			return fmt.Errorf("%w: location %q has upcoming occurrences of event %q",
+				service.ErrNotAllowed, id, e.ID)
+		}
+		return nil
+	})
+	return err //nolint:wrapcheck // the container returns service errors
+}
+
+// checkHallsUnused makes sure that no upcoming events or occurrences of
+// recurring events are taking place in the given halls of the location with
+// the given id. This function returns [service.ErrNotAllowed] if one of the
+// halls is still in use.
+func (h *restHandler) checkHallsUnused(ctx context.Context, id string, halls []string) error {
+	if len(halls) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, hall := range halls {
+		filter := internal.Filter{LocationID: id, Hall: hall, EndsAfter: now, NonRecurring: true}
+		n, err := h.eventsDB.CountEvents(ctx, &filter)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%w: hall %q of location %q has %d upcoming events",
+				service.ErrNotAllowed, hall, id, n)
+		}
+	}
+
+	// The exceptions can move occurrences into other halls, so the series
+	// are expanded if the hall of any of their occurrences is one of the
+	// given halls. A series with too many occurrences to check is assumed to
+	// be using the halls.
+	filter := internal.Filter{LocationID: id, Recurring: true}
+	err := h.eventsDB.ForEachEvent(ctx, &filter, func(e *internal.Event) error {
+		if !usesHalls(e, halls) {
+			return nil
+		}
+		occurrences, err := e.Occurrences(
+			now, now.AddDate(maxRecurrenceYears, 0, 0), maxExpandedEvents)
+		if err != nil {
+			return internal.Unexpected(ctx, err)
+		}
+		if len(occurrences) == maxExpandedEvents || slices.ContainsFunc(occurrences,
+			func(o internal.Event) bool { return slices.Contains(halls, o.Hall) }) {
+			return fmt.Errorf("%w: location %q has upcoming occurrences of event %q "+
+				"in the halls %q", service.ErrNotAllowed, id, e.ID, halls)
+		}
+		return nil
+	})
+	return err //nolint:wrapcheck // the container returns service errors
+}
+
+// usesHalls returns true if the recurring event or any of its exceptions takes
+// place in one of the given halls.
+func usesHalls(e *internal.Event, halls []string) bool {
+	return slices.Contains(halls, e.Hall) ||
+		slices.ContainsFunc(e.Recurrence.Exceptions, func(x internal.Exception) bool {
+			return slices.Contains(halls, x.Hall)
+		})
+}
+
+// removedHalls returns the names of the halls of the current location, which
+// the changed location no longer has, i.e. the halls that were removed or
+// renamed.
+func removedHalls(current, changed *internal.Location) []string {
+	var removed []string
+	for _, hall := range current.Halls {
+		if !slices.ContainsFunc(changed.Halls, func(h internal.Hall) bool {
+			return h.Name == hall.Name
+		}) {
+			removed = append(removed, hall.Name)
+		}
+	}
+	return removed
+}
+
+// getLocation retrieves the location with the given id from the container.
+// This function returns [service.ErrNotFound] if the location does not exist.
+func (h *restHandler) getLocation(ctx context.Context, id string) (internal.Location, error) {
+	elem, err := h.eventsDB.GetByID(ctx, internal.LocationsCollection, id)
+	if err != nil {
+		return internal.Location{}, err
+	}
+	location, ok := elem.(internal.Location)
+	if !ok {
+		return internal.Location{}, internal.Unexpected(ctx, fmt.Errorf("unexpected type %T", elem))
+	}
+	return location, nil
+}
+
+// authorizeLocation makes sure that the client of the request may perform the
+// action on the stored location with the given id, and returns the location.
+// This function returns [service.ErrNotFound] if there is no such location, and
+// [service.ErrNotAllowed] if the client may not perform the action.
+func (h *restHandler) authorizeLocation(
+	ctx context.Context,
+	action, id string,
+) (internal.Location, error) {
+	location, err := h.getLocation(ctx, id)
+	if err != nil {
+		return internal.Location{}, err
+	}
+	if err := h.authorize(ctx, action, auth.ResourceLocation, location.Owner); err != nil {
+		return internal.Location{}, err
+	}
+	return location, nil
+}
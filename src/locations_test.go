@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+func TestCheckLocationUnused(t *testing.T) {
+	// The series started a month ago, so its stored dates are in the past.
+	start := time.Now().UTC().Truncate(time.Hour).AddDate(0, -1, 0)
+	past := internal.Event{
+		ID:         "past",
+		Name:       "Past Meetup",
+		StartDate:  start,
+		EndDate:    start.Add(2 * time.Hour),
+		Location:   internal.Location{ID: "l1"},
+		Hall:       "A",
+		Recurrence: &internal.Recurrence{RRule: "FREQ=WEEKLY;COUNT=2"},
+	}
+	weekly := past
+	weekly.ID, weekly.Recurrence = "weekly", &internal.Recurrence{RRule: "FREQ=WEEKLY"}
+
+	tests := []struct {
+		name   string
+		events []internal.Event
+		want   error
+	}{
+		{"unused", nil, nil},
+		{"past series", []internal.Event{past}, nil},
+		{"past event", []internal.Event{testEventAt("e1", start)}, nil},
+		{"upcoming event", []internal.Event{testEvent("e1", "A", 10)}, service.ErrNotAllowed},
+		{"upcoming occurrences", []internal.Event{weekly}, service.ErrNotAllowed},
+	}
+	for _, tt := range tests {
+		h, ctx := newTestHandler(t, false)
+		for _, e := range tt.events {
+			if err := h.eventsDB.Create(ctx, internal.EventsCollection, e); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+		err := h.checkLocationUnused(ctx, "l1")
+		if (tt.want == nil && err != nil) || !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkLocationUnused() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestChangeLocation(t *testing.T) {
+	h, ctx := newTestHandler(t, false)
+	rename := func(name string) func(internal.Location) (internal.Location, error) {
+		return func(current internal.Location) (internal.Location, error) {
+			current.Name, current.Owner = name, "someone else"
+			return current, nil
+		}
+	}
+
+	// An invalid change is not stored and not announced.
+	var verr *internal.ValidationError
+	if _, err := h.changeLocation(ctx, "l1", rename(" ")); !errors.As(err, &verr) {
+		t.Errorf("changeLocation() error = %v, want a validation error", err)
+	}
+	if _, err := h.changeLocation(ctx, "l2", rename("Club")); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("changeLocation() error = %v, want %v", err, service.ErrNotFound)
+	}
+	records, err := h.eventsDB.PendingOutbox(ctx, 10)
+	if err != nil || len(records) != 0 {
+		t.Errorf("PendingOutbox() = %d records, %v, want none", len(records), err)
+	}
+
+	// The change is stored and announced, and the owner is kept.
+	location, err := h.changeLocation(ctx, "l1", rename("Stadium"))
+	if err != nil {
+		t.Fatalf("changeLocation() error = %v", err)
+	}
+	stored, err := h.getLocation(ctx, "l1")
+	if err != nil || stored.Name != "Stadium" || stored.Owner != "" || location.Owner != "" {
+		t.Errorf("getLocation() = %+v, %v, want the renamed location", stored, err)
+	}
+	records, err = h.eventsDB.PendingOutbox(ctx, 10)
+	if err != nil || len(records) != 1 || records[0].Topic != internal.LocationUpdatedTopic {
+		t.Errorf("PendingOutbox() = %+v, %v, want a %s message",
+			records, err, internal.LocationUpdatedTopic)
+	}
+}
+
+func TestChangeLocationHalls(t *testing.T) {
+	// The series started a month ago, so its stored dates are in the past.
+	start := time.Now().UTC().Truncate(time.Hour).AddDate(0, -1, 0)
+	weekly := testEventAt("weekly", start)
+	weekly.Recurrence = &internal.Recurrence{RRule: "FREQ=WEEKLY"}
+	moved := testEventAt("moved", start)
+	moved.Recurrence = &internal.Recurrence{RRule: "FREQ=WEEKLY;COUNT=8"}
+	next := start.AddDate(0, 0, 7*6)
+	moved.Recurrence.SetException(internal.Exception{
+		RecurrenceID: next, Name: "Moved", Hall: "B",
+		StartDate: next, EndDate: next.Add(time.Hour),
+	})
+	moved.Hall = "C"
+
+	tests := []struct {
+		name   string
+		events []internal.Event
+		halls  []internal.Hall
+		want   error
+	}{
+		{"unused", nil, []internal.Hall{{Name: "C"}}, nil},
+		{"added hall", []internal.Event{testEvent("e1", "A", 10)},
+			[]internal.Hall{{Name: "A"}, {Name: "B"}, {Name: "C"}}, nil},
+		{"removed hall", []internal.Event{testEvent("e1", "B", 10)},
+			[]internal.Hall{{Name: "A"}}, service.ErrNotAllowed},
+		{"renamed hall", []internal.Event{testEvent("e1", "A", 10)},
+			[]internal.Hall{{Name: "AA"}, {Name: "B"}}, service.ErrNotAllowed},
+		{"past event", []internal.Event{testEventAt("e1", start)},
+			[]internal.Hall{{Name: "B"}}, nil},
+		{"upcoming occurrences", []internal.Event{weekly},
+			[]internal.Hall{{Name: "B"}}, service.ErrNotAllowed},
+		{"moved occurrence", []internal.Event{moved},
+			[]internal.Hall{{Name: "A"}, {Name: "C"}}, service.ErrNotAllowed},
+	}
+	for _, tt := range tests {
+		h, ctx := newTestHandler(t, false)
+		for _, e := range tt.events {
+			if err := h.eventsDB.Create(ctx, internal.EventsCollection, e); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+		_, err := h.changeLocation(ctx, "l1",
+			func(current internal.Location) (internal.Location, error) {
+				current.Halls = tt.halls
+				return current, nil
+			})
+		if (tt.want == nil && err != nil) || !errors.Is(err, tt.want) {
+			t.Errorf("%s: changeLocation() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// testEventAt returns an event taking place in the hall "A" of the location
+// "l1", starting at the given time and lasting two hours.
+func testEventAt(id string, start time.Time) internal.Event {
+	e := testEvent(id, "A", 0)
+	e.StartDate, e.EndDate = start, start.Add(2*time.Hour)
+	return e
+}
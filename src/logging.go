@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+)
+
+const (
+	// requestIDHeader is the header that carries the id of a request. The
+	// id sent by the client is kept, so that the request can be tracked
+	// across services. Otherwise, a new id is generated.
+	requestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLength is the maximum length of a request id sent by the
+	// client. Longer ids are replaced.
+	maxRequestIDLength = 128
+
+	// maxDumpSize is the maximum number of bytes of a request or response
+	// body that are dumped. Longer bodies are truncated.
+	maxDumpSize = 8 << 10
+
+	// redacted replaces the values of sensitive headers and fields.
+	redacted = "[REDACTED]"
+)
+
+// sensitiveHeaders are the headers whose values are never dumped.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"X-Admin-Token",
+	"X-Api-Key",
+}
+
+// sensitiveFields are the substrings of the names of the JSON fields whose
+// values are never dumped.
+var sensitiveFields = []string{"password", "secret", "token"}
+
+// logRequests is a middleware that assigns an id to every http request and
+// writes an access log line once the request is handled. The context of the
+// request holds a logger that carries the request id, see [internal.Logger].
+// If dump is set, then the bodies of the request and of the response are
+// logged as well, with the sensitive headers and fields redacted.
+func logRequests(dump bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := requestID(r)
+			w.Header().Set(requestIDHeader, id)
+			logger := internal.Logger(r.Context()).With(slog.String("request_id", id))
+			ctx := internal.WithLogger(r.Context(), logger)
+
+			var reqBody []byte
+			if dump {
+				reqBody = peekBody(r)
+				w = &dumpWriter{ResponseWriter: w}
+			}
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+			// The access log is written in a deferred call, so that
+			// requests aborted with a panic are logged as well.
+			defer func() {
+				logger.LogAttrs(ctx, slog.LevelInfo, "http request",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("route", routePattern(r)),
+					slog.Int("status", sw.status),
+					slog.Int("bytes", sw.size),
+					slog.Duration("duration", time.Since(start)),
+					slog.String("remote_addr", r.RemoteAddr),
+					slog.String("user_agent", r.UserAgent()),
+				)
+				if dw, ok := w.(*dumpWriter); ok {
+					logDump(ctx, logger, r, reqBody, dw)
+				}
+			}()
+			next.ServeHTTP(sw, r.WithContext(ctx))
+		})
+	}
+}
+
+// logDump logs the headers and the bodies of the request and of the response,
+// with the sensitive headers and fields redacted.
+func logDump(
+	ctx context.Context,
+	logger *slog.Logger,
+	r *http.Request,
+	reqBody []byte,
+	w *dumpWriter,
+) {
+	logger.LogAttrs(ctx, slog.LevelInfo, "http request dump",
+		slog.Group("request",
+			slog.Any("header", sanitizeHeader(r.Header)),
+			slog.String("body", sanitizeBody(r.Header, reqBody)),
+		),
+		slog.Group("response",
+			slog.Any("header", sanitizeHeader(w.Header())),
+			slog.String("body", sanitizeBody(w.Header(), w.body.Bytes())),
+		),
+	)
+}
+
+// requestID returns the id sent by the client in the X-Request-ID header. A
+// new id is generated if the client did not send an id, or if the id is too
+// long or contains characters other than printable ASCII.
+func requestID(r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		return internal.NewID()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < ' ' || id[i] > '~' {
+			return internal.NewID()
+		}
+	}
+	return id
+}
+
+// peekBody returns the beginning of the body of the request, at most
+// [maxDumpSize] bytes. The body is restored, so that the handler reads it in
+// full.
+func peekBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	// The error is reported to the handler when it reads the body.
+	b, _ := io.ReadAll(io.LimitReader(r.Body, maxDumpSize)) //nolint:errcheck // intentional
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+	return b
+}
+
+// sanitizeHeader returns a copy of the header with the values of the
+// sensitive headers redacted.
+func sanitizeHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range sensitiveHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+	return h
+}
+
+// sanitizeBody returns the body as a string that is safe to be logged. The
+// values of the sensitive fields of JSON bodies are redacted. Bodies that are
+// not text, CSV bodies, and JSON bodies that are truncated or invalid are not
+// logged, only their size. CSV bodies hold the imported and exported events,
+// and their columns are not checked for sensitive fields.
+func sanitizeBody(h http.Header, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type")) //nolint:errcheck // intentional
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/x-ndjson":
+		return sanitizeJSON(body)
+	case mediaType == "text/csv":
+		return omitted(body)
+	case strings.HasPrefix(mediaType, "text/"):
+		if len(body) == maxDumpSize {
+			return string(body) + "...(truncated)"
+		}
+		return string(body)
+	default:
+		return omitted(body)
+	}
+}
+
+// sanitizeJSON redacts the values of the sensitive fields of the given JSON
+// values, which are separated by whitespace as in NDJSON.
+func sanitizeJSON(body []byte) string {
+	var out bytes.Buffer
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var v any
+		if err := dec.Decode(&v); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return omitted(body)
+		}
+		b, err := json.Marshal(redactFields(v))
+		if err != nil {
+			return omitted(body)
+		}
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		out.Write(b)
+	}
+	return out.String()
+}
+
+// redactFields replaces the values of the sensitive fields of the decoded JSON
+// value, including those of nested objects.
+func redactFields(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if isSensitiveField(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactFields(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactFields(value)
+		}
+	}
+	return v
+}
+
+// isSensitiveField returns true if the name of the JSON field contains one of
+// the [sensitiveFields].
+func isSensitiveField(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveFields {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// omitted describes a body that is not logged.
+func omitted(body []byte) string {
+	if len(body) == maxDumpSize {
+		return fmt.Sprintf("(%d+ bytes omitted)", len(body))
+	}
+	return fmt.Sprintf("(%d bytes omitted)", len(body))
+}
+
+// dumpWriter records the beginning of the body of the response, at most
+// [maxDumpSize] bytes.
+type dumpWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write implements the [http.ResponseWriter] interface.
+func (w *dumpWriter) Write(b []byte) (int, error) {
+	if n := maxDumpSize - w.body.Len(); n > 0 {
+		w.body.Write(b[:min(n, len(b))])
+	}
+	return w.ResponseWriter.Write(b) //nolint:wrapcheck // intentional
+}
+
+// Unwrap returns the wrapped response writer, so that it can be used by
+// [http.ResponseController].
+func (w *dumpWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/eventscompass/events-service/src/internal"
+)
+
+func TestSanitizeHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc")
+	h.Set("X-Api-Key", "ops-key")
+	h.Set("Accept", "application/json")
+	got := sanitizeHeader(h)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Authorization", redacted},
+		{"X-Api-Key", redacted},
+		{"Accept", "application/json"},
+		{"Cookie", ""},
+	}
+	for _, tt := range tests {
+		if v := got.Get(tt.name); v != tt.want {
+			t.Errorf("sanitizeHeader() %s = %q, want %q", tt.name, v, tt.want)
+		}
+	}
+	if v := h.Get("Authorization"); v != "Bearer abc" {
+		t.Errorf("sanitizeHeader() changed the header to %q", v)
+	}
+}
+
+func TestSanitizeBody(t *testing.T) {
+	long := strings.Repeat("a", maxDumpSize)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"empty", "application/json", "", ""},
+		{"json", "application/json",
+			`{"name":"Jazz","password":"x"}`,
+			`{"name":"Jazz","password":"[REDACTED]"}`},
+		{"nested json", "application/merge-patch+json",
+			`{"auth":{"API_Token":"x","user":"bob"},"items":[{"secret":1}]}`,
+			`{"auth":{"API_Token":"[REDACTED]","user":"bob"},` +
+				`"items":[{"secret":"[REDACTED]"}]}`},
+		{"ndjson", "application/x-ndjson",
+			"{\"id\":\"e1\",\"token\":\"x\"}\n{\"id\":\"e2\"}\n",
+			"{\"id\":\"e1\",\"token\":\"[REDACTED]\"}\n{\"id\":\"e2\"}"},
+		{"truncated json", "application/json", `{"password":"x`, "(14 bytes omitted)"},
+		{"csv", "text/csv", "id,name\ne1,Jazz\n", "(16 bytes omitted)"},
+		{"text", "text/plain; charset=utf-8", "not found", "not found"},
+		{"truncated text", "text/plain", long, long + "...(truncated)"},
+		{"binary", "application/octet-stream", long, "(8192+ bytes omitted)"},
+	}
+	for _, tt := range tests {
+		h := http.Header{"Content-Type": []string{tt.contentType}}
+		if got := sanitizeBody(h, []byte(tt.body)); got != tt.want {
+			t.Errorf("%s: sanitizeBody() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		keep bool
+	}{
+		{"missing", "", false},
+		{"valid", "req-1", true},
+		{"longest", strings.Repeat("a", maxRequestIDLength), true},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"control character", "req\n1", false},
+		{"not ascii", "réq-1", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(requestIDHeader, tt.id)
+		got := requestID(r)
+		if (got == tt.id) != tt.keep || got == "" {
+			t.Errorf("%s: requestID(%q) = %q, want it kept: %v", tt.name, tt.id, got, tt.keep)
+		}
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := chi.NewMux()
+	handler.Use(logRequests(true))
+	handler.Post("/api/events", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"response-secret"}`))
+	})
+
+	// The id sent by the client is returned in the response.
+	body := strings.NewReader(`{"password":"request-secret"}`)
+	r := httptest.NewRequest(http.MethodPost, "/api/events", body)
+	r = r.WithContext(internal.WithLogger(r.Context(), logger))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(requestIDHeader, "req-1")
+	r.Header.Set(adminTokenHeader, "admin-secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if id := w.Header().Get(requestIDHeader); id != "req-1" {
+		t.Errorf("%s = %q, want %q", requestIDHeader, id, "req-1")
+	}
+
+	// The logs carry the request id and the route, and no sensitive values.
+	for _, want := range []string{`"request_id":"req-1"`, `"route":"/api/events"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs = %s, want %s", &logs, want)
+		}
+	}
+	for _, secret := range []string{"request-secret", "response-secret", "admin-secret"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("logs = %s, want %q redacted", &logs, secret)
+		}
+	}
+
+	// Otherwise, a new id is generated.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	if id := w.Header().Get(requestIDHeader); id == "" {
+		t.Errorf("%s is missing", requestIDHeader)
+	}
+}
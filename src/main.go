@@ -3,14 +3,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/caarlos0/env/v6"
+	"google.golang.org/grpc"
 
 	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/filestore"
+	"github.com/eventscompass/events-service/src/internal/memory"
 	"github.com/eventscompass/events-service/src/internal/mongodb"
+	"github.com/eventscompass/events-service/src/internal/tracing"
 	"github.com/eventscompass/service-framework/pubsub"
-	"github.com/eventscompass/service-framework/pubsub/rabbitmq"
 	"github.com/eventscompass/service-framework/service"
 )
 
@@ -23,12 +27,39 @@ type EventsService struct {
 	// http requests to the rest api of the service.
 	restHandler http.Handler
 
+	// grpcServer serves grpc requests to the grpc api of the
+	// service.
+	grpcServer *grpc.Server
+
 	// eventBus is used for publishing and subscribing to messages.
-	eventsBus service.MessageBus
+	eventsBus messageBus
 
 	// eventsDB is used to read and store elements in a container database.
 	eventsDB internal.EventsContainer
 
+	// relay publishes the messages written to the outbox of the
+	// container to the message bus.
+	relay *outboxRelay
+
+	// announcer announces the occurrences of the recurring
+	// events.
+	announcer *announcer
+
+	// auth authenticates the clients of the rest and grpc APIs.
+	auth *authPolicy
+
+	// tenants resolves the tenant of the requests to the rest
+	// and grpc APIs.
+	tenants *tenancy
+
+	// metrics are the metrics reported by the service.
+	metrics *serviceMetrics
+
+	// tracer traces the requests handled by the service, and
+	// exportTraces exports the spans.
+	tracer       *tracing.Tracer
+	exportTraces func(context.Context) error
+
 	// cfg is used to configure the service.
 	cfg *Config
 }
@@ -42,28 +73,123 @@ func (s *EventsService) Init(ctx context.Context) error {
 	}
 	s.cfg = &cfg
 
+	// Init the tracing.
+	tracer, exportTraces, err := newTracer(&s.cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("init tracing: %w", err)
+	}
+	s.tracer, s.exportTraces = tracer, exportTraces
+
+	// Init the authentication.
+	authPolicy, err := newAuthPolicy(&s.cfg.Auth, s.cfg.AdminToken)
+	if err != nil {
+		return fmt.Errorf("init auth: %w", err)
+	}
+	s.auth = authPolicy
+
+	// Init the resolution of the tenants.
+	tenants, err := newTenancy(&s.cfg.Tenants)
+	if err != nil {
+		return fmt.Errorf("init tenants: %w", err)
+	}
+	s.tenants = tenants
+
 	// Init the database layer.
-	mongoCfg := mongodb.Config(s.cfg.EventsDB)
-	db, err := mongodb.NewMongoDBContainer(ctx, &mongoCfg)
+	db, err := newEventsDB(ctx, &s.cfg.EventsDB)
 	if err != nil {
 		return fmt.Errorf("init db: %w", err)
 	}
+	s.metrics = newServiceMetrics(db)
+	db = &instrumentedContainer{EventsContainer: db, metrics: s.metrics, tracer: tracer}
 	s.eventsDB = db
 
 	// Init the message bus,
-	busCfg := rabbitmq.Config(s.cfg.EventsMQ)
-	bus, err := rabbitmq.NewAMQPBus(&busCfg, pubsub.EventsExchange)
+	bus, err := newEventsBus(&s.cfg.EventsMQ)
 	if err != nil {
 		return fmt.Errorf("init mq: %w", err)
 	}
-	s.eventsBus = bus
+	s.eventsBus = &instrumentedBus{messageBus: bus, metrics: s.metrics, tracer: tracer}
+	s.relay = newOutboxRelay(db, s.eventsBus, cfg.OutboxInterval, cfg.OutboxMaxBackoff)
+	s.announcer = &announcer{db: db, relay: s.relay, horizon: cfg.RecurrenceHorizon}
 
-	// Init the rest API of the service.
+	// Init the rest and grpc APIs of the service.
 	s.initREST()
+	s.initGRPC()
 
 	return nil
 }
 
+// Bus implements the [service.CloudService] interface.
+func (s *EventsService) Bus() service.MessageBus {
+	return s.eventsBus
+}
+
+// Events implements the [service.CloudService] interface.
+func (s *EventsService) Events() map[string]service.EventHandler {
+	return map[string]service.EventHandler{
+		pubsub.EventBookedTopic: s.eventBooked,
+	}
+}
+
+// Tasks implements the [service.BackgroundService] interface. The outbox relay
+// publishes the messages, the announcer announces the occurrences of the
+// recurring events, and the spans are exported.
+func (s *EventsService) Tasks() map[string]func(context.Context) error {
+	return map[string]func(context.Context) error{
+		"outbox-relay":  s.relay.run,
+		"announcer":     s.announcer.run,
+		"traces-export": s.exportTraces,
+	}
+}
+
+// newEventsDB creates the database layer selected by the configuration.
+func newEventsDB(ctx context.Context, cfg *DBConfig) (internal.EventsContainer, error) {
+	switch cfg.Backend {
+	case "mongodb":
+		return mongodb.NewMongoDBContainer(ctx, &mongodb.Config{
+			Host:        cfg.Host,
+			Port:        cfg.Port,
+			Username:    cfg.Username,
+			Password:    cfg.Password,
+			Database:    cfg.Database,
+			UniqueNames: cfg.UniqueNames,
+		})
+	case "file":
+		return filestore.NewContainer(ctx, &filestore.Config{
+			Dir:             cfg.Path,
+			CompactInterval: cfg.CompactInterval,
+		})
+	case "memory":
+		slog.Warn("using an in-memory database, data will not be persisted")
+		return memory.NewContainer(), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown db backend %q", service.ErrUnexpected, cfg.Backend)
+	}
+}
+
+// messageBus is a message bus whose connection can be checked.
+type messageBus interface {
+	service.MessageBus
+
+	// Ping checks that the bus can deliver messages. This
+	// function returns [service.ErrConnectionClosed] if it
+	// cannot.
+	Ping(_ context.Context) error
+}
+
+// newEventsBus creates the message bus selected by the configuration.
+func newEventsBus(cfg *BusConfig) (messageBus, error) {
+	switch cfg.Backend {
+	case "rabbitmq":
+		return newAMQPBus(cfg)
+	case "memory":
+		slog.Warn("using an in-memory message bus, messages will not leave the service")
+		return memory.NewBus(), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown mq backend %q", service.ErrUnexpected, cfg.Backend)
+	}
+}
+
 func main() {
 	service.Start(&EventsService{})
 }
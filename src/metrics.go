@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/metrics"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// serviceMetrics are the metrics reported by the service at the /metrics
+// endpoint, in the Prometheus text exposition format.
+type serviceMetrics struct {
+	registry *metrics.Registry
+
+	// httpRequests and httpDuration count and time the http
+	// requests by their method, route pattern and status code.
+	httpRequests *metrics.CounterVec
+	httpDuration *metrics.HistogramVec
+
+	// dbDuration and dbErrors time the operations of the
+	// container and count their errors by kind.
+	dbDuration *metrics.HistogramVec
+	dbErrors   *metrics.CounterVec
+
+	// publishes counts the messages published to the message
+	// bus by their topic and result.
+	publishes *metrics.CounterVec
+}
+
+// newServiceMetrics creates the metrics of the service. The number of stored
+// events is read from the given container whenever the metrics are collected.
+// The events of all tenants are counted at once, so that the cost of a scrape
+// does not grow with the number of tenants.
+func newServiceMetrics(db internal.EventsContainer) *serviceMetrics {
+	r := metrics.NewRegistry()
+	r.NewGaugeFunc("events_stored", "Number of events stored in the container.",
+		func(ctx context.Context) (float64, error) {
+			n, err := db.CountAll(ctx, internal.EventsCollection)
+			if err != nil {
+				return 0, err //nolint:wrapcheck // the container returns service errors
+			}
+			return float64(n), nil
+		})
+	return &serviceMetrics{
+		registry: r,
+		httpRequests: r.NewCounterVec("http_requests_total",
+			"Number of http requests.", "method", "route", "code"),
+		httpDuration: r.NewHistogramVec("http_request_duration_seconds",
+			"Latency of http requests.", metrics.DefaultBuckets, "method", "route", "code"),
+		dbDuration: r.NewHistogramVec("container_operation_duration_seconds",
+			"Latency of container operations.", metrics.DefaultBuckets, "operation"),
+		dbErrors: r.NewCounterVec("container_operation_errors_total",
+			"Number of failed container operations.", "operation", "kind"),
+		publishes: r.NewCounterVec("messages_published_total",
+			"Number of messages published to the message bus.", "topic", "result"),
+	}
+}
+
+// instrument is a middleware that records the metrics of the http requests.
+// The requests are labeled with the pattern of the matched route, instead of
+// their path, so that the number of series is bounded.
+func (m *serviceMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		// The metrics are recorded in a deferred call, so that
+		// requests aborted with a panic are recorded as well.
+		defer func() {
+			route := routePattern(r)
+			code := strconv.Itoa(sw.status)
+			m.httpRequests.Inc(r.Method, route, code)
+			m.httpDuration.Observe(time.Since(start).Seconds(), r.Method, route, code)
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// routePattern returns the pattern of the route that matched the request, or
+// "unmatched" if no route matched it.
+func routePattern(r *http.Request) string {
+	if route := chi.RouteContext(r.Context()).RoutePattern(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// statusWriter records the status code and the size of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+// WriteHeader implements the [http.ResponseWriter] interface.
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements the [http.ResponseWriter] interface.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err //nolint:wrapcheck // intentional
+}
+
+// Unwrap returns the wrapped response writer, so that it can be used by
+// [http.ResponseController].
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// observe records the latency of a container operation that started at the
+// given time, and counts the error returned by the operation, if any.
+func (m *serviceMetrics) observe(operation string, start time.Time, err error) {
+	m.dbDuration.Observe(time.Since(start).Seconds(), operation)
+	if err != nil {
+		m.dbErrors.Inc(operation, errorKind(err))
+	}
+}
+
+// errorKind returns the kind of the error, which is used as a label value.
+func errorKind(err error) string {
+	kinds := []struct {
+		target error
+		kind   string
+	}{
+		{service.ErrNotFound, "not_found"},
+		{service.ErrAlreadyExists, "already_exists"},
+		{service.ErrBadRequest, "bad_request"},
+		{service.ErrNotAllowed, "not_allowed"},
+		{service.ErrSpaceFull, "space_full"},
+		{service.ErrConnectionClosed, "connection_closed"},
+		{service.ErrTimeOut, "timeout"},
+		{service.ErrUnexpected, "unexpected"},
+		{context.Canceled, "canceled"},
+		{context.DeadlineExceeded, "deadline_exceeded"},
+	}
+	for _, k := range kinds {
+		if errors.Is(err, k.target) {
+			return k.kind
+		}
+	}
+	return "other"
+}
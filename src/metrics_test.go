@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/memory"
+)
+
+func TestEventsStored(t *testing.T) {
+	db := memory.NewContainer()
+	for _, tenant := range []string{internal.DefaultTenant, "acme", "globex"} {
+		ctx := internal.WithTenant(context.Background(), tenant)
+		if err := db.Create(ctx, internal.EventsCollection, testEvent("e1", "A", 10)); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	m := newServiceMetrics(db)
+
+	// The events of every tenant are counted.
+	w := httptest.NewRecorder()
+	m.registry.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), "\nevents_stored 3\n") {
+		t.Errorf("metrics = %q, want events_stored 3", w.Body)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/tracing"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// outboxBatchSize is the number of outbox records that are retrieved at once
+// by the relay.
+const outboxBatchSize = 100
+
+// enqueue encodes the payload and writes it to the outbox, from where it will
+// be published to the given topic of the message bus. The context should
+// belong to the transaction that commits the change announced by the message.
+// The trace context is stored with the message, so that the message is
+// published within the trace of the change. The message carries the tenant of
+// the context in its "tenant_id" field.
+func enqueue(ctx context.Context, db internal.EventsContainer, topic string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return internal.Unexpected(ctx, fmt.Errorf("marshal payload: %w", err))
+	}
+	if body, err = withTenantField(ctx, body); err != nil {
+		return err
+	}
+	record := internal.OutboxRecord{
+		ID:        internal.NewID(),
+		Topic:     topic,
+		Payload:   body,
+		CreatedAt: time.Now().UTC(),
+
+		TraceParent: tracing.SpanContextFromContext(ctx).Traceparent(),
+	}
+	if err := db.Create(ctx, internal.OutboxCollection, record); err != nil {
+		return fmt.Errorf("create outbox record: %w", err)
+	}
+	return nil
+}
+
+// outboxRelay publishes the records of the outbox to the message bus, in the
+// order in which they were written, and removes them from the outbox. A record
+// is removed only after it was published, thus every message is published at
+// least once. Failures are retried with an exponential backoff.
+type outboxRelay struct {
+	db  internal.EventsContainer
+	bus service.MessageBus
+
+	// interval is the interval at which the outbox is polled
+	// for pending records. It is also the initial backoff
+	// after a failure.
+	interval time.Duration
+
+	// maxBackoff is the maximum time to wait before retrying
+	// after a failure.
+	maxBackoff time.Duration
+
+	// wake is used to wake up the relay when new records are
+	// written to the outbox, instead of waiting for the next
+	// poll.
+	wake chan struct{}
+}
+
+// newOutboxRelay creates a new [outboxRelay] instance.
+func newOutboxRelay(
+	db internal.EventsContainer,
+	bus service.MessageBus,
+	interval time.Duration,
+	maxBackoff time.Duration,
+) *outboxRelay {
+	return &outboxRelay{
+		db:         db,
+		bus:        bus,
+		interval:   interval,
+		maxBackoff: maxBackoff,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// notify wakes up the relay. It does not block.
+func (r *outboxRelay) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default: // the relay is already notified
+	}
+}
+
+// run runs the relay until the context is cancelled.
+func (r *outboxRelay) run(ctx context.Context) error {
+	slog.Info("starting outbox relay")
+	var backoff time.Duration
+	for {
+		delay := r.interval
+		if err := r.relay(ctx); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			backoff = min(max(2*backoff, r.interval), r.maxBackoff)
+			delay = backoff
+			slog.Error(
+				"failed to relay outbox",
+				slog.String("error", err.Error()),
+				slog.Duration("retry_in", backoff),
+			)
+		} else {
+			backoff = 0
+		}
+
+		// Note that while backing off the relay is not woken up by new
+		// records, since publishing would most probably fail again.
+		wake := r.wake
+		if backoff > 0 {
+			wake = nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	slog.Info("stopping outbox relay")
+	return nil
+}
+
+// relay publishes the pending records until the outbox is empty.
+func (r *outboxRelay) relay(ctx context.Context) error {
+	for {
+		records, err := r.db.PendingOutbox(ctx, outboxBatchSize)
+		if err != nil {
+			return fmt.Errorf("pending outbox: %w", err)
+		}
+		for _, record := range records {
+			// The records of every tenant are relayed, each within
+			// the tenant that wrote it.
+			tenantCtx := internal.WithTenant(ctx, record.Tenant)
+			sc, _ := tracing.ParseTraceparent(record.TraceParent)
+			publishCtx := tracing.ContextWithRemoteSpanContext(tenantCtx, sc)
+			if err := r.bus.Publish(publishCtx, record.Topic, record.Payload); err != nil {
+				return fmt.Errorf("publish %s: %w", record.ID, err)
+			}
+			err := r.db.Delete(tenantCtx, internal.OutboxCollection, record.ID)
+			if err != nil && !errors.Is(err, service.ErrNotFound) {
+				return fmt.Errorf("delete %s: %w", record.ID, err)
+			}
+			slog.Info(
+				"publish message",
+				slog.String("topic", record.Topic),
+				slog.String("tenant", record.Tenant),
+				slog.String("message", string(record.Payload)),
+			)
+		}
+		if len(records) < outboxBatchSize {
+			return nil
+		}
+	}
+}
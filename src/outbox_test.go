@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/memory"
+)
+
+func TestRelay(t *testing.T) {
+	db := memory.NewContainer()
+	bus := &testBus{Bus: memory.NewBus(), db: db}
+	r := newOutboxRelay(db, bus, time.Second, time.Second)
+
+	// The records of every tenant are relayed, more than a batch in total.
+	var want []string
+	for i := 0; i < outboxBatchSize+1; i++ {
+		tenant := []string{internal.DefaultTenant, "acme", "globex"}[i%3]
+		ctx := internal.WithTenant(context.Background(), tenant)
+		id := fmt.Sprintf("%s/e%d", tenant, i)
+		err := enqueue(ctx, db, internal.EventDeletedTopic, internal.EventDeleted{ID: id})
+		if err != nil {
+			t.Fatalf("enqueue() error = %v", err)
+		}
+		want = append(want, id)
+	}
+	if err := r.relay(context.Background()); err != nil {
+		t.Fatalf("relay() error = %v", err)
+	}
+
+	// Every message is published with its own tenant, and removed from the
+	// outbox only after it was published.
+	var got []string
+	for _, m := range bus.published {
+		var msg struct {
+			ID       string `json:"id"`
+			TenantID string `json:"tenant_id"`
+		}
+		if err := json.Unmarshal(m.payload, &msg); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if tenant, _, _ := strings.Cut(msg.ID, "/"); msg.TenantID != tenant {
+			t.Errorf("message %s tenant_id = %q, want %q", msg.ID, msg.TenantID, tenant)
+		}
+		if !m.pending {
+			t.Errorf("message %s was removed from the outbox before it was published", msg.ID)
+		}
+		got = append(got, msg.ID)
+	}
+	if !slices.Equal(sorted(got), sorted(want)) {
+		t.Errorf("relay() published %d messages, want %d", len(got), len(want))
+	}
+	if n := pendingOutbox(t, db); n != 0 {
+		t.Errorf("PendingOutbox() = %d records, want none", n)
+	}
+}
+
+func TestRelayRun(t *testing.T) {
+	db := memory.NewContainer()
+	bus := &testBus{Bus: memory.NewBus(), db: db}
+
+	// The relay polls the outbox only once an hour, so it publishes only
+	// when it is notified.
+	r := newOutboxRelay(db, bus, time.Hour, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.run(ctx) }()
+	tenantCtx := internal.WithTenant(ctx, internal.DefaultTenant)
+	write := func(id string) {
+		t.Helper()
+		err := enqueue(tenantCtx, db, internal.EventDeletedTopic, internal.EventDeleted{ID: id})
+		if err != nil {
+			t.Fatalf("enqueue() error = %v", err)
+		}
+		r.notify()
+	}
+
+	// Notifying wakes the relay up.
+	write("e1")
+	bus.waitAttempts(t, 1)
+
+	// A failed publish keeps the record in the outbox, and the relay backs
+	// off instead of being woken up again.
+	bus.setErr(errors.New("bus is down"))
+	write("e2")
+	bus.waitAttempts(t, 2)
+	r.notify()
+	time.Sleep(50 * time.Millisecond)
+	if n := bus.attempts(); n != 2 {
+		t.Errorf("Publish() called %d times while backing off, want 2", n)
+	}
+	if n := pendingOutbox(t, db); n != 1 {
+		t.Errorf("PendingOutbox() = %d records, want 1", n)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("run() error = %v", err)
+	}
+}
+
+// testBus is a memory bus that records the messages published to it, and
+// whether they were still in the outbox at the time. Publishing fails with
+// the configured error, if any.
+type testBus struct {
+	*memory.Bus
+	db internal.EventsContainer
+
+	mu        sync.Mutex
+	err       error
+	published []publishedMessage
+	calls     int
+}
+
+// publishedMessage is a message published to a [testBus].
+type publishedMessage struct {
+	payload []byte
+	pending bool
+}
+
+// Publish implements the [service.MessageBus] interface.
+func (b *testBus) Publish(ctx context.Context, topic string, msg []byte) error {
+	records, err := b.db.PendingOutbox(ctx, 10*outboxBatchSize)
+	if err != nil {
+		return err
+	}
+	pending := slices.ContainsFunc(records, func(r internal.OutboxRecord) bool {
+		return string(r.Payload) == string(msg)
+	})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls++
+	if b.err != nil {
+		return b.err
+	}
+	b.published = append(b.published, publishedMessage{payload: msg, pending: pending})
+	return b.Bus.Publish(ctx, topic, msg)
+}
+
+// setErr makes the following calls of Publish fail with the given error.
+func (b *testBus) setErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+// attempts returns the number of calls of Publish.
+func (b *testBus) attempts() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls
+}
+
+// waitAttempts waits until Publish was called n times.
+func (b *testBus) waitAttempts(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.attempts() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Publish() called %d times, want %d", b.attempts(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// pendingOutbox returns the number of the records in the outbox.
+func pendingOutbox(t *testing.T, db internal.EventsContainer) int {
+	t.Helper()
+	records, err := db.PendingOutbox(context.Background(), 10*outboxBatchSize)
+	if err != nil {
+		t.Fatalf("PendingOutbox() error = %v", err)
+	}
+	return len(records)
+}
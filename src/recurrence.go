@@ -0,0 +1,709 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/pubsub"
+	"github.com/eventscompass/service-framework/service"
+)
+
+const (
+	// announceInterval is the interval at which the announcer
+	// looks for occurrences that are due to be announced.
+	announceInterval = time.Hour
+
+	// maxExpandWindow is the maximum length of the time within
+	// which recurring events are expanded into occurrences.
+	maxExpandWindow = 366 * 24 * time.Hour
+
+	// maxExpandedEvents is the maximum number of events that are
+	// retrieved while expanding the recurring events.
+	maxExpandedEvents = 10 * maxPageSize
+
+	// maxRecurrenceYears is the number of years after which the
+	// upcoming occurrences of recurring events are no longer
+	// looked for.
+	maxRecurrenceYears = 100
+)
+
+// announcer announces the occurrences of the recurring events. An occurrence is
+// announced by publishing an [pubsub.EventCreated] message with the id of the
+// occurrence, once it starts within the horizon.
+type announcer struct {
+	db      internal.EventsContainer
+	relay   *outboxRelay
+	horizon time.Duration
+}
+
+// run runs the announcer until the context is cancelled.
+func (a *announcer) run(ctx context.Context) error {
+	slog.Info("starting occurrence announcer")
+	ticker := time.NewTicker(announceInterval)
+	defer ticker.Stop()
+	for {
+		if err := a.announceAll(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("failed to announce occurrences", slog.String("error", err.Error()))
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			slog.Info("stopping occurrence announcer")
+			return nil
+		}
+	}
+}
+
+// announceAll announces the due occurrences of all the recurring events of
+// every tenant.
+func (a *announcer) announceAll(ctx context.Context) error {
+	tenants, err := a.db.Tenants(ctx, internal.EventsCollection)
+	if err != nil {
+		return err //nolint:wrapcheck // the container returns service errors
+	}
+	for _, tenant := range tenants {
+		if err := a.announceTenant(internal.WithTenant(ctx, tenant)); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+	}
+	a.relay.notify()
+	return nil
+}
+
+// announceTenant announces the due occurrences of the recurring events of the
+// tenant of the context.
+func (a *announcer) announceTenant(ctx context.Context) error {
+	until := time.Now().Add(a.horizon)
+	var ids []string
+	filter := internal.Filter{Recurring: true}
+	err := a.db.ForEachEvent(ctx, &filter, func(e *internal.Event) error {
+		if e.Recurrence.Announced.Before(until) {
+			ids = append(ids, e.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err //nolint:wrapcheck // the container returns service errors
+	}
+
+	for _, id := range ids {
+		err := a.db.WithTransaction(ctx, func(ctx context.Context) error {
+			series, err := getSeries(ctx, a.db, id)
+			if errors.Is(err, service.ErrNotFound) {
+				return nil // the event was deleted in the meantime
+			}
+			if err != nil {
+				return err
+			}
+			announced, err := a.announce(ctx, &series, until)
+			if err != nil || !announced {
+				return err
+			}
+			return a.db.Replace(ctx, internal.EventsCollection, id, series)
+		})
+		if err != nil {
+			return fmt.Errorf("announce %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// announce writes an [pubsub.EventCreated] message to the outbox for every
+// occurrence of the series, which originally starts before the given time and
+// was not announced yet. It advances [internal.Recurrence.Announced] of the
+// series, which must then be stored. The function returns false if nothing was
+// announced. The context should belong to the transaction that stores the
+// series.
+func (a *announcer) announce(
+	ctx context.Context,
+	series *internal.Event,
+	until time.Time,
+) (bool, error) {
+	// Occurrences that have already ended are not announced.
+	from, now := series.Recurrence.Announced, time.Now()
+	if from.Before(now) {
+		from = now
+	}
+	occurrences, err := series.Occurrences(from, until, maxExpandedEvents)
+	if err != nil {
+		return false, internal.Unexpected(ctx, err)
+	}
+	var n int
+	for _, o := range occurrences {
+		if o.RecurrenceID.Before(series.Recurrence.Announced) || !o.RecurrenceID.Before(until) {
+			continue
+		}
+		msg := createdMessage(&o)
+		if err := enqueue(ctx, a.db, pubsub.EventCreatedTopic, msg); err != nil {
+			return false, err
+		}
+		n++
+		series.Recurrence.Announced = o.RecurrenceID.Add(time.Nanosecond)
+	}
+	if len(occurrences) < maxExpandedEvents && n > 0 {
+		series.Recurrence.Announced = until
+	}
+	return n > 0, nil
+}
+
+// announceDeleted writes an [internal.EventDeleted] message to the outbox for
+// every announced occurrence of the series, which originally starts at or
+// after the given time and has not ended yet. The context should belong to the
+// transaction that changes the series.
+func (a *announcer) announceDeleted(
+	ctx context.Context,
+	series *internal.Event,
+	from time.Time,
+) error {
+	announced := series.Recurrence.Announced
+	occurrences, err := series.Occurrences(time.Now(), announced, maxExpandedEvents)
+	if err != nil {
+		return internal.Unexpected(ctx, err)
+	}
+	for _, o := range occurrences {
+		if o.RecurrenceID.Before(from) || !o.RecurrenceID.Before(announced) {
+			continue
+		}
+		msg := internal.EventDeleted{ID: o.ID}
+		if err := enqueue(ctx, a.db, internal.EventDeletedTopic, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findEvent retrieves the event with the given id. The id can also be the id of
+// an occurrence of a recurring event. This function returns
+// [service.ErrNotFound] if there is no such event.
+func findEvent(
+	ctx context.Context,
+	db internal.EventsContainer,
+	id string,
+) (internal.Event, error) {
+	seriesID, recurrenceID, ok := internal.ParseOccurrenceID(id)
+	if !ok {
+		elem, err := db.GetByID(ctx, internal.EventsCollection, id)
+		if err != nil {
+			return internal.Event{}, err //nolint:wrapcheck // the container returns service errors
+		}
+		event, ok := elem.(internal.Event)
+		if !ok {
+			err := fmt.Errorf("unexpected type %T", elem)
+			return internal.Event{}, internal.Unexpected(ctx, err)
+		}
+		return event, nil
+	}
+
+	series, err := getSeries(ctx, db, seriesID)
+	if err != nil {
+		return internal.Event{}, err
+	}
+	occurrence, ok, err := series.Occurrence(recurrenceID)
+	if err != nil {
+		return internal.Event{}, internal.Unexpected(ctx, err)
+	}
+	if !ok {
+		return internal.Event{}, fmt.Errorf("%w: no occurrence %q", service.ErrNotFound, id)
+	}
+	return occurrence, nil
+}
+
+// getSeries retrieves the recurring event with the given id. This function
+// returns [service.ErrNotFound] if there is no such event, or if the event does
+// not recur.
+func getSeries(
+	ctx context.Context,
+	db internal.EventsContainer,
+	id string,
+) (internal.Event, error) {
+	elem, err := db.GetByID(ctx, internal.EventsCollection, id)
+	if err != nil {
+		return internal.Event{}, err //nolint:wrapcheck // the container returns service errors
+	}
+	series, ok := elem.(internal.Event)
+	if !ok {
+		return internal.Event{}, internal.Unexpected(ctx, fmt.Errorf("unexpected type %T", elem))
+	}
+	if series.Recurrence == nil {
+		return internal.Event{}, fmt.Errorf("%w: event %q does not recur", service.ErrNotFound, id)
+	}
+	return series, nil
+}
+
+// expandEvents retrieves a page of events, in which the recurring events are
+// replaced by their occurrences overlapping with the time between from and to.
+// The occurrences and the other events are filtered, sorted and paged as
+// described by the query. The events that do not recur are paged by the
+// container, and merged with the occurrences. Only the events of the page are
+// kept in memory. This function returns [service.ErrBadRequest] if the time is
+// not bounded or longer than [maxExpandWindow].
+func (h *restHandler) expandEvents(
+	ctx context.Context,
+	q *internal.Query,
+	from time.Time,
+	to time.Time,
+) (*internal.Page, error) {
+	if from.IsZero() || to.IsZero() || to.Sub(from) > maxExpandWindow {
+		return nil, fmt.Errorf("%w: events can be expanded within at most %v",
+			service.ErrBadRequest, maxExpandWindow)
+	}
+	x, err := newExpansion(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve the page of the events that do not recur from the container.
+	// One more event than the page needs tells whether there is a next page.
+	// The events match the query and come after its cursor, so they are kept
+	// as they are, even if some of their fields were left out.
+	single := *q
+	single.Filter.NonRecurring, single.Limit = true, q.Limit+1
+	page, err := h.eventsDB.QueryEvents(ctx, &single)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the container returns service errors
+	}
+	x.events = append(x.events, page.Events...)
+
+	// Collect the occurrences of the recurring events. They are filtered after
+	// they are expanded, since their dates and halls can differ from those of
+	// their series. A series has occurrences within the time only if it starts
+	// before the time ends.
+	filter := internal.Filter{
+		StartsBefore: to,
+		Country:      q.Filter.Country,
+		LocationID:   q.Filter.LocationID,
+		Recurring:    true,
+	}
+	err = h.eventsDB.ForEachEvent(ctx, &filter, func(e *internal.Event) error {
+		occurrences, err := e.Occurrences(from, to, maxExpandedEvents)
+		if err != nil {
+			return internal.Unexpected(ctx, err)
+		}
+		x.addAll(occurrences)
+		return nil
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the container returns service errors
+	}
+	return x.page(), nil
+}
+
+// expansion collects the events of a page while the recurring events are
+// expanded. See [restHandler.expandEvents]. Only the events that come first in
+// the order of the query are kept, so that the number of the expanded events
+// does not matter.
+type expansion struct {
+	q      *internal.Query
+	cursor *internal.Cursor
+	events []internal.Event
+}
+
+// newExpansion returns an expansion for the given query, which starts after the
+// cursor of the page token of the query.
+func newExpansion(q *internal.Query) (*expansion, error) {
+	x := expansion{q: q}
+	if q.PageToken != "" {
+		c, err := internal.ParsePageToken(q.Sort, q.PageToken)
+		if err != nil {
+			return nil, fmt.Errorf("parse page token: %w", err)
+		}
+		x.cursor = c
+	}
+	return &x, nil
+}
+
+// addAll adds the events that match the query. See [expansion.add].
+func (x *expansion) addAll(events []internal.Event) {
+	for i := range events {
+		x.add(&events[i])
+	}
+}
+
+// add adds the event if it matches the query. Once twice as many events as the
+// page needs were added, the events that do not make it to the page are
+// dropped.
+func (x *expansion) add(e *internal.Event) {
+	if !x.q.Filter.Match(e) || (x.cursor != nil && x.q.Sort.Compare(e, x.cursor) <= 0) {
+		return
+	}
+	x.events = append(x.events, *e)
+	if len(x.events) >= 2*(x.q.Limit+1) {
+		x.truncate()
+	}
+}
+
+// truncate sorts the added events and keeps those of the page, and the first
+// event of the next page, if any.
+func (x *expansion) truncate() {
+	sort := x.q.Sort
+	slices.SortFunc(x.events, func(a, b internal.Event) int {
+		return sort.Compare(&a, &internal.Cursor{Value: sort.Value(&b), ID: b.ID})
+	})
+	if len(x.events) > x.q.Limit+1 {
+		x.events = x.events[:x.q.Limit+1]
+	}
+}
+
+// page sorts the added events and returns the first page of them.
+func (x *expansion) page() *internal.Page {
+	x.truncate()
+	page := internal.Page{Events: x.events}
+	if limit := x.q.Limit; len(x.events) > limit {
+		page.Events = x.events[:limit]
+		page.NextPageToken = internal.NewPageToken(x.q.Sort, &page.Events[limit-1])
+	}
+	return &page
+}
+
+// parseRange parses the "range" query parameter of the request, which selects
+// the occurrences of a recurring event that are changed. It returns true if
+// the following occurrences are changed as well, i.e. if the range is
+// "this_and_following". This function returns [service.ErrBadRequest] if the
+// range is neither "this" nor "this_and_following".
+func parseRange(r *http.Request) (bool, error) {
+	switch v := r.URL.Query().Get("range"); v {
+	case "", "this":
+		return false, nil
+	case "this_and_following":
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: unknown range %q", service.ErrBadRequest, v)
+	}
+}
+
+func (h *restHandler) replaceOccurrence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and body.
+	id := chi.URLParam(r, "id")
+	var event internal.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		httpError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
+		return
+	}
+	following, allowOverlap, err := h.parseChangeOptions(r)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Replace the occurrence.
+	internal.Logger(ctx).Info("request to replace occurrence", slog.Any("event", event))
+	occurrence, err := h.changeOccurrence(ctx, id, following, allowOverlap,
+		func(o *internal.Event) error { return replaceFields(o, &event) })
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("occurrence successfully replaced")
+
+	// Write the response.
+	writeJSON(ctx, w, &occurrence)
+}
+
+func (h *restHandler) updateOccurrence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and body.
+	id := chi.URLParam(r, "id")
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
+		return
+	}
+	following, allowOverlap, err := h.parseChangeOptions(r)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Update the occurrence.
+	internal.Logger(ctx).Info("request to update occurrence", slog.String("id", id))
+	occurrence, err := h.changeOccurrence(ctx, id, following, allowOverlap,
+		func(o *internal.Event) error { return patchFields(ctx, o, patch) })
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("occurrence successfully updated")
+
+	// Write the response.
+	writeJSON(ctx, w, &occurrence)
+}
+
+// patchFields applies the JSON merge patch to the occurrence o. Only the name,
+// the dates and the hall of the occurrence can be patched. This function
+// returns [errOccurrenceChange] if the patch changes any other field.
+func patchFields(ctx context.Context, o *internal.Event, patch []byte) error {
+	patched, err := internal.ApplyMergePatch(*o, patch)
+	if err != nil {
+		return err //nolint:wrapcheck // ApplyMergePatch returns service errors
+	}
+	raw, err := json.Marshal(patched)
+	if err != nil {
+		return internal.Unexpected(ctx, err)
+	}
+	var event internal.Event
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return fmt.Errorf("%w: %v", service.ErrBadRequest, err)
+	}
+	if event.ID != o.ID || event.Location.ID != o.Location.ID ||
+		event.Recurrence != nil || event.SeriesID != o.SeriesID {
+		return errOccurrenceChange
+	}
+	o.Name, o.Hall = event.Name, event.Hall
+	o.StartDate, o.EndDate, o.Duration = event.StartDate, event.EndDate, event.Duration
+	return nil
+}
+
+// replaceFields replaces the occurrence o with the given event. Only the
+// name, the dates and the hall of the occurrence are replaced. This function
+// returns [errOccurrenceChange] if the event has a different id or location.
+func replaceFields(o, event *internal.Event) error {
+	if (event.ID != "" && event.ID != o.ID) ||
+		(event.Location.ID != "" && event.Location.ID != o.Location.ID) {
+		return errOccurrenceChange
+	}
+	o.Name, o.Hall = event.Name, event.Hall
+	o.StartDate, o.EndDate, o.Duration = event.StartDate, event.EndDate, event.Duration
+	return nil
+}
+
+// errOccurrenceChange is returned when a client attempts to change a field of
+// an occurrence, which is shared by all the occurrences of its series.
+var errOccurrenceChange = fmt.Errorf(
+	"%w: only the name, the dates and the hall of an occurrence can be changed",
+	service.ErrBadRequest)
+
+// parseChangeOptions parses the query parameters of a request changing an
+// occurrence. It returns true if the change applies to the following
+// occurrences as well, see [parseRange], and if overlaps are allowed, see
+// [restHandler.allowOverlap].
+func (h *restHandler) parseChangeOptions(r *http.Request) (bool, bool, error) {
+	following, err := parseRange(r)
+	if err != nil {
+		return false, false, err
+	}
+	allowOverlap, err := h.allowOverlap(r)
+	if err != nil {
+		return false, false, err
+	}
+	return following, allowOverlap, nil
+}
+
+// changeOccurrence changes the occurrence with the given id by calling fn, and
+// records the change as an exception of its series. If following is set, then
+// the change applies to the following occurrences as well. The client must be
+// allowed to update the series. The function returns the changed occurrence.
+func (h *restHandler) changeOccurrence(
+	ctx context.Context,
+	id string,
+	following bool,
+	allowOverlap bool,
+	fn func(*internal.Event) error,
+) (internal.Event, error) {
+	_, recurrenceID, _ := internal.ParseOccurrenceID(id)
+	var occurrence internal.Event
+	err := h.transact(ctx, func(ctx context.Context) error {
+		series, o, err := h.getOccurrence(ctx, id)
+		if err != nil {
+			return err
+		}
+		err = h.authorize(ctx, auth.ActionUpdate, auth.ResourceEvent, series.Organizer)
+		if err != nil {
+			return err
+		}
+		if err := fn(&o); err != nil {
+			return err
+		}
+		if err := checkDuration(&o); err != nil {
+			return err
+		}
+
+		// Record the change, replacing the exceptions that it overrides.
+		series.Recurrence.SetException(internal.Exception{
+			RecurrenceID:  recurrenceID,
+			ThisAndFuture: following,
+			Name:          o.Name,
+			StartDate:     o.StartDate,
+			EndDate:       o.EndDate,
+			Hall:          o.Hall,
+		})
+		if err := internal.ValidateEvent(&series); err != nil {
+			return err //nolint:wrapcheck // validation errors are returned as is
+		}
+
+		// Store the series and announce the change.
+		occurrence, _, err = series.Occurrence(recurrenceID)
+		if err != nil {
+			return internal.Unexpected(ctx, err)
+		}
+		err = h.storeEvent(ctx, &series, allowOverlap, func(ctx context.Context) error {
+			if h.locationRefs {
+				series.Location = internal.Location{ID: series.Location.ID}
+			}
+			return h.eventsDB.Replace(ctx, internal.EventsCollection, series.ID, series)
+		})
+		if err != nil {
+			return err
+		}
+		return enqueue(ctx, h.eventsDB, internal.EventUpdatedTopic, updatedMessage(&occurrence))
+	})
+	return occurrence, err
+}
+
+// checkDuration makes sure that the duration of the changed occurrence, if
+// given, agrees with its dates. The other fields of the occurrence are
+// validated as part of its series. This function returns a
+// [internal.ValidationError] if the duration does not agree.
+func checkDuration(o *internal.Event) error {
+	if d := o.EndDate.Sub(o.StartDate); o.Duration != 0 && o.Duration != d {
+		return &internal.ValidationError{Fields: []internal.FieldError{{
+			Field:   "duration",
+			Message: fmt.Sprintf("must be equal to end_date - start_date (%v)", d),
+		}}}
+	}
+	return nil
+}
+
+// getOccurrence retrieves the occurrence with the given id together with its
+// series. The locations of both are filled in. This function returns
+// [service.ErrNotFound] if there is no such occurrence.
+func (h *restHandler) getOccurrence(
+	ctx context.Context,
+	id string,
+) (internal.Event, internal.Event, error) {
+	seriesID, recurrenceID, _ := internal.ParseOccurrenceID(id)
+	series, err := getSeries(ctx, h.eventsDB, seriesID)
+	if err != nil {
+		return internal.Event{}, internal.Event{}, err
+	}
+	if err := h.fillLocations(ctx, &series); err != nil {
+		return internal.Event{}, internal.Event{}, err
+	}
+	o, ok, err := series.Occurrence(recurrenceID)
+	if err != nil {
+		return internal.Event{}, internal.Event{}, internal.Unexpected(ctx, err)
+	}
+	if !ok {
+		return internal.Event{}, internal.Event{}, fmt.Errorf(
+			"%w: no occurrence %q", service.ErrNotFound, id)
+	}
+	return series, o, nil
+}
+
+func (h *restHandler) deleteOccurrence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key.
+	id := chi.URLParam(r, "id")
+	following, err := parseRange(r)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Delete the occurrence.
+	internal.Logger(ctx).Info("request to delete occurrence", slog.String("id", id))
+	err = h.transact(ctx, func(ctx context.Context) error {
+		return h.removeOccurrence(ctx, id, following)
+	})
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("occurrence successfully deleted")
+
+	// Write the response.
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeOccurrence removes the occurrence with the given id from its series,
+// together with the following occurrences if requested. Removing the first
+// occurrence together with the following ones deletes the whole series. The
+// client must be allowed to delete the series. The deletion of the occurrences
+// that were announced is announced as well. The context should belong to the
+// transaction that changes the series.
+func (h *restHandler) removeOccurrence(ctx context.Context, id string, following bool) error {
+	series, recurrenceID, err := h.authorizeOccurrence(ctx, auth.ActionDelete, id)
+	if err != nil {
+		return err
+	}
+	seriesID := series.ID
+
+	// Announce the deletion before the series is changed.
+	if err := h.announceRemoved(ctx, &series, recurrenceID, following); err != nil {
+		return err
+	}
+
+	switch {
+	case following && recurrenceID.Equal(series.StartDate):
+		if err := h.eventsDB.Delete(ctx, internal.EventsCollection, seriesID); err != nil {
+			return err //nolint:wrapcheck // the container returns service errors
+		}
+		msg := internal.EventDeleted{ID: seriesID}
+		return enqueue(ctx, h.eventsDB, internal.EventDeletedTopic, msg)
+	case following:
+		if err := series.Recurrence.Truncate(recurrenceID); err != nil {
+			return internal.Unexpected(ctx, err)
+		}
+	default:
+		series.Recurrence.Exclude(recurrenceID)
+	}
+	return h.eventsDB.Replace(ctx, internal.EventsCollection, seriesID, series) //nolint:wrapcheck // the container returns service errors
+}
+
+// authorizeOccurrence makes sure that the client of the request may perform the
+// action on the series of the occurrence with the given id. The function returns
+// the series and the original start time of the occurrence. This function
+// returns [service.ErrNotFound] if there is no such occurrence, and
+// [service.ErrNotAllowed] if the client may not perform the action.
+func (h *restHandler) authorizeOccurrence(
+	ctx context.Context,
+	action, id string,
+) (internal.Event, time.Time, error) {
+	seriesID, recurrenceID, _ := internal.ParseOccurrenceID(id)
+	series, err := getSeries(ctx, h.eventsDB, seriesID)
+	if err != nil {
+		return internal.Event{}, time.Time{}, err
+	}
+	if _, ok, err := series.Occurrence(recurrenceID); err != nil || !ok {
+		return internal.Event{}, time.Time{}, fmt.Errorf(
+			"%w: no occurrence %q", service.ErrNotFound, id)
+	}
+	if err := h.authorize(ctx, action, auth.ResourceEvent, series.Organizer); err != nil {
+		return internal.Event{}, time.Time{}, err
+	}
+	return series, recurrenceID, nil
+}
+
+// announceRemoved writes an [internal.EventDeleted] message to the outbox for
+// the occurrence of the series, which originally starts at the given time, and
+// for the following occurrences if requested, as far as they were announced.
+// The context should belong to the transaction that changes the series.
+func (h *restHandler) announceRemoved(
+	ctx context.Context,
+	series *internal.Event,
+	recurrenceID time.Time,
+	following bool,
+) error {
+	if following {
+		return h.announcer.announceDeleted(ctx, series, recurrenceID)
+	}
+	if !recurrenceID.Before(series.Recurrence.Announced) {
+		return nil
+	}
+	msg := internal.EventDeleted{ID: internal.OccurrenceID(series.ID, recurrenceID)}
+	return enqueue(ctx, h.eventsDB, internal.EventDeletedTopic, msg)
+}
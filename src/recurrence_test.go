@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+)
+
+func TestExpandEvents(t *testing.T) {
+	h, ctx := newTestHandler(t, false)
+	series := testEvent("s1", "A", 12)
+	series.Recurrence = &internal.Recurrence{RRule: "FREQ=DAILY;COUNT=3"}
+	second := testEvent("e2", "B", 10)
+	second.StartDate = second.StartDate.AddDate(0, 0, 1)
+	second.EndDate = second.EndDate.AddDate(0, 0, 1)
+	for _, e := range []internal.Event{testEvent("e1", "B", 10), second, series} {
+		if err := h.createEvent(ctx, &e, false); err != nil {
+			t.Fatalf("createEvent() error = %v", err)
+		}
+	}
+
+	// The pages merge the events that do not recur with the occurrences.
+	from := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 5)
+	q := internal.Query{
+		Filter: internal.Filter{StartsAfter: from, EndsBefore: to},
+		Sort:   internal.SortByStartDate,
+		Limit:  2,
+	}
+	want := [][]string{
+		{"e1", "s1_20300101T120000Z"},
+		{"e2", "s1_20300102T120000Z"},
+		{"s1_20300103T120000Z"},
+	}
+	for i, w := range want {
+		page, err := h.expandEvents(ctx, &q, from, to)
+		if err != nil {
+			t.Fatalf("expandEvents() error = %v", err)
+		}
+		var got []string
+		for _, e := range page.Events {
+			got = append(got, e.ID)
+		}
+		if !slices.Equal(got, w) {
+			t.Errorf("page %d = %v, want %v", i, got, w)
+		}
+		if last := i == len(want)-1; last != (page.NextPageToken == "") {
+			t.Errorf("page %d has next page token %q", i, page.NextPageToken)
+		}
+		q.PageToken = page.NextPageToken
+	}
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 
 	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
 	"github.com/eventscompass/service-framework/pubsub"
 	"github.com/eventscompass/service-framework/service"
 )
@@ -18,36 +25,97 @@ func (s *EventsService) REST() http.Handler {
 	return s.restHandler
 }
 
+// exportPath is the path of the endpoint exporting the events, which streams
+// its responses.
+const exportPath = "/api/events:export"
+
+// Streams implements the [service.StreamingService] interface. The exports of
+// the events are streamed, since they can be arbitrarily large.
+func (s *EventsService) Streams(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.URL.Path == exportPath
+}
+
 // initREST initializes the handler for the rest server part of the service.
 // This function creates a router and registers with that router the handlers
 // for the http endpoints.
 func (s *EventsService) initREST() {
-	restHandler := &restHandler{
-		eventsDB:  s.eventsDB,
-		eventsBus: s.eventsBus,
-	}
+	restHandler := s.newHandler()
 	mux := chi.NewMux()
+	mux.Use(logRequests(s.cfg.REST.DumpRequests))
+	mux.Use(s.metrics.instrument)
 
-	// API routes.
-	mux.Get("/api/events/id/{id}", restHandler.readByID)
-	mux.Get("/api/events/name/{name}", restHandler.readByName)
-	mux.Get("/api/events", restHandler.readAll)
-	mux.Post("/api/events", restHandler.create)
+	// API routes. Only the requests to the api are traced, since
+	// the health checks and the metrics are polled frequently.
+	// The clients of the api are authenticated, and the requests
+	// are scoped to their tenant.
+	api := mux.With(traceRequests(s.tracer), s.auth.middleware, s.tenants.middleware)
+	api.Get("/api/events/id/{id}", restHandler.readByID)
+	api.Get("/api/events/id/{id}/calendar.ics", restHandler.readEventCalendar)
+	api.Get("/api/events/name/{name}", restHandler.readByName)
+	api.Get("/api/events", restHandler.readAll)
+	api.Get("/api/events.ics", restHandler.readAllCalendar)
+	api.Get(exportPath, restHandler.exportEvents)
+	api.Post("/api/events:import", restHandler.importEvents)
+	api.Get("/api/events/search", restHandler.search)
+	api.Post("/api/events", restHandler.create)
+	api.Put("/api/events/id/{id}", restHandler.replace)
+	api.Patch("/api/events/id/{id}", restHandler.update)
+	api.Delete("/api/events/id/{id}", restHandler.delete)
 
-	// Health check.
-	mux.Handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, "I am healthy and strong, buddy!")
-	}))
+	api.Get("/api/locations/id/{id}", restHandler.readLocationByID)
+	api.Get("/api/locations/name/{name}", restHandler.readLocationByName)
+	api.Get("/api/locations", restHandler.readAllLocations)
+	api.Post("/api/locations", restHandler.createLocation)
+	api.Put("/api/locations/id/{id}", restHandler.replaceLocation)
+	api.Patch("/api/locations/id/{id}", restHandler.updateLocation)
+	api.Delete("/api/locations/id/{id}", restHandler.deleteLocation)
+	api.Get("/api/locations/{id}/halls/{hall}/schedule", restHandler.readHallSchedule)
+	api.Get("/api/locations/{id}/events.ics", restHandler.readLocationCalendar)
+	api.Get("/api/locations/{id}/halls/{hall}/events.ics", restHandler.readHallCalendar)
+
+	// Health checks.
+	mux.Get("/livez", s.livez)
+	mux.Get("/readyz", s.readyz)
+
+	// Metrics.
+	mux.Method(http.MethodGet, "/metrics", s.metrics.registry)
 
 	s.restHandler = mux
 }
 
+// newHandler creates the handler that implements the business logic of the
+// service. The grpc server shares the handler with the rest server.
+func (s *EventsService) newHandler() *restHandler {
+	return &restHandler{
+		eventsDB:     s.eventsDB,
+		relay:        s.relay,
+		locationRefs: s.cfg.LocationRefs,
+		policy:       s.auth.policy,
+		announcer:    s.announcer,
+	}
+}
+
 // restHandler handles http requests. It is the bridge between the rest api and
 // the business logic. Every rest endpoint exposed by the server will be served
 // by calling one of the handler methods.
 type restHandler struct {
-	eventsDB  internal.EventsContainer
-	eventsBus service.MessageBus
+	eventsDB internal.EventsContainer
+
+	// relay publishes the messages written to the outbox. It is
+	// notified whenever a message is written.
+	relay *outboxRelay
+
+	// locationRefs is set if only the location ids of the events
+	// are stored. See [Config.LocationRefs].
+	locationRefs bool
+
+	// announcer announces the occurrences of the recurring
+	// events.
+	announcer *announcer
+
+	// policy decides which client may change which resources.
+	// See [AuthConfig.PolicyFile].
+	policy *auth.Policy
 }
 
 func (h *restHandler) create(w http.ResponseWriter, r *http.Request) {
@@ -56,38 +124,25 @@ func (h *restHandler) create(w http.ResponseWriter, r *http.Request) {
 	// Decode the request body.
 	var event internal.Event
 	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
-		service.HTTPError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
+		httpError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
 		return
 	}
-
-	// Create the event.
-	slog.Info("request to create event", slog.Any("event", event))
-	err := h.eventsDB.Create(ctx, internal.EventsCollection, event)
+	if event.ID == "" {
+		event.ID = internal.NewID()
+	}
+	allowOverlap, err := h.allowOverlap(r)
 	if err != nil {
-		service.HTTPError(ctx, w, err)
+		httpError(ctx, w, err)
 		return
 	}
-	slog.Info("event successfully created")
 
-	// Publish to the message queue.
-	payload := pubsub.EventCreated{
-		ID:         event.ID,
-		Name:       event.Name,
-		LocationID: event.Location.ID,
-		Start:      event.StartDate,
-		End:        event.EndDate,
-	}
-	topic := pubsub.EventCreatedTopic
-	body, err := json.Marshal(&payload)
-	if err != nil {
-		slog.Error("failed to marshal for publishing", err)
-	}
-	if err == nil {
-		if pErr := h.eventsBus.Publish(ctx, topic, body); pErr != nil {
-			slog.Error("failed to publish", slog.String("topic", topic), pErr)
-		}
-		slog.Info("publish message", slog.String("topic", topic), slog.Any("message", payload))
+	// Create the event.
+	internal.Logger(ctx).Info("request to create event", slog.Any("event", event))
+	if err := h.createEvent(ctx, &event, allowOverlap); err != nil {
+		httpError(ctx, w, err)
+		return
 	}
+	internal.Logger(ctx).Info("event successfully created")
 
 	// Write the response.
 	w.Header().Set("Location", fmt.Sprintf("%s/id/%s", r.URL.Path, event.ID))
@@ -101,18 +156,24 @@ func (h *restHandler) readByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	// Get the event.
-	slog.Info("request to read event", slog.String("id", id))
-	event, err := h.eventsDB.GetByID(ctx, internal.EventsCollection, id)
+	internal.Logger(ctx).Info("request to read event", slog.String("id", id))
+	event, err := findEvent(ctx, h.eventsDB, id)
 	if err != nil {
-		service.HTTPError(ctx, w, err)
+		httpError(ctx, w, err)
+		return
+	}
+	if err := h.fillLocations(ctx, &event); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	views, err := h.withAttendance(ctx, event)
+	if err != nil {
+		httpError(ctx, w, err)
 		return
 	}
 
 	// Write the response.
-	w.Header().Set("Content-Type", "application/json; charset=utf8")
-	if err := json.NewEncoder(w).Encode(&event); err != nil {
-		slog.Info("failed to write response", err)
-	}
+	writeJSON(ctx, w, &views[0])
 }
 
 func (h *restHandler) readByName(w http.ResponseWriter, r *http.Request) {
@@ -122,34 +183,522 @@ func (h *restHandler) readByName(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
 
 	// Get the event.
-	slog.Info("request to read event", slog.String("name", name))
-	event, err := h.eventsDB.GetByName(ctx, internal.EventsCollection, name)
+	internal.Logger(ctx).Info("request to read event", slog.String("name", name))
+	elem, err := h.eventsDB.GetByName(ctx, internal.EventsCollection, name)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	event, err := h.toEvent(ctx, elem)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	views, err := h.withAttendance(ctx, event)
 	if err != nil {
-		service.HTTPError(ctx, w, err)
+		httpError(ctx, w, err)
 		return
 	}
 
 	// Write the response.
-	w.Header().Set("Content-Type", "application/json; charset=utf8")
-	if err := json.NewEncoder(w).Encode(&event); err != nil {
-		slog.Info("failed to write response", err)
-	}
+	writeJSON(ctx, w, &views[0])
 }
 
 func (h *restHandler) readAll(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Get all events.
-	slog.Info("request to read all events")
-	events, err := h.eventsDB.GetAll(ctx, internal.EventsCollection)
+	// Decode the request query.
+	q, err := parseQuery(r)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	expand, err := parseBool(r.URL.Query(), "expand")
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// If only location references are stored, then the events cannot be
+	// filtered by the data of their location.
+	if h.locationRefs && q.Filter.NeedsLocationData() {
+		httpError(ctx, w, fmt.Errorf(
+			"%w: filtering by country or capacity is not supported", service.ErrBadRequest))
+		return
+	}
+
+	// Retrieve the fields needed for computing the requested fields.
+	requested := q.Fields
+	q.Fields = storedFields(requested, h.locationRefs)
+
+	// Get a page of events. If requested, the recurring events are expanded
+	// into their occurrences between the requested start and end dates.
+	internal.Logger(ctx).Info("request to read events", slog.Any("query", q))
+	var page *internal.Page
+	if expand {
+		page, err = h.expandEvents(ctx, q, q.Filter.StartsAfter, q.Filter.EndsBefore)
+	} else {
+		page, err = h.eventsDB.QueryEvents(ctx, q)
+	}
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	ptrs := make([]*internal.Event, len(page.Events))
+	for i := range page.Events {
+		ptrs[i] = &page.Events[i]
+	}
+	if err := h.fillLocations(ctx, ptrs...); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	views, err := h.withAttendance(ctx, page.Events...)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	events, err := projectEvents(views, requested)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Write the response.
+	if page.NextPageToken != "" {
+		w.Header().Set(nextPageTokenHeader, page.NextPageToken)
+	}
+	writeJSON(ctx, w, events)
+}
+
+func (h *restHandler) search(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request query.
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		httpError(ctx, w, fmt.Errorf("%w: missing search query", service.ErrBadRequest))
+		return
+	}
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > maxPageSize {
+			httpError(ctx, w, fmt.Errorf(
+				"%w: limit must be between 1 and %d", service.ErrBadRequest, maxPageSize))
+			return
+		}
+		limit = l
+	}
+
+	// Search the events.
+	internal.Logger(ctx).Info("request to search events", slog.String("query", query))
+	// The hits hold the data of the locations of the events, even if only
+	// location references are stored.
+	hits, err := h.eventsDB.SearchEvents(ctx, query, limit)
 	if err != nil {
-		service.HTTPError(ctx, w, err)
+		httpError(ctx, w, err)
 		return
 	}
 
 	// Write the response.
+	writeJSON(ctx, w, &struct {
+		Hits []internal.SearchHit `json:"hits"`
+	}{Hits: hits})
+}
+
+func (h *restHandler) replace(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and body.
+	id := chi.URLParam(r, "id")
+	if _, _, ok := internal.ParseOccurrenceID(id); ok {
+		h.replaceOccurrence(w, r)
+		return
+	}
+	var event internal.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		httpError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
+		return
+	}
+	if event.ID == "" {
+		event.ID = id
+	}
+	if event.ID != id {
+		httpError(ctx, w, fmt.Errorf("%w: id cannot be changed", service.ErrBadRequest))
+		return
+	}
+	allowOverlap, err := h.allowOverlap(r)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Replace the event.
+	internal.Logger(ctx).Info("request to replace event", slog.Any("event", event))
+	if err := h.replaceEvent(ctx, id, &event, allowOverlap); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("event successfully replaced")
+
+	// Write the response.
+	writeJSON(ctx, w, &event)
+}
+
+func (h *restHandler) update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and body.
+	id := chi.URLParam(r, "id")
+	if _, _, ok := internal.ParseOccurrenceID(id); ok {
+		h.updateOccurrence(w, r)
+		return
+	}
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
+		return
+	}
+	allowOverlap, err := h.allowOverlap(r)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Update the event. Note that the patch is applied here instead of in the
+	// container, because the location of the patched event must be checked
+	// before it is stored. The event is read and replaced within the same
+	// transaction, so that concurrent updates are not lost.
+	internal.Logger(ctx).Info("request to update event", slog.String("id", id))
+	var event internal.Event
+	err = h.transact(ctx, func(ctx context.Context) error {
+		elem, err := h.eventsDB.GetByID(ctx, internal.EventsCollection, id)
+		if err != nil {
+			return err //nolint:wrapcheck // the container returns service errors
+		}
+		patched, err := internal.ApplyMergePatch(elem, patch)
+		if err != nil {
+			return err //nolint:wrapcheck // intentional
+		}
+		if event, err = h.toEvent(ctx, patched); err != nil {
+			return err
+		}
+		return h.replaceEvent(ctx, id, &event, allowOverlap)
+	})
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("event successfully updated")
+
+	// Write the response.
+	writeJSON(ctx, w, &event)
+}
+
+func (h *restHandler) delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key.
+	id := chi.URLParam(r, "id")
+	if _, _, ok := internal.ParseOccurrenceID(id); ok {
+		h.deleteOccurrence(w, r)
+		return
+	}
+
+	// Delete the event.
+	internal.Logger(ctx).Info("request to delete event", slog.String("id", id))
+	if err := h.deleteEvent(ctx, id); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("event successfully deleted")
+
+	// Write the response.
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createEvent validates the event and stores it as a new event, which is owned
+// by the client of the request. The creation is announced, except for
+// recurring events whose occurrences are announced instead. This function
+// returns a [internal.ValidationError] if the event is not valid, a
+// [internal.ConflictError] if it overlaps with other events and overlaps are
+// not allowed, and [service.ErrNotAllowed] if the client may not create it.
+func (h *restHandler) createEvent(
+	ctx context.Context,
+	event *internal.Event,
+	allowOverlap bool,
+) error {
+	event.Organizer = owner(ctx)
+	if err := h.authorize(ctx, auth.ActionCreate, auth.ResourceEvent, event.Organizer); err != nil {
+		return err
+	}
+	stored, err := h.checkEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+	msg := createdMessage(event)
+	return h.transact(ctx, func(ctx context.Context) error {
+		err := h.storeEvent(ctx, &stored, allowOverlap, func(ctx context.Context) error {
+			return h.eventsDB.Create(ctx, internal.EventsCollection, stored)
+		})
+		if err != nil || stored.Recurrence != nil {
+			return err // the occurrences are announced instead
+		}
+		return enqueue(ctx, h.eventsDB, pubsub.EventCreatedTopic, msg)
+	})
+}
+
+// replaceEvent validates the event and stores it in place of the event with the
+// given id, announcing the update. The event keeps the organizer of the stored
+// event. The errors are the same as for [restHandler.createEvent].
+func (h *restHandler) replaceEvent(
+	ctx context.Context,
+	id string,
+	event *internal.Event,
+	allowOverlap bool,
+) error {
+	organizer, err := h.authorizeEvent(ctx, auth.ActionUpdate, id)
+	if err != nil {
+		return err
+	}
+	event.Organizer = organizer
+	stored, err := h.checkEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+	msg := updatedMessage(event)
+	return h.commit(ctx, internal.EventUpdatedTopic, msg, func(ctx context.Context) error {
+		return h.storeEvent(ctx, &stored, allowOverlap, func(ctx context.Context) error {
+			return h.eventsDB.Replace(ctx, internal.EventsCollection, id, stored)
+		})
+	})
+}
+
+// deleteEvent deletes the event with the given id, and announces the deletion of
+// the event, as well as of the occurrences of a recurring event.
+func (h *restHandler) deleteEvent(ctx context.Context, id string) error {
+	if _, err := h.authorizeEvent(ctx, auth.ActionDelete, id); err != nil {
+		return err
+	}
+	msg := internal.EventDeleted{ID: id}
+	return h.commit(ctx, internal.EventDeletedTopic, msg, func(ctx context.Context) error {
+		series, err := getSeries(ctx, h.eventsDB, id)
+		switch {
+		case err == nil:
+			if err := h.announcer.announceDeleted(ctx, &series, time.Time{}); err != nil {
+				return err
+			}
+		case !errors.Is(err, service.ErrNotFound):
+			return err
+		}
+		return h.eventsDB.Delete(ctx, internal.EventsCollection, id)
+	})
+}
+
+// authorizeEvent makes sure that the client of the request may perform the
+// action on the stored event with the given id, and returns the organizer of
+// the event. This function returns [service.ErrNotFound] if there is no such
+// event, and [service.ErrNotAllowed] if the client may not perform the action.
+func (h *restHandler) authorizeEvent(ctx context.Context, action, id string) (string, error) {
+	elem, err := h.eventsDB.GetByID(ctx, internal.EventsCollection, id)
+	if err != nil {
+		return "", err //nolint:wrapcheck // the container returns service errors
+	}
+	event, ok := elem.(internal.Event)
+	if !ok {
+		return "", internal.Unexpected(ctx, fmt.Errorf("unexpected type %T", elem))
+	}
+	if err := h.authorize(ctx, action, auth.ResourceEvent, event.Organizer); err != nil {
+		return "", err
+	}
+	return event.Organizer, nil
+}
+
+// checkEvent links the event to its location and validates it. The function
+// returns the version of the event that should be stored in the container.
+// This function returns a [internal.ValidationError] if the event is not
+// valid.
+func (h *restHandler) checkEvent(
+	ctx context.Context,
+	event *internal.Event,
+) (internal.Event, error) {
+	stored, err := h.linkLocation(ctx, event)
+	if err != nil {
+		return internal.Event{}, err
+	}
+	if err := internal.ValidateEvent(event); err != nil {
+		return internal.Event{}, err
+	}
+	return stored, nil
+}
+
+// storeEvent stores the event by calling store. The function first makes sure
+// that the location of the event still exists. Unless overlaps are allowed, it
+// then makes sure that the event does not overlap with other events.
+// Otherwise, it makes sure that the client may allow the event to overlap.
+// The due occurrences of a recurring event are announced, which advances
+// [internal.Recurrence.Announced] of the event. If the event replaces a stored
+// one, then the occurrences announced for the stored event are not announced
+// again. The context should belong to the transaction that stores the event.
+func (h *restHandler) storeEvent(
+	ctx context.Context,
+	event *internal.Event,
+	allowOverlap bool,
+	store func(context.Context) error,
+) error {
+	if err := h.lockEventLocation(ctx, event); err != nil {
+		return err
+	}
+	if allowOverlap {
+		err := h.authorize(ctx, auth.ActionAllowOverlap, auth.ResourceEvent, event.Organizer)
+		if err != nil {
+			return err
+		}
+	} else if err := h.checkOverlap(ctx, event); err != nil {
+		return err
+	}
+	if event.Recurrence != nil {
+		series, err := getSeries(ctx, h.eventsDB, event.ID)
+		switch {
+		case err == nil:
+			event.Recurrence.Announced = series.Recurrence.Announced
+		case errors.Is(err, service.ErrNotFound):
+			event.Recurrence.Announced = time.Time{}
+		default:
+			return err
+		}
+		until := time.Now().Add(h.announcer.horizon)
+		if _, err := h.announcer.announce(ctx, event, until); err != nil {
+			return err
+		}
+	}
+	return store(ctx)
+}
+
+// toEvent converts an element of the events collection into an event, and
+// fills in its location data if needed.
+func (h *restHandler) toEvent(ctx context.Context, elem any) (internal.Event, error) {
+	event, ok := elem.(internal.Event)
+	if !ok {
+		return internal.Event{}, internal.Unexpected(ctx, fmt.Errorf("unexpected type %T", elem))
+	}
+	if err := h.fillLocations(ctx, &event); err != nil {
+		return internal.Event{}, err
+	}
+	return event, nil
+}
+
+// createdMessage returns the message notifying that the given event was
+// created.
+func createdMessage(event *internal.Event) pubsub.EventCreated {
+	return pubsub.EventCreated{
+		ID:         event.ID,
+		Name:       event.Name,
+		LocationID: event.Location.ID,
+		Start:      event.StartDate,
+		End:        event.EndDate,
+	}
+}
+
+// updatedMessage returns the message notifying that the given event was
+// updated.
+func updatedMessage(event *internal.Event) internal.EventUpdated {
+	return internal.EventUpdated{
+		ID:         event.ID,
+		Name:       event.Name,
+		LocationID: event.Location.ID,
+		Start:      event.StartDate,
+		End:        event.EndDate,
+	}
+}
+
+// commit applies a change to the container by calling fn, and writes a message
+// announcing the change to the outbox, within the same transaction. Thus, the
+// message is published if and only if the change is committed.
+func (h *restHandler) commit(
+	ctx context.Context,
+	topic string,
+	payload any,
+	fn func(context.Context) error,
+) error {
+	return h.transact(ctx, func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		return enqueue(ctx, h.eventsDB, topic, payload)
+	})
+}
+
+// transact runs fn within a transaction, and wakes up the relay once the
+// transaction is committed, since fn might have written messages to the
+// outbox.
+func (h *restHandler) transact(ctx context.Context, fn func(context.Context) error) error {
+	if err := h.eventsDB.WithTransaction(ctx, fn); err != nil {
+		return err //nolint:wrapcheck // errors of fn are returned as is
+	}
+	h.relay.notify()
+	return nil
+}
+
+// httpError maps the provided error to the correct http status code and writes
+// it to the response writer. Validation errors are written as a json body that
+// lists the fields that failed validation, and conflicts as a json body that
+// lists the conflicting events. Unauthenticated clients are asked to send a
+// bearer token. All other errors are handled by [service.HTTPError].
+func httpError(ctx context.Context, w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		internal.Logger(ctx).Info("client is not authenticated",
+			slog.String("error", err.Error()))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="events-service"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var vErr *internal.ValidationError
+	if errors.As(err, &vErr) {
+		internal.Logger(ctx).Info("client made an invalid request",
+			slog.String("error", err.Error()))
+		w.Header().Set("Content-Type", "application/json; charset=utf8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusBadRequest)
+		body := struct {
+			Error  string                `json:"error"`
+			Fields []internal.FieldError `json:"fields"`
+		}{
+			Error:  service.ErrBadRequest.Error(),
+			Fields: vErr.Fields,
+		}
+		if err := json.NewEncoder(w).Encode(&body); err != nil {
+			internal.Logger(ctx).Info("failed to write response", slog.String("error", err.Error()))
+		}
+		return
+	}
+	var cErr *internal.ConflictError
+	if errors.As(err, &cErr) {
+		internal.Logger(ctx).Info("client requested a conflicting event",
+			slog.String("error", err.Error()))
+		w.Header().Set("Content-Type", "application/json; charset=utf8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusConflict)
+		body := struct {
+			Error     string   `json:"error"`
+			Conflicts []string `json:"conflicts"`
+		}{
+			Error:     service.ErrAlreadyExists.Error(),
+			Conflicts: cErr.IDs,
+		}
+		if err := json.NewEncoder(w).Encode(&body); err != nil {
+			internal.Logger(ctx).Info("failed to write response", slog.String("error", err.Error()))
+		}
+		return
+	}
+	service.HTTPError(ctx, w, err)
+}
+
+// writeJSON encodes v as the JSON body of the response. The failures to write
+// the response are logged with the logger of the context.
+func writeJSON(ctx context.Context, w http.ResponseWriter, v any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf8")
-	if err := json.NewEncoder(w).Encode(&events); err != nil {
-		slog.Info("failed to write response", err)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		internal.Logger(ctx).Info("failed to write response", slog.String("error", err.Error()))
 	}
 }
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/pubsub"
+	"github.com/eventscompass/service-framework/service"
+)
+
+const (
+	// maxImportEvents is the maximum number of events that can be
+	// imported with a single request.
+	maxImportEvents = 10 * maxPageSize
+
+	// exportBatchSize is the number of events that are written
+	// to the client at once while streaming an export.
+	exportBatchSize = maxPageSize
+
+	// exportWriteTimeout is the time within which every batch of
+	// an export must be written to the client.
+	exportWriteTimeout = 30 * time.Second
+
+	// maxNDJSONLine is the maximum length of a line of an NDJSON
+	// import in bytes.
+	maxNDJSONLine = 1 << 20
+)
+
+// The formats of imports and exports.
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// errDryRun is returned to roll back the transactions of a dry run.
+var errDryRun = errors.New("dry run")
+
+// importRow is an event read from a row of an import.
+type importRow struct {
+	// line is the line of the row within the request body.
+	line  int
+	event internal.Event
+
+	// stored is the version of the event that is stored, if the
+	// event is valid. See [restHandler.checkEvent].
+	stored internal.Event
+	err    error
+}
+
+// importError is the reason why a row of an import was not imported.
+type importError struct {
+	Line      int                   `json:"line"`
+	ID        string                `json:"id,omitempty"`
+	Error     string                `json:"error"`
+	Fields    []internal.FieldError `json:"fields,omitempty"`
+	Conflicts []string              `json:"conflicts,omitempty"`
+}
+
+// importReport is the response body of an import.
+type importReport struct {
+	DryRun   bool          `json:"dry_run"`
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Errors   []importError `json:"errors,omitempty"`
+}
+
+// importOptions are the options of an import, given as query parameters.
+type importOptions struct {
+	// format is the format of the request body, either "csv"
+	// or "ndjson".
+	format string
+
+	// dryRun is set if the events are checked, but not stored.
+	dryRun bool
+
+	// bestEffort is set if the valid events are imported even
+	// if other events of the import are not valid. Otherwise,
+	// either all the events are imported or none of them is.
+	bestEffort bool
+
+	// allowOverlap is set if the events are imported even if
+	// they overlap with other events. See
+	// [restHandler.allowOverlap].
+	allowOverlap bool
+}
+
+// parseImportOptions parses the options of an import from the query of the
+// request. This function returns [service.ErrBadRequest] if an option is not
+// valid.
+func (h *restHandler) parseImportOptions(r *http.Request) (*importOptions, error) {
+	var opts importOptions
+	var err error
+	params := r.URL.Query()
+	if opts.format, err = parseFormat(params); err != nil {
+		return nil, err
+	}
+	if opts.dryRun, err = parseBool(params, "dry_run"); err != nil {
+		return nil, err
+	}
+	if opts.bestEffort, err = parseBool(params, "best_effort"); err != nil {
+		return nil, err
+	}
+	if opts.allowOverlap, err = h.allowOverlap(r); err != nil {
+		return nil, err
+	}
+	return &opts, nil
+}
+
+func (h *restHandler) importEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request query and body.
+	opts, err := h.parseImportOptions(r)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	rows, err := readImport(r.Body, opts.format)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Import the events. Unless the import is best effort, either all the
+	// events are imported or none of them is. The imported events are owned
+	// by the client of the request.
+	internal.Logger(ctx).Info("request to import events",
+		slog.Int("rows", len(rows)),
+		slog.Bool("dry_run", opts.dryRun),
+		slog.Bool("best_effort", opts.bestEffort),
+	)
+	if err := h.authorize(ctx, auth.ActionCreate, auth.ResourceEvent, owner(ctx)); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	if err := h.checkImport(ctx, rows); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	report := importReport{DryRun: opts.dryRun}
+	if opts.bestEffort {
+		err = h.importEach(ctx, rows, opts, &report)
+	} else {
+		err = h.importAll(ctx, rows, opts, &report)
+	}
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("events imported",
+		slog.Int("imported", report.Imported),
+		slog.Int("failed", report.Failed),
+	)
+
+	// Write the response. An import that is not best effort fails as a whole.
+	if !opts.bestEffort && report.Failed > 0 {
+		w.Header().Set("Content-Type", "application/json; charset=utf8")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	writeJSON(ctx, w, &report)
+}
+
+// checkImport links the events of the rows to their locations and validates
+// them. The events are owned by the client of the request. The errors of the
+// rows are recorded in the rows. The function returns an error only if the
+// check could not be carried out.
+func (h *restHandler) checkImport(ctx context.Context, rows []importRow) error {
+	seen := make(map[string]bool, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		if row.err != nil {
+			continue
+		}
+		if row.event.ID == "" {
+			row.event.ID = internal.NewID()
+		}
+		if seen[row.event.ID] {
+			row.err = fmt.Errorf("%w: duplicate id %q in the import",
+				service.ErrAlreadyExists, row.event.ID)
+			continue
+		}
+		seen[row.event.ID] = true
+		row.event.Organizer = owner(ctx)
+		row.stored, row.err = h.checkEvent(ctx, &row.event)
+		if row.err != nil && !isRowError(row.err) {
+			return row.err
+		}
+	}
+	return nil
+}
+
+// importAll imports the events of the rows within a single transaction. If
+// any row fails, then nothing is imported. In a dry run, the transaction is
+// always rolled back.
+func (h *restHandler) importAll(
+	ctx context.Context,
+	rows []importRow,
+	opts *importOptions,
+	report *importReport,
+) error {
+	for i := range rows {
+		if rows[i].err != nil {
+			report.add(&rows[i])
+		}
+	}
+	if report.Failed > 0 {
+		return nil
+	}
+	var failed *importRow
+	err := h.transact(ctx, func(ctx context.Context) error {
+		failed = nil
+		for i := range rows {
+			if err := h.importRow(ctx, &rows[i], opts.allowOverlap); err != nil {
+				failed = &rows[i]
+				return err
+			}
+		}
+		if opts.dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	switch {
+	case err == nil || errors.Is(err, errDryRun):
+		report.Imported = len(rows)
+		return nil
+	case isRowError(err):
+		failed.err = err
+		report.add(failed)
+		return nil
+	default:
+		return err
+	}
+}
+
+// importEach imports the event of every row within its own transaction, so
+// that the rows that fail do not prevent the others from being imported. In a
+// dry run, the transactions are always rolled back. Thus, the rows of a dry run
+// are checked independently of each other.
+func (h *restHandler) importEach(
+	ctx context.Context,
+	rows []importRow,
+	opts *importOptions,
+	report *importReport,
+) error {
+	for i := range rows {
+		row := &rows[i]
+		if row.err == nil {
+			err := h.transact(ctx, func(ctx context.Context) error {
+				if err := h.importRow(ctx, row, opts.allowOverlap); err != nil {
+					return err
+				}
+				if opts.dryRun {
+					return errDryRun
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, errDryRun) {
+				if !isRowError(err) {
+					return err
+				}
+				row.err = err
+			}
+		}
+		report.add(row)
+	}
+	return nil
+}
+
+// importRow stores the event of the row and announces its creation. The
+// context should belong to the transaction that stores the event.
+func (h *restHandler) importRow(ctx context.Context, row *importRow, allowOverlap bool) error {
+	err := h.storeEvent(ctx, &row.stored, allowOverlap, func(ctx context.Context) error {
+		return h.eventsDB.Create(ctx, internal.EventsCollection, row.stored)
+	})
+	if err != nil || row.stored.Recurrence != nil {
+		return err // the occurrences are announced instead
+	}
+	return enqueue(ctx, h.eventsDB, pubsub.EventCreatedTopic, createdMessage(&row.event))
+}
+
+// add records the outcome of the row in the report.
+func (r *importReport) add(row *importRow) {
+	if row.err == nil {
+		r.Imported++
+		return
+	}
+	r.Failed++
+	e := importError{Line: row.line, ID: row.event.ID, Error: row.err.Error()}
+	var vErr *internal.ValidationError
+	if errors.As(row.err, &vErr) {
+		e.Error, e.Fields = service.ErrBadRequest.Error(), vErr.Fields
+	}
+	var cErr *internal.ConflictError
+	if errors.As(row.err, &cErr) {
+		e.Error, e.Conflicts = service.ErrAlreadyExists.Error(), cErr.IDs
+	}
+	r.Errors = append(r.Errors, e)
+}
+
+// isRowError returns true if the error is caused by the data of a row of an
+// import, as opposed to a failure of the service.
+func isRowError(err error) bool {
+	return errors.Is(err, service.ErrBadRequest) || errors.Is(err, service.ErrAlreadyExists)
+}
+
+// readImport reads the rows of an import in the given format. A row that
+// cannot be decoded is recorded with its error. This function returns
+// [service.ErrBadRequest] if the import is malformed as a whole, or if it has
+// more than [maxImportEvents] rows.
+func readImport(body io.Reader, format string) ([]importRow, error) {
+	var rows []importRow
+	var err error
+	if format == formatCSV {
+		rows, err = readCSV(body)
+	} else {
+		rows, err = readNDJSON(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > maxImportEvents {
+		return nil, fmt.Errorf("%w: at most %d events can be imported at once",
+			service.ErrBadRequest, maxImportEvents)
+	}
+	return rows, nil
+}
+
+// readCSV reads the rows of a CSV import. The first line is the header, which
+// names the columns. See [internal.CSVDecoder].
+func readCSV(body io.Reader) ([]importRow, error) {
+	r := csv.NewReader(body)
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrBadRequest, err)
+	}
+	d, err := internal.NewCSVDecoder(header)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // NewCSVDecoder returns service errors
+	}
+
+	var rows []importRow
+	for len(rows) <= maxImportEvents {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", service.ErrBadRequest, err)
+		}
+		row := importRow{}
+		row.line, _ = r.FieldPos(0)
+		row.event, row.err = d.Decode(record)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readNDJSON reads the rows of an NDJSON import, which holds an event in
+// every line. Blank lines are skipped.
+func readNDJSON(body io.Reader) ([]importRow, error) {
+	s := bufio.NewScanner(body)
+	s.Buffer(nil, maxNDJSONLine)
+	var rows []importRow
+	for line := 1; len(rows) <= maxImportEvents && s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		row := importRow{line: line}
+		if err := json.Unmarshal(s.Bytes(), &row.event); err != nil {
+			row.err = fmt.Errorf("%w: %v", service.ErrBadRequest, err)
+		}
+		rows = append(rows, row)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrBadRequest, err)
+	}
+	return rows, nil
+}
+
+func (h *restHandler) exportEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request query.
+	params := r.URL.Query()
+	format, err := parseFormat(params)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	filter, err := parseFilter(params)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	if h.locationRefs && filter.NeedsLocationData() {
+		httpError(ctx, w, fmt.Errorf(
+			"%w: filtering by country or capacity is not supported", service.ErrBadRequest))
+		return
+	}
+
+	// Stream the events from the container. The response is not buffered by
+	// the framework, see [EventsService.Streams], so the events are written
+	// to the client in batches. The locations are cached while streaming, so
+	// that each of them is retrieved only once.
+	internal.Logger(ctx).Info("request to export events", slog.Any("filter", filter))
+	rc := http.NewResponseController(w)
+	enc := newExportEncoder(w, format)
+	w.Header().Set("Content-Type", enc.contentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="events.`+format+`"`)
+	n, err := h.streamExport(ctx, rc, enc, filter)
+
+	// The error can only be reported if nothing was written yet. Otherwise
+	// the export ends early.
+	if err != nil && n == 0 {
+		w.Header().Del("Content-Disposition")
+		httpError(ctx, w, err)
+		return
+	}
+	if err != nil {
+		internal.Logger(ctx).Info("failed to write response", slog.String("error", err.Error()))
+	}
+}
+
+// streamExport encodes the events matching the filter, and writes them to the
+// client in batches. The function returns the number of the encoded events.
+func (h *restHandler) streamExport(
+	ctx context.Context,
+	rc *http.ResponseController,
+	enc *exportEncoder,
+	filter *internal.Filter,
+) (int, error) {
+	locations := make(map[string]internal.Location)
+	n := 0
+	err := h.eventsDB.ForEachEvent(ctx, filter, func(e *internal.Event) error {
+		if err := h.fillLocationsFrom(ctx, locations, e); err != nil {
+			return err
+		}
+		if n%exportBatchSize == 0 {
+			if err := extendWriteDeadline(rc); err != nil {
+				return err
+			}
+		}
+		if err := enc.encode(e); err != nil {
+			return err
+		}
+		if n++; n%exportBatchSize == 0 {
+			return flushExport(rc, enc)
+		}
+		return nil
+	})
+	if err != nil {
+		return n, err //nolint:wrapcheck // the container returns service errors
+	}
+	return n, flushExport(rc, enc)
+}
+
+// flushExport writes the buffered events of an export to the client.
+func flushExport(rc *http.ResponseController, enc *exportEncoder) error {
+	if err := enc.flush(); err != nil {
+		return err
+	}
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err //nolint:wrapcheck // intentional
+	}
+	return nil
+}
+
+// extendWriteDeadline extends the write deadline of the connection, so that
+// the next batch of an export can be written to the client.
+func extendWriteDeadline(rc *http.ResponseController) error {
+	err := rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err //nolint:wrapcheck // intentional
+	}
+	return nil
+}
+
+// exportEncoder encodes the events of an export one by one.
+type exportEncoder struct {
+	format string
+	csv    *csv.Writer
+	buf    *bufio.Writer
+	json   *json.Encoder
+}
+
+// newExportEncoder returns an encoder writing the events to w in the given
+// format.
+func newExportEncoder(w io.Writer, format string) *exportEncoder {
+	enc := exportEncoder{format: format}
+	if format == formatCSV {
+		enc.csv = csv.NewWriter(w)
+		enc.csv.Write(internal.CSVHeader) //nolint:errcheck // reported by flush
+	} else {
+		enc.buf = bufio.NewWriter(w)
+		enc.json = json.NewEncoder(enc.buf)
+	}
+	return &enc
+}
+
+// contentType returns the media type of the format of the encoder.
+func (enc *exportEncoder) contentType() string {
+	if enc.format == formatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// encode encodes the given event.
+func (enc *exportEncoder) encode(e *internal.Event) error {
+	if enc.csv != nil {
+		return enc.csv.Write(e.MarshalCSV()) //nolint:wrapcheck // intentional
+	}
+	return enc.json.Encode(e) //nolint:wrapcheck // intentional
+}
+
+// flush writes the buffered events.
+func (enc *exportEncoder) flush() error {
+	if enc.csv != nil {
+		enc.csv.Flush()
+		return enc.csv.Error() //nolint:wrapcheck // intentional
+	}
+	return enc.buf.Flush() //nolint:wrapcheck // intentional
+}
+
+// parseFormat parses the "format" query parameter, which is either "csv" or
+// "ndjson" (the default). This function returns [service.ErrBadRequest] if
+// the format is not supported.
+func parseFormat(params url.Values) (string, error) {
+	switch v := params.Get("format"); v {
+	case "", formatNDJSON:
+		return formatNDJSON, nil
+	case formatCSV:
+		return formatCSV, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported format %q", service.ErrBadRequest, v)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eventscompass/events-service/src/internal"
+)
+
+func TestExportEvents(t *testing.T) {
+	h, ctx := newTestHandler(t, true)
+	n := exportBatchSize + 1
+	for i := 0; i < n; i++ {
+		e := testEvent(fmt.Sprintf("e%04d", i), "A", 10)
+		if err := h.eventsDB.Create(ctx, internal.EventsCollection, e); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	// The export is streamed, and the stored location references are filled
+	// in with the locations.
+	r := httptest.NewRequest(http.MethodGet, exportPath, nil).WithContext(ctx)
+	if !(&EventsService{}).Streams(r) {
+		t.Errorf("Streams(%s) = false, want true", exportPath)
+	}
+	w := httptest.NewRecorder()
+	h.exportEvents(w, r)
+	if w.Code != http.StatusOK || !w.Flushed {
+		t.Fatalf("exportEvents() status = %d, flushed = %v, want %d, true",
+			w.Code, w.Flushed, http.StatusOK)
+	}
+	s := bufio.NewScanner(w.Body)
+	var got int
+	for ; s.Scan(); got++ {
+		var e internal.Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if want := fmt.Sprintf("e%04d", got); e.ID != want || e.Location.Name != "Arena" {
+			t.Errorf("event %d = %s at %q, want %s at %q",
+				got, e.ID, e.Location.Name, want, "Arena")
+		}
+	}
+	if got != n {
+		t.Errorf("exportEvents() = %d events, want %d", got, n)
+	}
+
+	// Other requests are not streamed.
+	r = httptest.NewRequest(http.MethodGet, "/api/events", nil)
+	if (&EventsService{}).Streams(r) {
+		t.Errorf("Streams(/api/events) = true, want false")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+func (h *restHandler) readEventCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key.
+	id := chi.URLParam(r, "id")
+
+	// Get the event.
+	internal.Logger(ctx).Info("request to read event calendar", slog.String("id", id))
+	event, err := findEvent(ctx, h.eventsDB, id)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	if err := h.fillLocations(ctx, &event); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Write the response.
+	writeCalendar(w, r, event.Name, []internal.Event{event})
+}
+
+func (h *restHandler) readAllCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request query.
+	from, to, err := parseWindow(r.URL.Query(), h.announcer.horizon)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Get the events taking place within the time.
+	internal.Logger(ctx).Info("request to read events calendar")
+	events, err := h.calendarEvents(ctx, internal.Filter{}, from, to)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Write the response.
+	writeCalendar(w, r, "Events", events)
+}
+
+func (h *restHandler) readLocationCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and query.
+	id := chi.URLParam(r, "id")
+	from, to, err := parseWindow(r.URL.Query(), h.announcer.horizon)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Get the events taking place at the location within the time.
+	internal.Logger(ctx).Info("request to read location calendar", slog.String("location_id", id))
+	location, err := h.getLocation(ctx, id)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	events, err := h.calendarEvents(ctx, internal.Filter{LocationID: id}, from, to)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Write the response.
+	writeCalendar(w, r, location.Name, events)
+}
+
+func (h *restHandler) readHallCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and query.
+	id, hall := chi.URLParam(r, "id"), chi.URLParam(r, "hall")
+	from, to, err := parseWindow(r.URL.Query(), h.announcer.horizon)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Make sure that the hall exists.
+	internal.Logger(ctx).Info("request to read hall calendar",
+		slog.String("location_id", id),
+		slog.String("hall", hall),
+	)
+	location, err := h.getLocation(ctx, id)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	if location.Hall(hall) == nil {
+		httpError(ctx, w, fmt.Errorf(
+			"%w: location %q has no hall %q", service.ErrNotFound, id, hall))
+		return
+	}
+
+	// Get the events taking place in the hall. The occurrences of recurring
+	// events can take place in different halls, so the recurring events are
+	// expanded as for the schedule of the hall. The calendar is truncated to
+	// the earliest events, if there are more.
+	q := internal.Query{
+		Filter: internal.Filter{LocationID: id, Hall: hall, EndsAfter: from, StartsBefore: to},
+		Sort:   internal.SortByStartDate,
+		Limit:  maxExpandedEvents,
+	}
+	page, err := h.expandEvents(ctx, &q, from, to)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	ptrs := make([]*internal.Event, len(page.Events))
+	for i := range page.Events {
+		ptrs[i] = &page.Events[i]
+	}
+	if err := h.fillLocations(ctx, ptrs...); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Write the response.
+	writeCalendar(w, r, location.Name+" - "+hall, page.Events)
+}
+
+// calendarEvents retrieves the events that match the filter and take place
+// between from and to, and fills in their location data. Recurring events are
+// retrieved as a whole, if they have occurrences within the time. At most
+// [maxExpandedEvents] events that do not recur, and as many recurring events,
+// are retrieved, the earliest first.
+func (h *restHandler) calendarEvents(
+	ctx context.Context,
+	filter internal.Filter,
+	from time.Time,
+	to time.Time,
+) ([]internal.Event, error) {
+	single := filter
+	single.EndsAfter, single.StartsBefore = from, to
+	events, err := h.calendarPage(ctx, single)
+	if err != nil {
+		return nil, err
+	}
+	events = slices.DeleteFunc(events, func(e internal.Event) bool { return e.Recurrence != nil })
+
+	// A series has occurrences within the time only if it starts before the
+	// time ends.
+	recurring := filter
+	recurring.Recurring, recurring.StartsBefore = true, to
+	series, err := h.calendarPage(ctx, recurring)
+	if err != nil {
+		return nil, err
+	}
+	for i := range series {
+		occurrences, err := series[i].Occurrences(from, to, 1)
+		if err != nil {
+			return nil, internal.Unexpected(ctx, err)
+		}
+		if len(occurrences) > 0 {
+			events = append(events, series[i])
+		}
+	}
+
+	ptrs := make([]*internal.Event, len(events))
+	for i := range events {
+		ptrs[i] = &events[i]
+	}
+	if err := h.fillLocations(ctx, ptrs...); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// calendarPage retrieves the first [maxExpandedEvents] events that match the
+// filter, the earliest first.
+func (h *restHandler) calendarPage(
+	ctx context.Context,
+	filter internal.Filter,
+) ([]internal.Event, error) {
+	q := internal.Query{Filter: filter, Sort: internal.SortByStartDate, Limit: maxExpandedEvents}
+	page, err := h.eventsDB.QueryEvents(ctx, &q)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the container returns service errors
+	}
+	return page.Events, nil
+}
+
+// writeCalendar encodes the events as the iCalendar body of the response. The
+// response carries an ETag computed from the calendar, so that clients polling
+// the calendar can make conditional requests using If-None-Match. If the
+// calendar did not change, then the body is omitted. The calendar is encoded
+// twice, once for computing the ETag and once for writing it, so that it is
+// not buffered.
+func writeCalendar(w http.ResponseWriter, r *http.Request, name string, events []internal.Event) {
+	ctx, stamp := r.Context(), time.Now()
+	encode := func(dst io.Writer) *internal.CalendarWriter {
+		c := internal.NewCalendarWriter(dst, name, stamp)
+		for i := range events {
+			c.WriteEvent(&events[i])
+		}
+		return c
+	}
+
+	c := encode(io.Discard)
+	_ = c.Close() //nolint:errcheck // writing to io.Discard never fails
+	etag := `"` + hex.EncodeToString(c.Digest()) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if err := encode(w).Close(); err != nil {
+		internal.Logger(ctx).Info("failed to write response", slog.String("error", err.Error()))
+	}
+}
+
+// etagMatch returns true if the value of an If-None-Match header matches the
+// given entity tag. The comparison is weak, as required by RFC 9110.
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
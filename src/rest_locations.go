@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/pubsub"
+	"github.com/eventscompass/service-framework/service"
+)
+
+func (h *restHandler) createLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request body.
+	var location internal.Location
+	if err := json.NewDecoder(r.Body).Decode(&location); err != nil {
+		httpError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
+		return
+	}
+	if location.ID == "" {
+		location.ID = internal.NewID()
+	}
+
+	// Create the location. The location is owned by the client of the
+	// request.
+	internal.Logger(ctx).Info("request to create location", slog.Any("location", location))
+	location.Owner = owner(ctx)
+	err := h.authorize(ctx, auth.ActionCreate, auth.ResourceLocation, location.Owner)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	if err := internal.ValidateLocation(&location); err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	msg := pubsub.LocationCreated{
+		ID:   location.ID,
+		Name: location.Name,
+	}
+	err = h.commit(ctx, pubsub.LocationCreatedTopic, msg, func(ctx context.Context) error {
+		return h.eventsDB.Create(ctx, internal.LocationsCollection, location)
+	})
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("location successfully created")
+
+	// Write the response.
+	w.Header().Set("Location", fmt.Sprintf("%s/id/%s", r.URL.Path, location.ID))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *restHandler) readLocationByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key.
+	id := chi.URLParam(r, "id")
+
+	// Get the location.
+	internal.Logger(ctx).Info("request to read location", slog.String("id", id))
+	location, err := h.eventsDB.GetByID(ctx, internal.LocationsCollection, id)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Write the response.
+	writeJSON(ctx, w, &location)
+}
+
+func (h *restHandler) readLocationByName(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key.
+	name := chi.URLParam(r, "name")
+
+	// Get the location.
+	internal.Logger(ctx).Info("request to read location", slog.String("name", name))
+	location, err := h.eventsDB.GetByName(ctx, internal.LocationsCollection, name)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Write the response.
+	writeJSON(ctx, w, &location)
+}
+
+func (h *restHandler) readAllLocations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Get all locations.
+	internal.Logger(ctx).Info("request to read all locations")
+	locations, err := h.eventsDB.GetAll(ctx, internal.LocationsCollection)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+
+	// Write the response.
+	writeJSON(ctx, w, &locations)
+}
+
+func (h *restHandler) replaceLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and body.
+	id := chi.URLParam(r, "id")
+	var location internal.Location
+	if err := json.NewDecoder(r.Body).Decode(&location); err != nil {
+		httpError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
+		return
+	}
+	if location.ID == "" {
+		location.ID = id
+	}
+	if location.ID != id {
+		httpError(ctx, w, fmt.Errorf("%w: id cannot be changed", service.ErrBadRequest))
+		return
+	}
+
+	// Replace the location. The owner of the location does not change.
+	internal.Logger(ctx).Info("request to replace location", slog.Any("location", location))
+	location, err := h.changeLocation(ctx, id,
+		func(internal.Location) (internal.Location, error) { return location, nil })
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("location successfully replaced")
+
+	// Write the response.
+	writeJSON(ctx, w, &location)
+}
+
+func (h *restHandler) updateLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and body.
+	id := chi.URLParam(r, "id")
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(ctx, w, fmt.Errorf("%w: %v", service.ErrBadRequest, err))
+		return
+	}
+
+	// Update the location. Note that the patch is applied here instead of in
+	// the container, because the patched location must be validated before it
+	// is stored. The owner of the location does not change.
+	internal.Logger(ctx).Info("request to update location", slog.String("id", id))
+	location, err := h.changeLocation(ctx, id,
+		func(current internal.Location) (internal.Location, error) {
+			patched, err := internal.ApplyMergePatch(current, patch)
+			if err != nil {
+				return internal.Location{}, err
+			}
+			location, ok := patched.(internal.Location)
+			if !ok {
+				return internal.Location{}, internal.Unexpected(ctx,
+					fmt.Errorf("unexpected type %T", patched))
+			}
+			return location, nil
+		})
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("location successfully updated")
+
+	// Write the response.
+	writeJSON(ctx, w, &location)
+}
+
+func (h *restHandler) deleteLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key.
+	id := chi.URLParam(r, "id")
+
+	// Delete the location. The location is checked and deleted within the
+	// same transaction, which conflicts with the transactions storing events
+	// at the location, see [restHandler.lockLocation].
+	internal.Logger(ctx).Info("request to delete location", slog.String("id", id))
+	err := h.transact(ctx, func(ctx context.Context) error {
+		if _, err := h.authorizeLocation(ctx, auth.ActionDelete, id); err != nil {
+			return err
+		}
+		if err := h.checkLocationUnused(ctx, id); err != nil {
+			return err
+		}
+		return h.eventsDB.Delete(ctx, internal.LocationsCollection, id)
+	})
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	internal.Logger(ctx).Info("location successfully deleted")
+
+	// Write the response.
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+const (
+	// defaultPageSize is the number of events returned in a
+	// page, if the client does not provide a limit.
+	defaultPageSize = 100
+
+	// maxPageSize is the maximum number of events that can be
+	// returned in a page.
+	maxPageSize = 1000
+
+	// defaultSearchLimit is the number of search hits returned,
+	// if the client does not provide a limit.
+	defaultSearchLimit = 20
+)
+
+// nextPageTokenHeader is the response header that holds the token of the next
+// page of events, if there is one. The token is passed in a header, so that the
+// body of the listed events remains a bare array.
+const nextPageTokenHeader = "X-Next-Page-Token"
+
+// parseQuery parses the url query parameters of the request into a query for
+// retrieving events from the container. The following parameters are
+// supported:
+//
+//   - limit: the maximum number of events in the page
+//   - page_token: the token of the page, as returned in the previous page
+//   - sort: the sort order, one of "start_date" or "name", optionally
+//     prefixed with "-" for descending order
+//   - fields: a comma-separated list of the fields to be returned, which
+//     may include the attendance fields "booked", "remaining" and "sold_out"
+//
+// The events can be filtered using the parameters supported by [parseFilter].
+// This function returns [service.ErrBadRequest] if any of the parameters is
+// not valid.
+func parseQuery(r *http.Request) (*internal.Query, error) {
+	params := r.URL.Query()
+	q := internal.Query{
+		Limit:     defaultPageSize,
+		PageToken: params.Get("page_token"),
+	}
+
+	filter, err := parseFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	q.Filter = *filter
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return nil, fmt.Errorf(
+				"%w: limit must be between 1 and %d", service.ErrBadRequest, maxPageSize)
+		}
+		q.Limit = limit
+	}
+
+	sort, err := internal.ParseSort(params.Get("sort"))
+	if err != nil {
+		return nil, err
+	}
+	q.Sort = sort
+
+	if v := params.Get("fields"); v != "" {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if _, ok := internal.EventField(f); !ok && !attendanceFields[f] {
+				return nil, fmt.Errorf("%w: unknown field %q", service.ErrBadRequest, f)
+			}
+			q.Fields = append(q.Fields, f)
+		}
+	}
+
+	return &q, nil
+}
+
+// parseFilter parses the given url query parameters into a filter for events.
+// The following parameters are supported:
+//
+//   - starts_after: events starting at or after the given time (RFC 3339)
+//   - ends_before: events ending at or before the given time (RFC 3339)
+//   - country: events taking place in the given country
+//   - location_id: events taking place at the given location
+//   - hall: events taking place in the hall with the given name
+//   - min_capacity: events taking place in a hall with at least the given
+//     capacity
+//
+// This function returns [service.ErrBadRequest] if any of the parameters is
+// not valid.
+func parseFilter(params url.Values) (*internal.Filter, error) {
+	f := internal.Filter{
+		Country:    params.Get("country"),
+		LocationID: params.Get("location_id"),
+		Hall:       params.Get("hall"),
+	}
+
+	var err error
+	if f.StartsAfter, err = parseTime(params, "starts_after"); err != nil {
+		return nil, err
+	}
+	if f.EndsBefore, err = parseTime(params, "ends_before"); err != nil {
+		return nil, err
+	}
+	if v := params.Get("min_capacity"); v != "" {
+		f.MinCapacity, err = strconv.Atoi(v)
+		if err != nil || f.MinCapacity < 0 {
+			return nil, fmt.Errorf(
+				"%w: min_capacity must be a non-negative integer", service.ErrBadRequest)
+		}
+	}
+	return &f, nil
+}
+
+// parseTime parses the url query parameter with the given key as an RFC 3339
+// timestamp. A missing parameter is parsed as the zero time.
+func parseTime(params url.Values, key string) (time.Time, error) {
+	v := params.Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s: %v", service.ErrBadRequest, key, err)
+	}
+	return t, nil
+}
+
+// parseBool parses the url query parameter with the given key as a boolean. A
+// missing parameter is parsed as false. This function returns
+// [service.ErrBadRequest] if the parameter is not a boolean.
+func parseBool(params url.Values, key string) (bool, error) {
+	v := params.Get(key)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", service.ErrBadRequest, key, err)
+	}
+	return b, nil
+}
+
+// storedFields returns the fields of the events that have to be retrieved from
+// the container, in order to respond with the requested fields. The attendance
+// of an event is computed from the capacity of its hall, thus the hall has to
+// be retrieved instead of the attendance fields. If only location references
+// are stored, then the location id has to be retrieved as well, since the
+// location data is filled in from it.
+func storedFields(requested []string, locationRefs bool) []string {
+	if len(requested) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(requested))
+	attendance := false
+	for _, f := range requested {
+		if attendanceFields[f] {
+			attendance = true
+			continue
+		}
+		res = append(res, f)
+	}
+	if attendance {
+		res = append(res, "hall", "location.halls")
+	}
+	if locationRefs {
+		res = append(res, "location.id")
+	}
+	return res
+}
+
+// projectEvents returns the json representation of the given events including
+// only the given fields. If no fields are given, then the events are returned
+// in full.
+func projectEvents(events []eventView, fields []string) ([]any, error) {
+	res := make([]any, 0, len(events))
+	if len(fields) == 0 {
+		for _, e := range events {
+			res = append(res, e)
+		}
+		return res, nil
+	}
+
+	paths := make([][]string, 0, len(fields))
+	for _, f := range fields {
+		paths = append(paths, strings.Split(f, "."))
+	}
+	for _, e := range events {
+		raw, err := json.Marshal(&e)
+		if err != nil {
+			return nil, fmt.Errorf("%w: encode event: %v", service.ErrUnexpected, err)
+		}
+		var doc any
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("%w: decode event: %v", service.ErrUnexpected, err)
+		}
+		res = append(res, pick(doc, paths))
+	}
+	return res, nil
+}
+
+// pick returns a copy of the json document including only the members along
+// the given paths. Arrays are traversed, i.e. the paths are applied to each of
+// their elements.
+func pick(doc any, paths [][]string) any {
+	switch d := doc.(type) {
+	case []any:
+		res := make([]any, 0, len(d))
+		for _, elem := range d {
+			res = append(res, pick(elem, paths))
+		}
+		return res
+	case map[string]any:
+		// Group the remaining paths by their first member.
+		children := make(map[string][][]string)
+		for _, p := range paths {
+			if len(p) == 0 {
+				return doc // the whole document was requested
+			}
+			children[p[0]] = append(children[p[0]], p[1:])
+		}
+		res := make(map[string]any, len(children))
+		for k, rest := range children {
+			if v, ok := d[k]; ok {
+				res[k] = pick(v, rest)
+			}
+		}
+		return res
+	default:
+		return doc
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/memory"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// newTestService creates a service with the rest api of the tests, over a
+// memory container and a memory bus. The clients authenticate as in
+// [newTestAuthPolicy], and the location l1 with the halls A and B is stored.
+func newTestService(t *testing.T) *EventsService {
+	t.Helper()
+	tracer, _, err := newTracer(&TracingConfig{Exporter: "none"})
+	if err != nil {
+		t.Fatalf("newTracer() error = %v", err)
+	}
+	db := memory.NewContainer()
+	bus := memory.NewBus()
+	relay := newOutboxRelay(db, bus, time.Second, time.Second)
+	s := &EventsService{
+		eventsBus: bus,
+		eventsDB:  db,
+		relay:     relay,
+		announcer: &announcer{db: db, relay: relay, horizon: 90 * 24 * time.Hour},
+		auth:      newTestAuthPolicy(t),
+		tenants:   newTestTenancy(t, false),
+		metrics:   newServiceMetrics(db),
+		tracer:    tracer,
+		cfg:       &Config{},
+	}
+	s.initREST()
+
+	location := internal.Location{
+		ID:      "l1",
+		Name:    "Arena",
+		Address: "1 Main Street",
+		Country: "NL",
+		Halls:   []internal.Hall{{Name: "A", Capacity: 100}, {Name: "B", Capacity: 200}},
+	}
+	if w := send(t, s, http.MethodPost, "/api/locations", location); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/locations status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	return s
+}
+
+// send sends a request with the given body, encoded as JSON unless it is a
+// string, to the rest api of the service. The request is made by an admin.
+func send(
+	t *testing.T,
+	s *EventsService,
+	method, path string,
+	body any,
+) *httptest.ResponseRecorder {
+	t.Helper()
+	var b []byte
+	switch body := body.(type) {
+	case nil:
+	case string:
+		b = []byte(body)
+	default:
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+	}
+	r := httptest.NewRequest(method, path, bytes.NewReader(b))
+	r.Header.Set(adminTokenHeader, testAdminToken)
+	w := httptest.NewRecorder()
+	s.REST().ServeHTTP(w, r)
+	return w
+}
+
+func TestRoutes(t *testing.T) {
+	s := newTestService(t)
+	e := testEvent("e.1", "A", 10)
+	if w := send(t, s, http.MethodPost, "/api/events", e); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/events status = %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	// An id with a dot is routed to the event, and not to its calendar.
+	w := send(t, s, http.MethodGet, "/api/events/id/e.1", nil)
+	var got internal.Event
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.ID != e.ID {
+		t.Errorf("GET /api/events/id/e.1 = %d %q, want event %s", w.Code, w.Body, e.ID)
+	}
+
+	w = send(t, s, http.MethodGet, "/api/events/id/e.1/calendar.ics", nil)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") ||
+		!strings.Contains(w.Body.String(), "UID:e.1\r\n") {
+		t.Errorf("GET /api/events/id/e.1/calendar.ics = %d %s %q, want the calendar of e.1",
+			w.Code, ct, w.Body)
+	}
+}
+
+func TestReadHallSchedule(t *testing.T) {
+	s := newTestService(t)
+	for i, id := range []string{"e1", "e2", "e3"} {
+		e := testEvent(id, "A", 10+3*i)
+		if w := send(t, s, http.MethodPost, "/api/events", e); w.Code != http.StatusCreated {
+			t.Fatalf("POST /api/events status = %d, want %d", w.Code, http.StatusCreated)
+		}
+	}
+
+	// The slots are listed as a bare array, and the token of the next page is
+	// passed in a header.
+	first := "/api/locations/l1/halls/A/schedule?from=2030-01-01T00:00:00Z&limit=2"
+	path := first
+	var got []string
+	for page := 0; path != ""; page++ {
+		w := send(t, s, http.MethodGet, path, nil)
+		var slots []slot
+		if err := json.Unmarshal(w.Body.Bytes(), &slots); err != nil {
+			t.Fatalf("GET schedule page %d = %d %q, want slots", page, w.Code, w.Body)
+		}
+		for _, sl := range slots {
+			got = append(got, sl.EventID)
+		}
+		path = ""
+		if token := w.Header().Get(nextPageTokenHeader); token != "" {
+			path = first + "&page_token=" + url.QueryEscape(token)
+		}
+	}
+	if want := []string{"e1", "e2", "e3"}; !slices.Equal(got, want) {
+		t.Errorf("schedule = %v, want %v", got, want)
+	}
+}
+
+func TestEventsAPI(t *testing.T) {
+	s := newTestService(t)
+
+	// Created events are located by the Location header.
+	e := testEvent("e1", "A", 10)
+	w := send(t, s, http.MethodPost, "/api/events", e)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/api/events/id/e1" {
+		t.Fatalf("POST /api/events = %d at %q, want %d at %q",
+			w.Code, w.Header().Get("Location"), http.StatusCreated, "/api/events/id/e1")
+	}
+	got := readEvent(t, s, "e1")
+	if got.Name != e.Name || got.Location.Name != "Arena" {
+		t.Errorf("GET /api/events/id/e1 = %+v, want %s at Arena", got, e.Name)
+	}
+
+	// Invalid and overlapping events are rejected with the reasons.
+	invalid := testEvent("e2", "A", 14)
+	invalid.Name = ""
+	w = send(t, s, http.MethodPost, "/api/events", invalid)
+	var vErr struct {
+		Error  string                `json:"error"`
+		Fields []internal.FieldError `json:"fields"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &vErr); err != nil ||
+		w.Code != http.StatusBadRequest || len(vErr.Fields) != 1 || vErr.Fields[0].Field != "name" {
+		t.Errorf("POST invalid event = %d %q, want %d with field name",
+			w.Code, w.Body, http.StatusBadRequest)
+	}
+	w = send(t, s, http.MethodPost, "/api/events", testEvent("e3", "A", 11))
+	var cErr struct {
+		Error     string   `json:"error"`
+		Conflicts []string `json:"conflicts"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &cErr); err != nil ||
+		w.Code != http.StatusConflict || !slices.Equal(cErr.Conflicts, []string{"e1"}) {
+		t.Errorf("POST overlapping event = %d %q, want %d with conflict e1",
+			w.Code, w.Body, http.StatusConflict)
+	}
+
+	// Replacing changes every field, and merge patches change only the fields
+	// of the patch, removing those set to null.
+	e.Name = "Replaced"
+	if w := send(t, s, http.MethodPut, "/api/events/id/e1", e); w.Code != http.StatusOK {
+		t.Errorf("PUT /api/events/id/e1 status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := readEvent(t, s, "e1"); got.Name != e.Name {
+		t.Errorf("GET replaced event name = %q, want %q", got.Name, e.Name)
+	}
+	patch := `{"name":"Patched","hall":null}`
+	if w := send(t, s, http.MethodPatch, "/api/events/id/e1", patch); w.Code != http.StatusOK {
+		t.Errorf("PATCH /api/events/id/e1 status = %d, want %d", w.Code, http.StatusOK)
+	}
+	got = readEvent(t, s, "e1")
+	if got.Name != "Patched" || got.Hall != "" || !got.StartDate.Equal(e.StartDate) ||
+		got.Location.ID != e.Location.ID {
+		t.Errorf("GET /api/events/id/e1 = %+v, want the patched event", got)
+	}
+
+	w = send(t, s, http.MethodDelete, "/api/events/id/e1", nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("DELETE /api/events/id/e1 status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if w := send(t, s, http.MethodGet, "/api/events/id/e1", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET deleted event status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestLocationsAPI(t *testing.T) {
+	s := newTestService(t)
+	read := func(path string) internal.Location {
+		t.Helper()
+		w := send(t, s, http.MethodGet, path, nil)
+		var l internal.Location
+		if err := json.Unmarshal(w.Body.Bytes(), &l); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %q, want a location", path, w.Code, w.Body)
+		}
+		return l
+	}
+	if l := read("/api/locations/id/l1"); l.Name != "Arena" || len(l.Halls) != 2 {
+		t.Errorf("GET /api/locations/id/l1 = %+v, want Arena with 2 halls", l)
+	}
+	if l := read("/api/locations/name/Arena"); l.ID != "l1" {
+		t.Errorf("GET /api/locations/name/Arena = %+v, want l1", l)
+	}
+
+	// Patching keeps the halls.
+	w := send(t, s, http.MethodPatch, "/api/locations/id/l1", `{"name":"Stadium"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("PATCH /api/locations/id/l1 status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if l := read("/api/locations/id/l1"); l.Name != "Stadium" || len(l.Halls) != 2 {
+		t.Errorf("GET patched location = %+v, want Stadium with 2 halls", l)
+	}
+
+	w = send(t, s, http.MethodGet, "/api/locations", nil)
+	var all []internal.Location
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil || len(all) != 1 {
+		t.Errorf("GET /api/locations = %d %q, want 1 location", w.Code, w.Body)
+	}
+
+	// Locations with upcoming events cannot be deleted.
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	e := testEventAt("e1", start)
+	if w := send(t, s, http.MethodPost, "/api/events", e); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/events status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	w = send(t, s, http.MethodDelete, "/api/locations/id/l1", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("DELETE used location status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	send(t, s, http.MethodDelete, "/api/events/id/e1", nil)
+	w = send(t, s, http.MethodDelete, "/api/locations/id/l1", nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("DELETE unused location status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestQueryParameters(t *testing.T) {
+	s := newTestService(t)
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"limit=10&fields=id,name,booked&sort=-name&country=NL&min_capacity=10", http.StatusOK},
+		{"limit=0", http.StatusBadRequest},
+		{"limit=ten", http.StatusBadRequest},
+		{"limit=1001", http.StatusBadRequest},
+		{"page_token=garbage", http.StatusBadRequest},
+		{"fields=id,unknown", http.StatusBadRequest},
+		{"sort=capacity", http.StatusBadRequest},
+		{"starts_after=yesterday", http.StatusBadRequest},
+		{"min_capacity=-1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := send(t, s, http.MethodGet, "/api/events?"+tt.query, nil); w.Code != tt.want {
+			t.Errorf("GET /api/events?%s status = %d, want %d", tt.query, w.Code, tt.want)
+		}
+	}
+}
+
+func TestAttendance(t *testing.T) {
+	s := newTestService(t)
+	small := internal.Location{
+		ID:      "l2",
+		Name:    "Club",
+		Address: "2 Main Street",
+		Country: "NL",
+		Halls:   []internal.Hall{{Name: "Small", Capacity: 2}},
+	}
+	if w := send(t, s, http.MethodPost, "/api/locations", small); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/locations status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	e := testEvent("e1", "Small", 10)
+	e.Location = internal.Location{ID: "l2"}
+	if w := send(t, s, http.MethodPost, "/api/events", e); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/events status = %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	// A booking that is delivered twice is counted once.
+	book := func(user string) {
+		msg := fmt.Sprintf(`{"event_id":"e1","user_id":%q}`, user)
+		s.eventBooked(context.Background(), []byte(msg))
+	}
+	attendance := func() internal.Attendance {
+		t.Helper()
+		w := send(t, s, http.MethodGet, "/api/events?fields=id,booked,remaining,sold_out", nil)
+		var views []eventView
+		if err := json.Unmarshal(w.Body.Bytes(), &views); err != nil || len(views) != 1 {
+			t.Fatalf("GET /api/events = %d %q, want 1 event", w.Code, w.Body)
+		}
+		return views[0].Attendance
+	}
+	book("u1")
+	book("u1")
+	if a := attendance(); a.Booked != 1 || a.Remaining == nil || *a.Remaining != 1 || a.SoldOut {
+		t.Errorf("attendance = %+v, want 1 booked, 1 remaining", a)
+	}
+	book("u2")
+	if a := attendance(); a.Booked != 2 || a.Remaining == nil || *a.Remaining != 0 || !a.SoldOut {
+		t.Errorf("attendance = %+v, want sold out", a)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	s := newTestService(t)
+	w := send(t, s, http.MethodGet, "/readyz", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /readyz status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// The status of every dependency is reported.
+	s.eventsBus = &downBus{Bus: memory.NewBus()}
+	w = send(t, s, http.MethodGet, "/readyz", nil)
+	var h health
+	if err := json.Unmarshal(w.Body.Bytes(), &h); err != nil {
+		t.Fatalf("GET /readyz = %q, want the health", w.Body)
+	}
+	if w.Code != http.StatusServiceUnavailable || h.Status != statusDown ||
+		h.Dependencies["database"].Status != statusUp ||
+		h.Dependencies["message_bus"].Status != statusDown ||
+		h.Dependencies["message_bus"].Error == "" {
+		t.Errorf("GET /readyz = %d %+v, want %d with the message bus down",
+			w.Code, h, http.StatusServiceUnavailable)
+	}
+}
+
+// readEvent reads the event with the given id from the rest api.
+func readEvent(t *testing.T, s *EventsService, id string) internal.Event {
+	t.Helper()
+	w := send(t, s, http.MethodGet, "/api/events/id/"+id, nil)
+	var e internal.Event
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET /api/events/id/%s = %d %q, want an event", id, w.Code, w.Body)
+	}
+	return e
+}
+
+// downBus is a memory bus that cannot be reached.
+type downBus struct {
+	*memory.Bus
+}
+
+// Ping implements the [messageBus] interface.
+func (b *downBus) Ping(context.Context) error {
+	return fmt.Errorf("%w: bus is down", service.ErrConnectionClosed)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// maxConflicts is the maximum number of conflicting events that are listed when
+// an event overlaps with other events.
+const maxConflicts = 100
+
+// slot is a time slot during which a hall is occupied by an event.
+type slot struct {
+	EventID   string    `json:"event_id"`
+	Name      string    `json:"name"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+}
+
+func (h *restHandler) readHallSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Decode the request key and query.
+	id, hall := chi.URLParam(r, "id"), chi.URLParam(r, "hall")
+	params := r.URL.Query()
+	q := internal.Query{
+		Filter:    internal.Filter{LocationID: id, Hall: hall},
+		Sort:      internal.SortByStartDate,
+		Limit:     defaultPageSize,
+		PageToken: params.Get("page_token"),
+	}
+	from, to, err := parseWindow(params, h.announcer.horizon)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	q.Filter.EndsAfter, q.Filter.StartsBefore = from, to
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			httpError(ctx, w, fmt.Errorf(
+				"%w: limit must be between 1 and %d", service.ErrBadRequest, maxPageSize))
+			return
+		}
+		q.Limit = limit
+	}
+
+	// Make sure that the hall exists.
+	internal.Logger(ctx).Info("request to read hall schedule",
+		slog.String("location_id", id),
+		slog.String("hall", hall),
+	)
+	location, err := h.getLocation(ctx, id)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	if location.Hall(hall) == nil {
+		httpError(ctx, w, fmt.Errorf(
+			"%w: location %q has no hall %q", service.ErrNotFound, id, hall))
+		return
+	}
+
+	// Get a page of the events taking place in the hall, including the
+	// occurrences of the recurring events.
+	page, err := h.expandEvents(ctx, &q, from, to)
+	if err != nil {
+		httpError(ctx, w, err)
+		return
+	}
+	slots := make([]slot, 0, len(page.Events))
+	for _, e := range page.Events {
+		slots = append(slots, slot{
+			EventID:   e.ID,
+			Name:      e.Name,
+			StartDate: e.StartDate,
+			EndDate:   e.EndDate,
+		})
+	}
+
+	// Write the response.
+	if page.NextPageToken != "" {
+		w.Header().Set(nextPageTokenHeader, page.NextPageToken)
+	}
+	writeJSON(ctx, w, slots)
+}
+
+// parseWindow parses the "from" and "to" query parameters, which bound the
+// time of a schedule. The time starts now and spans the given horizon by
+// default. This function returns [service.ErrBadRequest] if a parameter is not
+// a valid time.
+func parseWindow(params url.Values, horizon time.Duration) (time.Time, time.Time, error) {
+	from, err := parseTime(params, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if from.IsZero() {
+		from = time.Now()
+	}
+	to, err := parseTime(params, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if to.IsZero() {
+		to = from.Add(horizon)
+	}
+	return from, to, nil
+}
+
+// allowOverlap returns true if the request asks to store an event even if it
+// overlaps with other events, using the "allow_overlap" query parameter.
+// Whether the client may do so is decided by the policy, once the event is
+// stored, see [restHandler.storeEvent]. This function returns
+// [service.ErrBadRequest] if the parameter is not a boolean.
+func (h *restHandler) allowOverlap(r *http.Request) (bool, error) {
+	return parseBool(r.URL.Query(), "allow_overlap")
+}
+
+// checkOverlap makes sure that no other event takes place in a hall of the
+// given event at an overlapping time. The occurrences of recurring events are
+// checked up to the recurrence horizon. Events that do not take place in a
+// hall are not checked. The check must run within the transaction that stores
+// the event, after the location of the event is locked, see
+// [restHandler.lockLocation]. Concurrent transactions scheduling events at the
+// same location then conflict with each other. One of them is aborted and
+// retried, and sees the events stored by the other. Otherwise, both could pass
+// the check before either is committed. This function returns a
+// [internal.ConflictError] listing the ids of the overlapping events.
+func (h *restHandler) checkOverlap(ctx context.Context, event *internal.Event) error {
+	occurrences, err := h.occupiedSlots(event)
+	if err != nil {
+		return internal.Unexpected(ctx, err)
+	}
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	ids, err := h.findConflicts(ctx, event, occurrences)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return &internal.ConflictError{IDs: ids}
+	}
+	return nil
+}
+
+// occupiedSlots returns the occurrences of the given event, which take place in
+// a hall. A recurring event is expanded from now, or from its start if it
+// starts later, up to the recurrence horizon.
+func (h *restHandler) occupiedSlots(event *internal.Event) ([]internal.Event, error) {
+	from, to := event.StartDate, event.EndDate
+	if event.Recurrence != nil {
+		if now := time.Now(); from.Before(now) {
+			from = now
+		}
+		to = from.Add(h.announcer.horizon)
+	}
+	occurrences, err := event.Occurrences(from, to, maxExpandedEvents)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by the caller
+	}
+	return slices.DeleteFunc(occurrences, func(o internal.Event) bool {
+		return o.Hall == ""
+	}), nil
+}
+
+// findConflicts returns the ids of at most [maxConflicts] events at the
+// location of the given event, which overlap with any of its occurrences. The
+// recurring events are expanded, since their occurrences can take place in
+// different halls at different times.
+func (h *restHandler) findConflicts(
+	ctx context.Context,
+	event *internal.Event,
+	occurrences []internal.Event,
+) ([]string, error) {
+	from, to := timeSpan(occurrences)
+	var ids []string
+	check := func(e *internal.Event) error {
+		if e.ID == event.ID {
+			return nil
+		}
+		others, err := e.Occurrences(from, to, maxExpandedEvents)
+		if err != nil {
+			return internal.Unexpected(ctx, err)
+		}
+		for _, other := range others {
+			conflict := slices.ContainsFunc(occurrences, func(o internal.Event) bool {
+				return overlaps(&o, &other)
+			})
+			if conflict && len(ids) < maxConflicts {
+				ids = append(ids, other.ID)
+			}
+		}
+		return nil
+	}
+
+	// Look for events, which start before the time ends and end after the
+	// time starts, and for recurring events, which start before the time ends.
+	filter := internal.Filter{LocationID: event.Location.ID, StartsBefore: to, EndsAfter: from}
+	err := h.eventsDB.ForEachEvent(ctx, &filter, func(e *internal.Event) error {
+		if e.Recurrence != nil {
+			return nil // checked below
+		}
+		return check(e)
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the container returns service errors
+	}
+	filter = internal.Filter{LocationID: event.Location.ID, StartsBefore: to, Recurring: true}
+	if err := h.eventsDB.ForEachEvent(ctx, &filter, check); err != nil {
+		return nil, err //nolint:wrapcheck // the container returns service errors
+	}
+	return ids, nil
+}
+
+// timeSpan returns the earliest start date and the latest end date of the
+// given events, which must not be empty.
+func timeSpan(events []internal.Event) (time.Time, time.Time) {
+	from, to := events[0].StartDate, events[0].EndDate
+	for _, e := range events {
+		if e.StartDate.Before(from) {
+			from = e.StartDate
+		}
+		if e.EndDate.After(to) {
+			to = e.EndDate
+		}
+	}
+	return from, to
+}
+
+// overlaps returns true if the given events take place in the same hall at
+// overlapping times.
+func overlaps(a, b *internal.Event) bool {
+	return a.Hall != "" && a.Hall == b.Hall &&
+		a.StartDate.Before(b.EndDate) && b.StartDate.Before(a.EndDate)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eventscompass/events-service/src/internal"
+)
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b internal.Event
+		want bool
+	}{
+		{"same hall", testEvent("a", "A", 10), testEvent("b", "A", 11), true},
+		{"same times", testEvent("a", "A", 10), testEvent("b", "A", 10), true},
+		{"back to back", testEvent("a", "A", 10), testEvent("b", "A", 12), false},
+		{"other hall", testEvent("a", "A", 10), testEvent("b", "B", 11), false},
+		{"no hall", testEvent("a", "", 10), testEvent("b", "", 11), false},
+	}
+	for _, tt := range tests {
+		if got := overlaps(&tt.a, &tt.b); got != tt.want {
+			t.Errorf("%s: overlaps() = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := overlaps(&tt.b, &tt.a); got != tt.want {
+			t.Errorf("%s: overlaps() reversed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOverlap(t *testing.T) {
+	h, ctx := newTestHandler(t, false)
+	other := internal.Location{ID: "l2", Name: "Club", Country: "NL",
+		Halls: []internal.Hall{{Name: "A", Capacity: 50}}}
+	if err := h.eventsDB.Create(ctx, internal.LocationsCollection, other); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// The series takes place daily from 2030-01-01 to 2030-01-05, from 14:00
+	// to 16:00 in hall B.
+	series := testEvent("s1", "B", 14)
+	series.Recurrence = &internal.Recurrence{RRule: "FREQ=DAILY;COUNT=5"}
+	for _, e := range []internal.Event{testEvent("e1", "A", 10), series} {
+		if err := h.createEvent(ctx, &e, false); err != nil {
+			t.Fatalf("createEvent(%s) error = %v", e.ID, err)
+		}
+	}
+
+	atOther := testEvent("x", "A", 11)
+	atOther.Location = internal.Location{ID: "l2"}
+	thirdDay := testEvent("x", "B", 13)
+	thirdDay.StartDate = thirdDay.StartDate.AddDate(0, 0, 2)
+	thirdDay.EndDate = thirdDay.EndDate.AddDate(0, 0, 2)
+	recurring := testEvent("x", "A", 11)
+	recurring.StartDate = recurring.StartDate.AddDate(0, 0, -1)
+	recurring.EndDate = recurring.EndDate.AddDate(0, 0, -1)
+	recurring.Recurrence = &internal.Recurrence{RRule: "FREQ=DAILY;COUNT=3"}
+	movedSeries := testEvent("s1", "B", 15)
+	movedSeries.Recurrence = &internal.Recurrence{RRule: "FREQ=DAILY;COUNT=5"}
+	otherSeries := movedSeries
+	otherSeries.ID = "s2"
+
+	tests := []struct {
+		name  string
+		event internal.Event
+		want  []string
+	}{
+		{"overlapping", testEvent("x", "A", 11), []string{"e1"}},
+		{"back to back", testEvent("x", "A", 12), nil},
+		{"before", testEvent("x", "A", 8), nil},
+		{"other hall", testEvent("x", "C", 11), nil},
+		{"other location", atOther, nil},
+		{"no hall", testEvent("x", "", 11), nil},
+		{"single vs occurrence", thirdDay, []string{"s1_20300103T140000Z"}},
+		{"occurrence vs single", recurring, []string{"e1"}},
+		{"replaced single", testEvent("e1", "A", 11), nil},
+		{"replaced series", movedSeries, nil},
+		{"series vs series", otherSeries, []string{
+			"s1_20300101T140000Z", "s1_20300102T140000Z", "s1_20300103T140000Z",
+			"s1_20300104T140000Z", "s1_20300105T140000Z",
+		}},
+	}
+	for _, tt := range tests {
+		err := h.checkOverlap(ctx, &tt.event)
+		var conflict *internal.ConflictError
+		switch {
+		case tt.want == nil && err != nil:
+			t.Errorf("%s: checkOverlap() error = %v, want none", tt.name, err)
+		case tt.want == nil:
+		case !errors.As(err, &conflict):
+			t.Errorf("%s: checkOverlap() error = %v, want a conflict", tt.name, err)
+		case !reflect.DeepEqual(sorted(conflict.IDs), tt.want):
+			t.Errorf("%s: checkOverlap() conflicts = %v, want %v", tt.name, conflict.IDs, tt.want)
+		}
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	h, ctx := newTestHandler(t, false)
+	for i := 0; i < maxConflicts+1; i++ {
+		e := testEvent(fmt.Sprintf("e%d", i), "A", 0)
+		e.StartDate = e.StartDate.Add(time.Duration(i) * time.Minute)
+		e.EndDate = e.StartDate.Add(time.Minute)
+		if err := h.createEvent(ctx, &e, false); err != nil {
+			t.Fatalf("createEvent() error = %v", err)
+		}
+	}
+
+	// The occurrences cover all the events, but only so many are listed.
+	event := testEvent("x", "A", 0)
+	occurrences := []internal.Event{event, testEvent("x", "A", 2)}
+	ids, err := h.findConflicts(ctx, &event, occurrences)
+	if err != nil {
+		t.Fatalf("findConflicts() error = %v", err)
+	}
+	if len(ids) != maxConflicts {
+		t.Errorf("findConflicts() = %d ids, want %d", len(ids), maxConflicts)
+	}
+
+	// The events are excluded from their own conflicts.
+	event.ID = "e0"
+	ids, err = h.findConflicts(ctx, &event, occurrences[:1])
+	if err != nil {
+		t.Fatalf("findConflicts() error = %v", err)
+	}
+	if slices.Contains(ids, "e0") {
+		t.Errorf("findConflicts() = %v, want the event itself left out", ids)
+	}
+}
+
+func TestLockLocation(t *testing.T) {
+	h, ctx := newTestHandler(t, false)
+	e := testEvent("e1", "A", 10)
+	if err := h.lockEventLocation(ctx, &e); err != nil {
+		t.Errorf("lockEventLocation() error = %v", err)
+	}
+
+	// Events cannot be stored at a location that was deleted meanwhile, even
+	// if they passed the checks before.
+	stored, err := h.checkEvent(ctx, &e)
+	if err != nil {
+		t.Fatalf("checkEvent() error = %v", err)
+	}
+	if err := h.eventsDB.Delete(ctx, internal.LocationsCollection, "l1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	err = h.storeEvent(ctx, &stored, false, func(context.Context) error {
+		t.Errorf("storeEvent() stored the event")
+		return nil
+	})
+	var verr *internal.ValidationError
+	if !errors.As(err, &verr) || verr.Fields[0].Field != "location.id" {
+		t.Errorf("storeEvent() error = %v, want a validation error of location.id", err)
+	}
+}
+
+// sorted returns the given ids in order.
+func sorted(ids []string) []string {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	return ids
+}
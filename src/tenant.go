@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// tenantField is the field of the published messages that holds the tenant of
+// the change that they announce, and of the consumed messages that holds the
+// tenant of the event that they refer to.
+const tenantField = "tenant_id"
+
+// tenancy resolves the tenant of the requests to the rest and grpc apis. A
+// request is bound to the tenant of its client, i.e. of the token or the API
+// key, or to the tenant of the host to which it is sent. Only the clients that
+// may act across tenants can choose the tenant of the requests that are not
+// bound to one, by naming it in the tenant header.
+type tenancy struct {
+	// header is the header, or the grpc metadata, that names the
+	// tenant of a request.
+	header string
+
+	// crossRoles are the roles of the clients that may act
+	// across tenants.
+	crossRoles []string
+
+	// claim is the claim of the tokens that names the tenant of
+	// the client.
+	claim string
+
+	// hosts maps the hosts to their tenants, and apiKeys maps the
+	// names of the API keys to their tenants.
+	hosts   map[string]string
+	apiKeys map[string]string
+
+	// required rejects the requests whose tenant cannot be
+	// resolved, instead of serving them within the
+	// [internal.DefaultTenant].
+	required bool
+}
+
+// newTenancy creates the tenant resolution selected by the configuration.
+func newTenancy(cfg *TenantConfig) (*tenancy, error) {
+	hosts, err := parseTenantMap(cfg.Hosts)
+	if err != nil {
+		return nil, fmt.Errorf("hosts: %w", err)
+	}
+	apiKeys, err := parseTenantMap(cfg.APIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("api keys: %w", err)
+	}
+	return &tenancy{
+		header:     cfg.Header,
+		crossRoles: cfg.CrossTenantRoles,
+		claim:      cfg.Claim,
+		hosts:      hosts,
+		apiKeys:    apiKeys,
+		required:   cfg.Required,
+	}, nil
+}
+
+// parseTenantMap parses the comma separated "name:tenant" pairs of the
+// configuration. The names are not case sensitive. This function returns
+// [service.ErrUnexpected] if a pair is malformed or the tenant is not valid.
+func parseTenantMap(pairs []string) (map[string]string, error) {
+	res := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, tenant, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || !internal.ValidTenant(tenant) {
+			return nil, fmt.Errorf("%w: invalid pair %q", service.ErrUnexpected, pair)
+		}
+		res[strings.ToLower(name)] = tenant
+	}
+	return res, nil
+}
+
+// resolve returns the tenant of a request, given the tenant named by its
+// header and the host to which it was sent. The principal of the client, if
+// any, must be held by the context. This function returns
+// [service.ErrBadRequest] if the named tenant is not valid, or if no tenant is
+// resolved while one is required, and [service.ErrNotAllowed] if the header
+// names another tenant than the one to which the request is bound, or if the
+// client may not choose the tenant.
+func (t *tenancy) resolve(ctx context.Context, named, host string) (string, error) {
+	if named != "" && !internal.ValidTenant(named) {
+		return "", fmt.Errorf("%w: invalid tenant %q", service.ErrBadRequest, named)
+	}
+	principal, _ := auth.PrincipalFromContext(ctx)
+	tenant, err := t.bound(principal, host)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case named == "" || named == tenant:
+	case tenant != "":
+		return "", fmt.Errorf(
+			"%w: the request is bound to tenant %q", service.ErrNotAllowed, tenant)
+	case !t.crossTenant(principal):
+		return "", fmt.Errorf("%w: the client may not choose the tenant", service.ErrNotAllowed)
+	default:
+		tenant = named
+	}
+
+	switch {
+	case tenant != "":
+		return tenant, nil
+	case t.required:
+		return "", fmt.Errorf("%w: no tenant", service.ErrBadRequest)
+	default:
+		return internal.DefaultTenant, nil
+	}
+}
+
+// bound returns the tenant to which a request is bound by its client or by the
+// host to which it was sent, or an empty string if it is bound to none. This
+// function returns [service.ErrNotAllowed] if the client belongs to another
+// tenant than the host.
+func (t *tenancy) bound(principal *auth.Principal, host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	byHost := t.hosts[strings.ToLower(host)]
+	own := t.ofPrincipal(principal)
+	switch {
+	case own == "":
+		return byHost, nil
+	case byHost != "" && byHost != own:
+		return "", fmt.Errorf("%w: the client belongs to tenant %q", service.ErrNotAllowed, own)
+	default:
+		return own, nil
+	}
+}
+
+// ofPrincipal returns the tenant to which the client belongs, or an empty
+// string if the client is anonymous or does not belong to a tenant, e.g. if it
+// authenticated with the admin token.
+func (t *tenancy) ofPrincipal(principal *auth.Principal) string {
+	if principal == nil {
+		return ""
+	}
+	switch principal.Method {
+	case auth.MethodJWT:
+		tenant, _ := principal.Claims[t.claim].(string)
+		return tenant
+	case auth.MethodAPIKey:
+		return t.apiKeys[strings.ToLower(principal.Subject)]
+	default:
+		return ""
+	}
+}
+
+// crossTenant returns true if the client may act across tenants, i.e. if it
+// was granted one of the cross tenant roles. Anonymous clients never may.
+func (t *tenancy) crossTenant(principal *auth.Principal) bool {
+	if principal == nil {
+		return false
+	}
+	for _, role := range principal.Roles {
+		if slices.Contains(t.crossRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// scope returns a copy of the context that is scoped to the given tenant. The
+// logs written within the context carry the tenant.
+func scope(ctx context.Context, tenant string) context.Context {
+	logger := internal.Logger(ctx).With(slog.String("tenant", tenant))
+	return internal.WithTenant(internal.WithLogger(ctx, logger), tenant)
+}
+
+// middleware is a middleware that resolves the tenant of the requests to the
+// rest api. It must run after the clients are authenticated.
+func (t *tenancy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tenant, err := t.resolve(ctx, r.Header.Get(t.header), r.Host)
+		if err != nil {
+			httpError(ctx, w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(scope(ctx, tenant)))
+	})
+}
+
+// unaryInterceptor resolves the tenant of the requests to the unary grpc
+// methods. It must run after the clients are authenticated.
+func (t *tenancy) unaryInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	scoped, err := t.resolveGRPC(ctx)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return handler(scoped, req)
+}
+
+// streamInterceptor resolves the tenant of the requests to the streaming grpc
+// methods. It must run after the clients are authenticated.
+func (t *tenancy) streamInterceptor(
+	srv any,
+	stream grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, err := t.resolveGRPC(stream.Context())
+	if err != nil {
+		return grpcError(stream.Context(), err)
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// resolveGRPC resolves the tenant of a grpc request from its metadata. The
+// tenant is named by the metadata with the name of the tenant header, and the
+// host is the authority of the request.
+func (t *tenancy) resolveGRPC(ctx context.Context) (context.Context, error) {
+	first := func(key string) string {
+		if v := metadata.ValueFromIncomingContext(ctx, key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	tenant, err := t.resolve(ctx, first(strings.ToLower(t.header)), first(":authority"))
+	if err != nil {
+		return nil, err
+	}
+	return scope(ctx, tenant), nil
+}
+
+// withTenantField adds the tenant of the context to the given JSON encoded
+// message, which must be an object. This function returns
+// [service.ErrUnexpected] if the message is not an object.
+func withTenantField(ctx context.Context, msg []byte) ([]byte, error) {
+	tenant, err := internal.RequireTenant(ctx)
+	if err != nil {
+		return nil, internal.Unexpected(ctx, err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return nil, internal.Unexpected(ctx, fmt.Errorf("decode message: %w", err))
+	}
+	fields[tenantField], _ = json.Marshal(tenant) //nolint:errchkjson // strings are encodable
+	res, err := json.Marshal(fields)
+	if err != nil {
+		return nil, internal.Unexpected(ctx, fmt.Errorf("encode message: %w", err))
+	}
+	return res, nil
+}
+
+// messageTenant returns the tenant named by the given JSON encoded message that
+// was consumed from the message bus. Messages published by services that are
+// not aware of tenants name none, and belong to the [internal.DefaultTenant].
+// This function returns [service.ErrBadRequest] if the tenant is not valid.
+func messageTenant(msg []byte) (string, error) {
+	var m struct {
+		TenantID string `json:"tenant_id"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return "", fmt.Errorf("%w: decode message: %v", service.ErrBadRequest, err)
+	}
+	switch {
+	case m.TenantID == "":
+		return internal.DefaultTenant, nil
+	case !internal.ValidTenant(m.TenantID):
+		return "", fmt.Errorf("%w: invalid tenant %q", service.ErrBadRequest, m.TenantID)
+	default:
+		return m.TenantID, nil
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/eventscompass/events-service/src/eventspb"
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/auth"
+	"github.com/eventscompass/service-framework/service"
+)
+
+const (
+	testSecret     = "0123456789abcdef0123456789abcdef"
+	testAdminToken = "admin-token"
+)
+
+// newTestTenancy creates the tenant resolution of the tests. The host
+// acme.example.com and the API key "partner" belong to the tenant acme.
+func newTestTenancy(t *testing.T, required bool) *tenancy {
+	t.Helper()
+	tenants, err := newTenancy(&TenantConfig{
+		Header:           "X-Tenant-ID",
+		CrossTenantRoles: []string{auth.RoleAdmin},
+		Claim:            "tenant",
+		Hosts:            []string{"acme.example.com:acme"},
+		APIKeys:          []string{"Partner:acme"},
+		Required:         required,
+	})
+	if err != nil {
+		t.Fatalf("newTenancy() error = %v", err)
+	}
+	return tenants
+}
+
+// newTestAuthPolicy creates the authentication of the tests, which accepts the
+// tokens signed with the test secret and the API keys "partner" and "ops".
+func newTestAuthPolicy(t *testing.T) *authPolicy {
+	t.Helper()
+	p, err := newAuthPolicy(&AuthConfig{
+		JWTSecret:   testSecret,
+		APIKeys:     []string{"partner:partner-key", "ops:ops-key"},
+		PublicReads: true,
+	}, testAdminToken)
+	if err != nil {
+		t.Fatalf("newAuthPolicy() error = %v", err)
+	}
+	return p
+}
+
+// signToken returns a token with the given claims, signed with the test secret.
+func signToken(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	claims["sub"] = "bob"
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	input := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseTenantMapInvalid(t *testing.T) {
+	for _, pair := range []string{"acme", ":acme", "host:", "host:Acme", "host:a b"} {
+		if _, err := parseTenantMap([]string{pair}); !errors.Is(err, service.ErrUnexpected) {
+			t.Errorf("parseTenantMap(%q) error = %v, want %v", pair, err, service.ErrUnexpected)
+		}
+	}
+}
+
+func TestOfPrincipal(t *testing.T) {
+	tenants := newTestTenancy(t, false)
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		want      string
+	}{
+		{"anonymous", nil, ""},
+		{"token", &auth.Principal{
+			Method: auth.MethodJWT, Claims: map[string]any{"tenant": "globex"},
+		}, "globex"},
+		{"token without claim", &auth.Principal{Method: auth.MethodJWT}, ""},
+		{"token with other claim", &auth.Principal{
+			Method: auth.MethodJWT, Claims: map[string]any{"tenant": 7.0},
+		}, ""},
+		{"api key", &auth.Principal{Method: auth.MethodAPIKey, Subject: "partner"}, "acme"},
+		{"unmapped api key", &auth.Principal{Method: auth.MethodAPIKey, Subject: "ops"}, ""},
+		{"admin token", &auth.Principal{
+			Method: auth.MethodAdminToken, Subject: auth.RoleAdmin, Roles: []string{auth.RoleAdmin},
+		}, ""},
+		{"api key claim", &auth.Principal{
+			Method: auth.MethodAPIKey, Subject: "ops", Claims: map[string]any{"tenant": "globex"},
+		}, ""},
+	}
+	for _, tt := range tests {
+		if got := tenants.ofPrincipal(tt.principal); got != tt.want {
+			t.Errorf("%s: ofPrincipal() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	globex := &auth.Principal{Method: auth.MethodJWT, Claims: map[string]any{"tenant": "globex"}}
+	partner := &auth.Principal{Method: auth.MethodAPIKey, Subject: "partner"}
+	ops := &auth.Principal{Method: auth.MethodAPIKey, Subject: "ops"}
+	admin := &auth.Principal{
+		Method: auth.MethodAdminToken, Subject: auth.RoleAdmin, Roles: []string{auth.RoleAdmin},
+	}
+	globexAdmin := &auth.Principal{
+		Method: auth.MethodJWT, Roles: []string{auth.RoleAdmin},
+		Claims: map[string]any{"tenant": "globex"},
+	}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		header    string
+		host      string
+		required  bool
+		want      string
+		wantErr   error
+	}{
+		{name: "anonymous", want: internal.DefaultTenant},
+		{name: "anonymous required", required: true, wantErr: service.ErrBadRequest},
+		{name: "anonymous header", header: "globex", wantErr: service.ErrNotAllowed},
+		{name: "anonymous host", host: "acme.example.com", want: "acme"},
+		{name: "host with port", host: "ACME.example.com:8080", want: "acme"},
+		{name: "unknown host", host: "other.example.com", want: internal.DefaultTenant},
+		{
+			name: "header of host", header: "acme", host: "acme.example.com",
+			want: "acme",
+		},
+		{
+			name: "header other than host", header: "globex", host: "acme.example.com",
+			wantErr: service.ErrNotAllowed,
+		},
+		{name: "invalid header", header: "Acme!", wantErr: service.ErrBadRequest},
+		{name: "token", principal: globex, want: "globex"},
+		{name: "token required", principal: globex, required: true, want: "globex"},
+		{name: "token header", principal: globex, header: "globex", want: "globex"},
+		{
+			name: "token other header", principal: globex, header: "acme",
+			wantErr: service.ErrNotAllowed,
+		},
+		{
+			name: "token other host", principal: globex, host: "acme.example.com",
+			wantErr: service.ErrNotAllowed,
+		},
+		{name: "api key", principal: partner, want: "acme"},
+		{name: "api key host", principal: partner, host: "acme.example.com", want: "acme"},
+		{
+			name: "api key other header", principal: partner, header: "globex",
+			wantErr: service.ErrNotAllowed,
+		},
+		{name: "unmapped api key", principal: ops, want: internal.DefaultTenant},
+		{
+			name: "unmapped api key header", principal: ops, header: "globex",
+			wantErr: service.ErrNotAllowed,
+		},
+		{name: "admin header", principal: admin, header: "globex", want: "globex"},
+		{name: "admin", principal: admin, want: internal.DefaultTenant},
+		{
+			name: "admin header other than host", principal: admin, header: "globex",
+			host: "acme.example.com", wantErr: service.ErrNotAllowed,
+		},
+		{
+			name: "admin of tenant", principal: globexAdmin, header: "acme",
+			wantErr: service.ErrNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.principal != nil {
+			ctx = auth.WithPrincipal(ctx, tt.principal)
+		}
+		got, err := newTestTenancy(t, tt.required).resolve(ctx, tt.header, tt.host)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("%s: resolve() = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	p, tenants := newTestAuthPolicy(t), newTestTenancy(t, false)
+	handler := p.middleware(tenants.middleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, _ := internal.TenantFromContext(r.Context())
+			_, _ = w.Write([]byte(tenant))
+		})))
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		host     string
+		wantCode int
+		want     string
+	}{
+		{name: "anonymous", wantCode: http.StatusOK, want: internal.DefaultTenant},
+		{name: "host", host: "acme.example.com", wantCode: http.StatusOK, want: "acme"},
+		{
+			name:     "anonymous header",
+			headers:  map[string]string{"X-Tenant-ID": "globex"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "host and other header",
+			headers:  map[string]string{"X-Tenant-ID": "globex"},
+			host:     "acme.example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "token",
+			headers: map[string]string{
+				"Authorization": "Bearer " + signToken(t, map[string]any{"tenant": "globex"}),
+			},
+			wantCode: http.StatusOK,
+			want:     "globex",
+		},
+		{
+			name: "token other header",
+			headers: map[string]string{
+				"Authorization": "Bearer " + signToken(t, map[string]any{"tenant": "globex"}),
+				"X-Tenant-ID":   "acme",
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "api key",
+			headers:  map[string]string{apiKeyHeader: "partner-key"},
+			wantCode: http.StatusOK,
+			want:     "acme",
+		},
+		{
+			name:     "unmapped api key header",
+			headers:  map[string]string{apiKeyHeader: "ops-key", "X-Tenant-ID": "globex"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "admin token header",
+			headers:  map[string]string{adminTokenHeader: testAdminToken, "X-Tenant-ID": "globex"},
+			wantCode: http.StatusOK,
+			want:     "globex",
+		},
+		{
+			name:     "invalid header",
+			headers:  map[string]string{adminTokenHeader: testAdminToken, "X-Tenant-ID": "a b"},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/events", http.NoBody)
+		if tt.host != "" {
+			r.Host = tt.host
+		}
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: code = %d, want %d", tt.name, w.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantCode == http.StatusOK && w.Body.String() != tt.want {
+			t.Errorf("%s: tenant = %q, want %q", tt.name, w.Body.String(), tt.want)
+		}
+	}
+}
+
+// testStream is a server stream with a fixed context.
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx // the context of the stream
+}
+
+// Context implements the [grpc.ServerStream] interface.
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestTenantInterceptors(t *testing.T) {
+	p, tenants := newTestAuthPolicy(t), newTestTenancy(t, false)
+	method := eventspb.EventsService_GetEvent_FullMethodName
+	listMethod := eventspb.EventsService_ListEvents_FullMethodName
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		wantCode codes.Code
+		want     string
+	}{
+		{name: "anonymous", md: metadata.Pairs(), want: internal.DefaultTenant},
+		{name: "authority", md: metadata.Pairs(":authority", "acme.example.com:443"), want: "acme"},
+		{
+			name:     "anonymous header",
+			md:       metadata.Pairs("x-tenant-id", "globex"),
+			wantCode: codes.PermissionDenied,
+		},
+		{name: "api key", md: metadata.Pairs("x-api-key", "partner-key"), want: "acme"},
+		{
+			name:     "api key other header",
+			md:       metadata.Pairs("x-api-key", "partner-key", "x-tenant-id", "globex"),
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name: "admin token header",
+			md:   metadata.Pairs("x-admin-token", testAdminToken, "x-tenant-id", "globex"),
+			want: "globex",
+		},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+		// The unary interceptors.
+		var got string
+		_, err := p.unaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req any) (any, error) {
+				return tenants.unaryInterceptor(ctx, req, nil,
+					func(ctx context.Context, _ any) (any, error) {
+						got, _ = internal.TenantFromContext(ctx)
+						return nil, nil
+					})
+			})
+		if status.Code(err) != tt.wantCode || got != tt.want {
+			t.Errorf("%s: unary tenant = %q, %v, want %q, %v",
+				tt.name, got, err, tt.want, tt.wantCode)
+		}
+
+		// The stream interceptors.
+		got = ""
+		info := &grpc.StreamServerInfo{FullMethod: listMethod}
+		err = p.streamInterceptor(nil, &testStream{ctx: ctx}, info,
+			func(srv any, stream grpc.ServerStream) error {
+				return tenants.streamInterceptor(srv, stream, nil,
+					func(_ any, stream grpc.ServerStream) error {
+						got, _ = internal.TenantFromContext(stream.Context())
+						return nil
+					})
+			})
+		if status.Code(err) != tt.wantCode || got != tt.want {
+			t.Errorf("%s: stream tenant = %q, %v, want %q, %v",
+				tt.name, got, err, tt.want, tt.wantCode)
+		}
+	}
+}
+
+func TestMessageTenant(t *testing.T) {
+	tests := []struct {
+		msg     string
+		want    string
+		wantErr error
+	}{
+		{`{"event_id":"e1"}`, internal.DefaultTenant, nil},
+		{`{"event_id":"e1","tenant_id":""}`, internal.DefaultTenant, nil},
+		{`{"event_id":"e1","tenant_id":"acme"}`, "acme", nil},
+		{`{"event_id":"e1","tenant_id":"ACME"}`, "", service.ErrBadRequest},
+		{`{"event_id":"e1","tenant_id":7}`, "", service.ErrBadRequest},
+		{`not json`, "", service.ErrBadRequest},
+	}
+	for _, tt := range tests {
+		got, err := messageTenant([]byte(tt.msg))
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("messageTenant(%s) = %q, %v, want %q, %v",
+				tt.msg, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWithTenantField(t *testing.T) {
+	ctx := internal.WithTenant(context.Background(), "acme")
+	got, err := withTenantField(ctx, []byte(`{"id":"e1","tenant_id":"globex"}`))
+	if err != nil {
+		t.Fatalf("withTenantField() error = %v", err)
+	}
+	if want := `{"id":"e1","tenant_id":"acme"}`; string(got) != want {
+		t.Errorf("withTenantField() = %s, want %s", got, want)
+	}
+	if tenant, err := messageTenant(got); err != nil || tenant != "acme" {
+		t.Errorf("messageTenant() = %q, %v, want acme", tenant, err)
+	}
+
+	if _, err := withTenantField(context.Background(), []byte(`{}`)); err == nil {
+		t.Errorf("withTenantField() without tenant error = nil")
+	}
+	if _, err := withTenantField(ctx, []byte(`[]`)); err == nil {
+		t.Errorf("withTenantField() of array error = nil")
+	}
+}
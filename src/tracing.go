@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/eventscompass/events-service/src/internal"
+	"github.com/eventscompass/events-service/src/internal/tracing"
+	"github.com/eventscompass/service-framework/service"
+)
+
+// traceparentHeader is the header that carries the trace context, both in http
+// requests and in messages.
+const traceparentHeader = "traceparent"
+
+// newTracer creates the tracer selected by the configuration, together with
+// the task that exports the spans.
+func newTracer(cfg *TracingConfig) (*tracing.Tracer, func(context.Context) error, error) {
+	switch cfg.Exporter {
+	case "otlp":
+		exporter := tracing.NewOTLPExporter(&tracing.OTLPConfig{
+			Endpoint:    cfg.Endpoint,
+			ServiceName: cfg.ServiceName,
+			Interval:    cfg.ExportInterval,
+		})
+		return tracing.NewTracer(exporter), exporter.Run, nil
+	case "none":
+		// The trace context is still propagated, so that the
+		// traces of other services are not broken.
+		return tracing.NewTracer(nil), func(context.Context) error { return nil }, nil
+	default:
+		return nil, nil, fmt.Errorf(
+			"%w: unknown traces exporter %q", service.ErrUnexpected, cfg.Exporter)
+	}
+}
+
+// traceRequests is a middleware that traces every http request with a server
+// span. If the request carries the trace context of the client in the
+// traceparent header, then the span continues the trace of the client. The
+// logs of the request carry the id of the trace.
+func traceRequests(tracer *tracing.Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if sc, ok := tracing.ParseTraceparent(r.Header.Get(traceparentHeader)); ok {
+				ctx = tracing.ContextWithRemoteSpanContext(ctx, sc)
+			}
+			ctx, span := tracer.Start(ctx, r.Method, tracing.SpanKindServer,
+				tracing.String("http.request.method", r.Method),
+				tracing.String("url.path", r.URL.Path),
+			)
+			logger := internal.Logger(ctx).With(
+				slog.String("trace_id", span.SpanContext().TraceID.String()))
+			ctx = internal.WithLogger(ctx, logger)
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+			// The span is ended in a deferred call, so that requests
+			// aborted with a panic are traced as well.
+			defer func() {
+				route := routePattern(r)
+				span.SetName(r.Method + " " + route)
+				span.SetAttributes(
+					tracing.String("http.route", route),
+					tracing.Int("http.response.status_code", sw.status),
+				)
+				if sw.status >= http.StatusInternalServerError {
+					span.SetErrorMessage(http.StatusText(sw.status))
+				}
+				span.End()
+			}()
+			next.ServeHTTP(sw, r.WithContext(ctx))
+		})
+	}
+}